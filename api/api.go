@@ -7,6 +7,7 @@ import (
 	"io/fs"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/PlakarKorp/kloset/repository"
 	"github.com/PlakarKorp/kloset/snapshot"
@@ -25,6 +26,8 @@ type uiserver struct {
 	// only get a KContext, except sometimes you truly need an
 	// AppContext.
 	ctx *appcontext.AppContext
+
+	events *eventHub
 }
 
 type Item[T any] struct {
@@ -101,16 +104,29 @@ func (view APIView) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 // TokenAuthMiddleware is a middleware that checks for the token in the request. If the token is empty, the middleware is a no-op.
 func TokenAuthMiddleware(token string) func(http.Handler) http.Handler {
+	return AuthMiddleware(token, nil)
+}
+
+// AuthMiddleware accepts requests bearing either the static token or a
+// JWT validated by oidc.  If neither is configured, the middleware is a
+// no-op.
+func AuthMiddleware(token string, oidc *OIDCVerifier) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if token != "" {
+			if token != "" || oidc != nil {
 				key := r.Header.Get("Authorization")
 				if key == "" {
 					handleError(w, r, authError("missing Authorization header"))
 					return
 				}
 
-				if subtle.ConstantTimeCompare([]byte(key), []byte("Bearer "+token)) == 0 {
+				valid := token != "" && subtle.ConstantTimeCompare([]byte(key), []byte("Bearer "+token)) == 1
+				if !valid && oidc != nil {
+					if bearer, ok := strings.CutPrefix(key, "Bearer "); ok {
+						valid = oidc.Verify(bearer) == nil
+					}
+				}
+				if !valid {
 					handleError(w, r, authError("invalid token"))
 					return
 				}
@@ -142,16 +158,24 @@ func (ui *uiserver) apiInfo(w http.ResponseWriter, r *http.Request) error {
 	return json.NewEncoder(w).Encode(res)
 }
 
-func SetupRoutes(server *http.ServeMux, repo *repository.Repository, ctx *appcontext.AppContext, token string) {
+func SetupRoutes(server *http.ServeMux, repo *repository.Repository, ctx *appcontext.AppContext, token string) error {
 	ui := uiserver{
 		store:      repo.Store(),
 		config:     repo.Configuration(),
 		repository: repo,
 		ctx:        ctx,
+		events:     newEventHub(ctx),
 	}
 
-	authToken := TokenAuthMiddleware(token)
-	urlSigner := NewSnapshotReaderURLSigner(&ui, token)
+	oidc, err := NewOIDCVerifierFromEnv()
+	if err != nil {
+		return err
+	}
+	authToken := AuthMiddleware(token, oidc)
+	urlSigner, err := NewSnapshotReaderURLSigner(&ui, authToken)
+	if err != nil {
+		return err
+	}
 
 	// Catch all API endpoint, called if no more specific API endpoint is found
 	server.Handle("/api/", JSONAPIView(func(w http.ResponseWriter, r *http.Request) error {
@@ -163,6 +187,8 @@ func SetupRoutes(server *http.ServeMux, repo *repository.Repository, ctx *appcon
 	}))
 
 	server.Handle("GET /api/info", authToken(JSONAPIView(ui.apiInfo)))
+	server.Handle("GET /api/events", authToken(APIView(ui.eventsStream)))
+	server.Handle("GET /api/auth/openid-configuration", JSONAPIView(ui.authOpenIDConfiguration(oidc)))
 
 	server.Handle("POST /api/authentication/login/github", authToken(JSONAPIView(ui.servicesLoginGithub)))
 	server.Handle("POST /api/authentication/login/email", authToken(JSONAPIView(ui.servicesLoginEmail)))
@@ -176,6 +202,7 @@ func SetupRoutes(server *http.ServeMux, repo *repository.Repository, ctx *appcon
 	server.Handle("GET /api/proxy/v1/reporting/reports", authToken(JSONAPIView(ui.servicesProxy)))
 
 	server.Handle("GET /api/repository/info", authToken(JSONAPIView(ui.repositoryInfo)))
+	server.Handle("GET /api/repository/stats", authToken(JSONAPIView(ui.repositoryStats)))
 	server.Handle("GET /api/repository/snapshots", authToken(JSONAPIView(ui.repositorySnapshots)))
 	server.Handle("GET /api/repository/locate-pathname", authToken(JSONAPIView(ui.repositoryLocatePathname)))
 	server.Handle("GET /api/repository/importer-types", authToken(JSONAPIView(ui.repositoryImporterTypes)))
@@ -183,6 +210,7 @@ func SetupRoutes(server *http.ServeMux, repo *repository.Repository, ctx *appcon
 	server.Handle("GET /api/repository/state/{state}", authToken(JSONAPIView(ui.repositoryState)))
 
 	server.Handle("GET /api/snapshot/{snapshot}", authToken(JSONAPIView(ui.snapshotHeader)))
+	server.Handle("GET /api/snapshot/statistics/{snapshot}", authToken(JSONAPIView(ui.snapshotStatistics)))
 	server.Handle("GET /api/snapshot/reader/{snapshot_path...}", urlSigner.VerifyMiddleware(APIView(ui.snapshotReader)))
 	server.Handle("POST /api/snapshot/reader-sign-url/{snapshot_path...}", authToken(JSONAPIView(urlSigner.Sign)))
 
@@ -194,4 +222,6 @@ func SetupRoutes(server *http.ServeMux, repo *repository.Repository, ctx *appcon
 
 	server.Handle("POST /api/snapshot/vfs/downloader/{snapshot_path...}", authToken(JSONAPIView(ui.snapshotVFSDownloader)))
 	server.Handle("GET /api/snapshot/vfs/downloader-sign-url/{id}", JSONAPIView(ui.snapshotVFSDownloaderSigned))
+
+	return nil
 }
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/coder/websocket"
+)
+
+// clientQueueSize is the number of events buffered for a websocket client
+// before further events are dropped for it.
+const clientQueueSize = 256
+
+// historySize is the number of past events kept to be replayed to the
+// clients connecting after they were emitted.
+const historySize = 1000
+
+type eventMessage struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+type pastEvent struct {
+	timestamp time.Time
+	event     any
+}
+
+// eventHub relays the events of the application context to the websocket
+// clients.  The events receiver blocks until every listener takes the
+// event and has no way to unregister one, so a single listener is kept
+// for the lifetime of the hub and slow clients lose events rather than
+// stall the operation emitting them.  The last events are kept so that
+// a client connecting in the middle of an operation can catch up.
+type eventHub struct {
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+	history []pastEvent
+	next    int
+}
+
+func newEventHub(ctx *appcontext.AppContext) *eventHub {
+	h := &eventHub{
+		clients: make(map[chan []byte]struct{}),
+		history: make([]pastEvent, 0, historySize),
+	}
+	go h.run(ctx.Events().Listen())
+	return h
+}
+
+func serializeEvent(event any) ([]byte, error) {
+	return json.Marshal(eventMessage{
+		Type: reflect.TypeOf(event).Name(),
+		Data: event,
+	})
+}
+
+// eventTime returns the time an event was emitted at, falling back to
+// the current time for events not carrying it.
+func eventTime(event any) time.Time {
+	v := reflect.ValueOf(event)
+	if v.Kind() == reflect.Struct {
+		if f := v.FieldByName("Timestamp"); f.IsValid() {
+			if t, ok := f.Interface().(time.Time); ok {
+				return t
+			}
+		}
+	}
+	return time.Now()
+}
+
+func (h *eventHub) run(listener <-chan any) {
+	for event := range listener {
+		past := pastEvent{timestamp: eventTime(event), event: event}
+
+		h.mu.Lock()
+		if len(h.history) < historySize {
+			h.history = append(h.history, past)
+		} else {
+			h.history[h.next] = past
+			h.next = (h.next + 1) % historySize
+		}
+
+		// don't bother serializing events nobody is waiting for
+		if len(h.clients) != 0 {
+			msg, err := serializeEvent(event)
+			if err != nil {
+				log.Printf("failed to serialize event %T: %v", event, err)
+			} else {
+				for client := range h.clients {
+					select {
+					case client <- msg:
+					default:
+					}
+				}
+			}
+		}
+		h.mu.Unlock()
+	}
+}
+
+// replay returns the past events emitted after since, oldest first.
+// It must be called with the mutex held.
+func (h *eventHub) replay(since time.Time) []any {
+	var events []any
+	for i := range h.history {
+		past := h.history[(h.next+i)%len(h.history)]
+		if past.timestamp.After(since) {
+			events = append(events, past.event)
+		}
+	}
+	return events
+}
+
+// subscribe registers a new client and returns the past events emitted
+// after since, so that no event is either missed or sent twice.
+func (h *eventHub) subscribe(since time.Time) (chan []byte, []any) {
+	client := make(chan []byte, clientQueueSize)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.clients[client] = struct{}{}
+	return client, h.replay(since)
+}
+
+func (h *eventHub) unsubscribe(client chan []byte) {
+	h.mu.Lock()
+	delete(h.clients, client)
+	h.mu.Unlock()
+}
+
+func (ui *uiserver) eventsStream(w http.ResponseWriter, r *http.Request) error {
+	since, err := QueryParamToTime(r, "since")
+	if err != nil {
+		return err
+	}
+	if since == nil {
+		since = &time.Time{}
+	}
+
+	// subscribe before accepting, so that no event sent once the
+	// client is connected is missed.
+	client, history := ui.events.subscribe(*since)
+	defer ui.events.unsubscribe(client)
+
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		// Accept already replied to the client
+		return nil
+	}
+	defer conn.CloseNow()
+
+	// the client is not expected to send anything, this only
+	// notices when it goes away.
+	ctx := conn.CloseRead(r.Context())
+
+	for _, event := range history {
+		msg, err := serializeEvent(event)
+		if err != nil {
+			log.Printf("failed to serialize event %T: %v", event, err)
+			continue
+		}
+		if err := conn.Write(ctx, websocket.MessageText, msg); err != nil {
+			return nil
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-client:
+			if err := conn.Write(ctx, websocket.MessageText, msg); err != nil {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PlakarKorp/kloset/events"
+	ptesting "github.com/PlakarKorp/plakar/testing"
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventsStream(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+
+	token := "test-token"
+	mux := http.NewServeMux()
+	SetupRoutes(mux, repo, ctx, token)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	dialCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/events"
+
+	_, resp, err := websocket.Dial(dialCtx, url, nil)
+	require.Error(t, err, "connecting without a token")
+	require.NotNil(t, resp)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	conn, _, err := websocket.Dial(dialCtx, url, &websocket.DialOptions{
+		HTTPHeader: http.Header{"Authorization": []string{"Bearer " + token}},
+	})
+	require.NoError(t, err)
+	defer conn.CloseNow()
+
+	ctx.Events().Send(events.PathEvent([32]byte{0x01}, "/etc/passwd"))
+
+	typ, data, err := conn.Read(dialCtx)
+	require.NoError(t, err)
+	require.Equal(t, websocket.MessageText, typ)
+
+	var msg struct {
+		Type string `json:"type"`
+		Data struct {
+			Pathname string
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(data, &msg))
+	require.Equal(t, "Path", msg.Type)
+	require.Equal(t, "/etc/passwd", msg.Data.Pathname)
+}
+
+func TestEventsStreamReplay(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+
+	token := "test-token"
+	mux := http.NewServeMux()
+	SetupRoutes(mux, repo, ctx, token)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx.Events().Send(events.PathEvent([32]byte{0x01}, "/before"))
+	t0 := time.Now()
+	for i := range 50 {
+		ctx.Events().Send(events.PathEvent([32]byte{0x01}, fmt.Sprintf("/file-%d", i)))
+	}
+
+	dialCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	header := http.Header{"Authorization": []string{"Bearer " + token}}
+	base := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/events"
+
+	_, resp, err := websocket.Dial(dialCtx, base+"?since=yesterday", &websocket.DialOptions{HTTPHeader: header})
+	require.Error(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	conn, _, err := websocket.Dial(dialCtx, base+"?since="+url.QueryEscape(t0.Format(time.RFC3339Nano)),
+		&websocket.DialOptions{HTTPHeader: header})
+	require.NoError(t, err)
+	defer conn.CloseNow()
+
+	readPathname := func() string {
+		_, data, err := conn.Read(dialCtx)
+		require.NoError(t, err)
+
+		var msg struct {
+			Data struct {
+				Pathname string
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(data, &msg))
+		return msg.Data.Pathname
+	}
+
+	for i := range 50 {
+		require.Equal(t, fmt.Sprintf("/file-%d", i), readPathname())
+	}
+
+	// the replay is followed by the live events
+	ctx.Events().Send(events.PathEvent([32]byte{0x01}, "/after"))
+	require.Equal(t, "/after", readPathname())
+}
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// keys are fetched again at most this often when a token is signed by
+// an unknown key, so a flood of forged tokens can't hammer the provider.
+const oidcKeysRefreshInterval = 5 * time.Minute
+
+// OIDCVerifier validates JWTs issued by an OpenID Connect provider for
+// a given client.
+type OIDCVerifier struct {
+	issuer   string
+	clientID string
+	client   *http.Client
+
+	mu        sync.Mutex
+	discovery map[string]any
+	keys      map[string]any
+	fetchedAt time.Time
+}
+
+// NewOIDCVerifierFromEnv returns a verifier configured from OIDC_ISSUER
+// and OIDC_CLIENT_ID, or nil if OIDC_ISSUER is not set.  The client ID
+// is required: without an audience to check, any token the provider
+// issued for another application would be accepted.
+func NewOIDCVerifierFromEnv() (*OIDCVerifier, error) {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+	clientID := os.Getenv("OIDC_CLIENT_ID")
+	if clientID == "" {
+		return nil, fmt.Errorf("OIDC_CLIENT_ID must be set along with OIDC_ISSUER")
+	}
+	return NewOIDCVerifier(issuer, clientID), nil
+}
+
+func NewOIDCVerifier(issuer, clientID string) *OIDCVerifier {
+	return &OIDCVerifier{
+		issuer:   issuer,
+		clientID: clientID,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (o *OIDCVerifier) getJSON(url string, v any) error {
+	res, err := o.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %s", url, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// refresh fetches the provider configuration if needed, then its keys.
+// It must be called with o.mu held.
+func (o *OIDCVerifier) refresh() error {
+	if o.discovery == nil {
+		var discovery map[string]any
+		if err := o.getJSON(strings.TrimSuffix(o.issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return err
+		}
+		if issuer, _ := discovery["issuer"].(string); issuer != o.issuer {
+			return fmt.Errorf("provider advertises issuer %q instead of %q", issuer, o.issuer)
+		}
+		o.discovery = discovery
+	}
+
+	jwksURI, _ := o.discovery["jwks_uri"].(string)
+	if jwksURI == "" {
+		return fmt.Errorf("provider configuration has no jwks_uri")
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := o.getJSON(jwksURI, &jwks); err != nil {
+		return err
+	}
+
+	keys := make(map[string]any)
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		switch k.Kty {
+		case "RSA":
+			n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+			e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+			y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{
+				Curve: curve,
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		}
+	}
+
+	o.keys = keys
+	o.fetchedAt = time.Now()
+	return nil
+}
+
+func (o *OIDCVerifier) key(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if key, ok := o.keys[kid]; ok {
+		return key, nil
+	}
+
+	if o.keys == nil || time.Since(o.fetchedAt) > oidcKeysRefreshInterval {
+		if err := o.refresh(); err != nil {
+			return nil, err
+		}
+		if key, ok := o.keys[kid]; ok {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// Verify checks the signature, issuer, audience and validity period of
+// a raw JWT.
+func (o *OIDCVerifier) Verify(raw string) error {
+	if o.clientID == "" {
+		return fmt.Errorf("no client ID configured")
+	}
+
+	_, err := jwt.Parse(raw, o.key,
+		jwt.WithIssuer(o.issuer),
+		jwt.WithAudience(o.clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}))
+	return err
+}
+
+// Configuration returns what clients need to authenticate against the
+// provider: its discovery document and the client ID to request tokens
+// for.
+func (o *OIDCVerifier) Configuration() (map[string]any, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.discovery == nil {
+		if err := o.refresh(); err != nil {
+			return nil, err
+		}
+	}
+
+	res := make(map[string]any, len(o.discovery)+1)
+	for k, v := range o.discovery {
+		res[k] = v
+	}
+	res["client_id"] = o.clientID
+	return res, nil
+}
+
+func (ui *uiserver) authOpenIDConfiguration(oidc *OIDCVerifier) JSONAPIView {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if oidc == nil {
+			return &ApiError{
+				HttpCode: 404,
+				ErrCode:  "not-found",
+				Message:  "OpenID Connect is not configured",
+			}
+		}
+
+		configuration, err := oidc.Configuration()
+		if err != nil {
+			return &ApiError{
+				HttpCode: 502,
+				ErrCode:  "bad-gateway",
+				Message:  fmt.Sprintf("failed to fetch the provider configuration: %s", err),
+			}
+		}
+		return json.NewEncoder(w).Encode(configuration)
+	}
+}
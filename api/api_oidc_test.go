@@ -0,0 +1,84 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOIDCVerifier(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var provider *httptest.Server
+	provider = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]any{
+				"issuer":   provider.URL,
+				"jwks_uri": provider.URL + "/jwks",
+			})
+		case "/jwks":
+			json.NewEncoder(w).Encode(map[string]any{
+				"keys": []map[string]string{{
+					"kid": "test",
+					"kty": "RSA",
+					"use": "sig",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer provider.Close()
+
+	sign := func(audience string, expires time.Time) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss": provider.URL,
+			"aud": audience,
+			"exp": expires.Unix(),
+		})
+		token.Header["kid"] = "test"
+		raw, err := token.SignedString(key)
+		require.NoError(t, err)
+		return raw
+	}
+
+	verifier := NewOIDCVerifier(provider.URL, "plakar")
+
+	// without a client ID there is no audience to check
+	require.Error(t, NewOIDCVerifier(provider.URL, "").Verify(sign("plakar", time.Now().Add(time.Hour))))
+
+	require.NoError(t, verifier.Verify(sign("plakar", time.Now().Add(time.Hour))))
+	require.Error(t, verifier.Verify(sign("someone-else", time.Now().Add(time.Hour))))
+	require.Error(t, verifier.Verify(sign("plakar", time.Now().Add(-time.Hour))))
+
+	configuration, err := verifier.Configuration()
+	require.NoError(t, err)
+	require.Equal(t, "plakar", configuration["client_id"])
+	require.Equal(t, provider.URL+"/jwks", configuration["jwks_uri"])
+
+	handler := AuthMiddleware("", verifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/api/info", nil)
+	req.Header.Set("Authorization", "Bearer "+sign("plakar", time.Now().Add(time.Hour)))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req.Header.Set("Authorization", "Bearer garbage")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
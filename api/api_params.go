@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/PlakarKorp/kloset/objects"
 	"github.com/PlakarKorp/kloset/repository"
@@ -91,6 +92,22 @@ func QueryParamToString(r *http.Request, param string) (string, bool, error) {
 	return str, true, nil
 }
 
+// QueryParamToTime parses an RFC3339 timestamp, returning nil if the
+// parameter is absent.
+func QueryParamToTime(r *http.Request, param string) (*time.Time, error) {
+	str := r.URL.Query().Get(param)
+	if str == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, str)
+	if err != nil {
+		return nil, parameterError(param, InvalidArgument, err)
+	}
+
+	return &t, nil
+}
+
 func QueryParamToSortKeys(r *http.Request, param, def string) ([]string, error) {
 	str := r.URL.Query().Get(param)
 	if str == "" {
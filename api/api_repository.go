@@ -1,6 +1,8 @@
 package api
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,10 +15,14 @@ import (
 
 	"github.com/PlakarKorp/kloset/objects"
 	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/repository/state"
+	"github.com/PlakarKorp/kloset/resources"
 	"github.com/PlakarKorp/kloset/snapshot"
 	"github.com/PlakarKorp/kloset/snapshot/header"
 	"github.com/PlakarKorp/kloset/snapshot/vfs"
 	"github.com/PlakarKorp/kloset/storage"
+	"github.com/google/uuid"
+	"go.omarpolo.com/ttlmap"
 )
 
 type RepositoryInfoSnapshots struct {
@@ -95,6 +101,191 @@ func (ui *uiserver) repositoryInfo(w http.ResponseWriter, r *http.Request) error
 	}})
 }
 
+type RepositoryStatsHistory struct {
+	Date          string `json:"date"`
+	SnapshotCount int    `json:"snapshot_count"`
+	LogicalBytes  int64  `json:"logical_bytes"`
+}
+
+type RepositoryStats struct {
+	SnapshotsCount       int                      `json:"snapshots_count"`
+	PackfilesCount       int                      `json:"packfiles_count"`
+	BlobsCount           int                      `json:"blobs_count"`
+	StatesCount          int                      `json:"states_count"`
+	TotalStoredBytes     int64                    `json:"total_stored_bytes"`
+	TotalLogicalBytes    int64                    `json:"total_logical_bytes"`
+	DedupRatio           float64                  `json:"dedup_ratio"`
+	AvgSnapshotSizeBytes int64                    `json:"avg_snapshot_size_bytes"`
+	SnapshotsPerDay30d   float64                  `json:"snapshots_per_day_30d"`
+	History              []RepositoryStatsHistory `json:"history,omitempty"`
+	ComputedAt           time.Time                `json:"computed_at"`
+}
+
+type repositoryStatsKey struct {
+	repositoryID uuid.UUID
+	withHistory  bool
+	states       objects.MAC
+}
+
+// repository stats require loading every snapshot header, keep them
+// around for a while as the UI tends to poll this endpoint.  They are
+// keyed on the set of states, which every write to the repository
+// changes.
+var repositoryStatsCache = ttlmap.New[repositoryStatsKey, RepositoryStats](time.Hour)
+
+func init() {
+	repositoryStatsCache.AutoExpire()
+}
+
+func getRepositoryStats(repo *repository.Repository, withHistory bool) (RepositoryStats, error) {
+	const historyDays = 90
+
+	var stats RepositoryStats
+
+	states, err := repo.GetStates()
+	if err != nil {
+		return stats, err
+	}
+	stats.StatesCount = len(states)
+
+	for range repo.ListPackfiles() {
+		stats.PackfilesCount++
+	}
+
+	stats.BlobsCount, err = countUniqueBlobs(repo)
+	if err != nil {
+		return stats, err
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	historyStart := today.AddDate(0, 0, -(historyDays - 1))
+
+	var history []RepositoryStatsHistory
+	if withHistory {
+		history = make([]RepositoryStatsHistory, historyDays)
+		for i := range history {
+			history[i].Date = historyStart.AddDate(0, 0, i).Format(time.DateOnly)
+		}
+	}
+
+	nLastMonth := 0
+	for snapshotID := range repo.ListSnapshots() {
+		snap, err := snapshot.Load(repo, snapshotID)
+		if err != nil {
+			continue
+		}
+
+		size := int64(snap.Header.GetSource(0).Summary.Directory.Size + snap.Header.GetSource(0).Summary.Below.Size)
+		timestamp := snap.Header.Timestamp.UTC()
+		snap.Close()
+
+		stats.SnapshotsCount++
+		stats.TotalLogicalBytes += size
+
+		if now.Sub(timestamp) < 30*24*time.Hour {
+			nLastMonth++
+		}
+
+		if withHistory && !timestamp.Before(historyStart) {
+			day := int(timestamp.Sub(historyStart).Hours() / 24)
+			if day < historyDays {
+				history[day].SnapshotCount++
+				history[day].LogicalBytes += size
+			}
+		}
+	}
+
+	stats.TotalStoredBytes = repo.StorageSize()
+	if stats.TotalStoredBytes > 0 {
+		stats.DedupRatio = float64(stats.TotalLogicalBytes) / float64(stats.TotalStoredBytes)
+	}
+	if stats.SnapshotsCount > 0 {
+		stats.AvgSnapshotSizeBytes = stats.TotalLogicalBytes / int64(stats.SnapshotsCount)
+	}
+	stats.SnapshotsPerDay30d = float64(nLastMonth) / 30
+	stats.History = history
+	stats.ComputedAt = time.Now()
+
+	return stats, nil
+}
+
+// countUniqueBlobs counts the distinct blobs referenced by the local
+// state of the repository, whatever packfile they live in.  The state
+// is expected to be up to date, see RebuildState.
+func countUniqueBlobs(repo *repository.Repository) (int, error) {
+	cache, err := repo.AppContext().GetCache().Repository(repo.Configuration().RepositoryID)
+	if err != nil {
+		return 0, err
+	}
+	st := state.NewLocalState(cache)
+
+	count := 0
+	for _, Type := range resources.Types() {
+		seen := make(map[objects.MAC]struct{})
+		for entry, err := range st.ListObjectsOfType(Type) {
+			if err != nil {
+				return 0, err
+			}
+			seen[entry.Blob] = struct{}{}
+		}
+		count += len(seen)
+	}
+
+	return count, nil
+}
+
+// statesDigest summarizes the set of states of the repository.
+func statesDigest(repo *repository.Repository) (objects.MAC, error) {
+	states, err := repo.GetStates()
+	if err != nil {
+		return objects.MAC{}, err
+	}
+	slices.SortFunc(states, func(a, b objects.MAC) int {
+		return bytes.Compare(a[:], b[:])
+	})
+
+	h := sha256.New()
+	for _, mac := range states {
+		h.Write(mac[:])
+	}
+	return objects.MAC(h.Sum(nil)), nil
+}
+
+func (ui *uiserver) repositoryStats(w http.ResponseWriter, r *http.Request) error {
+	withHistory := r.URL.Query().Get("history") == "true"
+	refresh := r.URL.Query().Get("refresh") == "true"
+
+	states, err := statesDigest(ui.repository)
+	if err != nil {
+		return err
+	}
+
+	key := repositoryStatsKey{
+		repositoryID: ui.repository.Configuration().RepositoryID,
+		withHistory:  withHistory,
+		states:       states,
+	}
+
+	// maintenance invalidates the stats it affects, possibly from
+	// another process.
+	stats, ok := repositoryStatsCache.Get(key)
+	if ok && !stats.ComputedAt.After(ui.ctx.GetCookies().RepositoryStatsInvalidatedAt(key.repositoryID)) {
+		ok = false
+	}
+	if !ok || refresh {
+		ui.repository.RebuildState()
+
+		stats, err = getRepositoryStats(ui.repository, withHistory)
+		if err != nil {
+			return err
+		}
+		repositoryStatsCache.Add(key, stats)
+	}
+
+	return json.NewEncoder(w).Encode(Item[RepositoryStats]{Item: stats})
+}
+
 func (ui *uiserver) repositorySnapshots(w http.ResponseWriter, r *http.Request) error {
 	offset, err := QueryParamToUint32(r, "offset", 0, 0)
 	if err != nil {
@@ -109,6 +300,17 @@ func (ui *uiserver) repositorySnapshots(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		return err
 	}
+	if importerType == "" {
+		importerType, _, err = QueryParamToString(r, "importer_type")
+		if err != nil {
+			return err
+		}
+	}
+
+	origin, _, err := QueryParamToString(r, "origin")
+	if err != nil {
+		return err
+	}
 
 	var sinceTime time.Time
 	since, _, err := QueryParamToString(r, "since")
@@ -150,6 +352,11 @@ func (ui *uiserver) repositorySnapshots(w http.ResponseWriter, r *http.Request)
 			continue
 		}
 
+		if origin != "" && !strings.EqualFold(snap.Header.GetSource(0).Importer.Origin, origin) {
+			snap.Close()
+			continue
+		}
+
 		if since != "" && snap.Header.Timestamp.Before(sinceTime) {
 			snap.Close()
 			continue
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,6 +14,7 @@ import (
 	"github.com/PlakarKorp/kloset/caching"
 	"github.com/PlakarKorp/kloset/hashing"
 	"github.com/PlakarKorp/kloset/logging"
+	"github.com/PlakarKorp/kloset/objects"
 	"github.com/PlakarKorp/kloset/repository"
 	"github.com/PlakarKorp/kloset/resources"
 	"github.com/PlakarKorp/kloset/storage"
@@ -662,3 +664,156 @@ func Test_RepositoryStateErrors(t *testing.T) {
 		})
 	}
 }
+
+func Test_RepositoryStatsBlobs(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+
+	// identical contents are stored once
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/a.txt", 0644, "same content"),
+		ptesting.NewMockFile("subdir/b.txt", 0644, "same content"),
+		ptesting.NewMockFile("subdir/c.txt", 0644, "other content"),
+	})
+	snap.Close()
+
+	packfiles, err := repo.GetPackfiles()
+	require.NoError(t, err)
+
+	unique := make(map[resources.Type]map[objects.MAC]struct{})
+	for _, packfileID := range packfiles {
+		p, err := repo.GetPackfile(packfileID)
+		require.NoError(t, err)
+		for _, blob := range p.Index {
+			if unique[blob.Type] == nil {
+				unique[blob.Type] = make(map[objects.MAC]struct{})
+			}
+			unique[blob.Type][blob.MAC] = struct{}{}
+		}
+	}
+	expected := 0
+	for _, blobs := range unique {
+		expected += len(blobs)
+	}
+
+	var noToken string
+	mux := http.NewServeMux()
+	SetupRoutes(mux, repo, ctx, noToken)
+
+	req, err := http.NewRequest("GET", "/api/repository/stats", nil)
+	require.NoError(t, err, "creating request")
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp Item[RepositoryStats]
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+
+	require.Equal(t, 1, resp.Item.SnapshotsCount)
+	require.Equal(t, len(packfiles), resp.Item.PackfilesCount)
+	require.Equal(t, expected, resp.Item.BlobsCount)
+	require.Len(t, unique[resources.RT_CHUNK], 2)
+}
+
+func Test_RepositoryStatsCache(t *testing.T) {
+	stats := func(mux *http.ServeMux, query string) RepositoryStats {
+		req, err := http.NewRequest("GET", "/api/repository/stats"+query, nil)
+		require.NoError(t, err, "creating request")
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp Item[RepositoryStats]
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		return resp.Item
+	}
+
+	var noToken string
+	repos := make([]*repository.Repository, 2)
+	muxes := make([]*http.ServeMux, 2)
+	for i := range repos {
+		var ctx *appcontext.AppContext
+		repos[i], ctx = ptesting.GenerateRepository(t, bytes.NewBuffer(nil), bytes.NewBuffer(nil), nil)
+		for range i + 1 {
+			snap := ptesting.GenerateSnapshot(t, repos[i], []ptesting.MockFile{
+				ptesting.NewMockFile("dummy.txt", 0644, "hello dummy"),
+			})
+			snap.Close()
+		}
+		muxes[i] = http.NewServeMux()
+		SetupRoutes(muxes[i], repos[i], ctx, noToken)
+	}
+
+	// each repository gets its own stats
+	require.Equal(t, 1, stats(muxes[0], "").SnapshotsCount)
+	require.Equal(t, 2, stats(muxes[1], "").SnapshotsCount)
+
+	// they are cached until asked to refresh them
+	cached := stats(muxes[0], "")
+	require.Equal(t, cached.ComputedAt, stats(muxes[0], "").ComputedAt)
+	require.True(t, stats(muxes[0], "?refresh=true").ComputedAt.After(cached.ComputedAt))
+
+	// or until the repository changes
+	snap := ptesting.GenerateSnapshot(t, repos[0], []ptesting.MockFile{
+		ptesting.NewMockFile("dummy.txt", 0644, "hello dummy"),
+	})
+	snap.Close()
+	require.Equal(t, 2, stats(muxes[0], "").SnapshotsCount)
+	require.Equal(t, 2, stats(muxes[1], "").SnapshotsCount)
+}
+
+func Test_RepositoryStatsInvalidation(t *testing.T) {
+	repo, ctx := ptesting.GenerateRepository(t, bytes.NewBuffer(nil), bytes.NewBuffer(nil), nil)
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockFile("dummy.txt", 0644, "hello dummy"),
+	})
+	snap.Close()
+
+	var noToken string
+	mux := http.NewServeMux()
+	SetupRoutes(mux, repo, ctx, noToken)
+
+	stats := func() RepositoryStats {
+		req, err := http.NewRequest("GET", "/api/repository/stats", nil)
+		require.NoError(t, err, "creating request")
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp Item[RepositoryStats]
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		return resp.Item
+	}
+
+	cached := stats()
+	require.Equal(t, 1, cached.SnapshotsCount)
+	require.Equal(t, cached.ComputedAt, stats().ComputedAt)
+
+	// a new snapshot writes a new state
+	snap = ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockFile("dummy.txt", 0644, "hello dummy"),
+	})
+	snap.Close()
+
+	recomputed := stats()
+	require.Equal(t, 2, recomputed.SnapshotsCount)
+	require.True(t, recomputed.ComputedAt.After(cached.ComputedAt))
+	require.Equal(t, recomputed.ComputedAt, stats().ComputedAt)
+
+	// removing it too
+	require.NoError(t, repo.DeleteSnapshot(snap.Header.Identifier))
+	require.Equal(t, 1, stats().SnapshotsCount)
+	cached = stats()
+
+	// as done by maintenance
+	require.NoError(t, ctx.GetCookies().InvalidateRepositoryStats(repo.Configuration().RepositoryID))
+
+	recomputed = stats()
+	require.Equal(t, 1, recomputed.SnapshotsCount)
+	require.True(t, recomputed.ComputedAt.After(cached.ComputedAt))
+}
@@ -2,15 +2,22 @@ package api
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/PlakarKorp/kloset/caching/lru"
@@ -18,7 +25,11 @@ import (
 	"github.com/PlakarKorp/kloset/repository"
 	"github.com/PlakarKorp/kloset/snapshot"
 	"github.com/PlakarKorp/kloset/snapshot/header"
+	"github.com/PlakarKorp/kloset/snapshot/importer"
 	"github.com/PlakarKorp/kloset/snapshot/vfs"
+	"github.com/PlakarKorp/kloset/storage"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/utils"
 	"github.com/alecthomas/chroma/formatters"
 	"github.com/alecthomas/chroma/lexers"
 	"github.com/alecthomas/chroma/styles"
@@ -27,6 +38,10 @@ import (
 	"go.omarpolo.com/ttlmap"
 )
 
+// archivePtar is handled here rather than by snapshot.Archive since it
+// needs a writable repository to be built.
+const archivePtar = "ptar"
+
 type downloadSignedUrl struct {
 	snapshotID [32]byte
 	rebase     bool
@@ -66,7 +81,35 @@ func (ui *uiserver) snapshotHeader(w http.ResponseWriter, r *http.Request) error
 		return err
 	}
 
-	return json.NewEncoder(w).Encode(Item[*header.Header]{Item: snap.Header})
+	health := utils.SnapshotHealth(snap.Header, utils.DefaultRPO, time.Now())
+	return json.NewEncoder(w).Encode(Item[SnapshotHeader]{Item: SnapshotHeader{
+		Header:      snap.Header,
+		HealthScore: health.Score,
+	}})
+}
+
+type SnapshotHeader struct {
+	*header.Header
+	HealthScore int `json:"health_score"`
+}
+
+func (ui *uiserver) snapshotStatistics(w http.ResponseWriter, r *http.Request) error {
+	snapshotID32, err := PathParamToID(r, "snapshot")
+	if err != nil {
+		return err
+	}
+
+	snap, err := loadsnap(ui.repository, snapshotID32)
+	if err != nil {
+		return err
+	}
+
+	stats, err := utils.GetSnapshotStatistics(snap)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(Item[*utils.SnapshotStatistics]{Item: stats})
 }
 
 func (ui *uiserver) snapshotReader(w http.ResponseWriter, r *http.Request) error {
@@ -170,13 +213,22 @@ func (ui *uiserver) snapshotReader(w http.ResponseWriter, r *http.Request) error
 	return nil
 }
 
+// SnapshotReaderURLSigner signs URLs with a key drawn when the server
+// starts, so signed URLs don't outlive it and can't be forged from the
+// API token, which may be empty.  Requests without a signature go
+// through auth.
 type SnapshotReaderURLSigner struct {
-	ui    *uiserver
-	token string
+	ui   *uiserver
+	auth func(http.Handler) http.Handler
+	key  []byte
 }
 
-func NewSnapshotReaderURLSigner(ui *uiserver, token string) SnapshotReaderURLSigner {
-	return SnapshotReaderURLSigner{ui, token}
+func NewSnapshotReaderURLSigner(ui *uiserver, auth func(http.Handler) http.Handler) (SnapshotReaderURLSigner, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return SnapshotReaderURLSigner{}, fmt.Errorf("failed to generate the URL signing key: %w", err)
+	}
+	return SnapshotReaderURLSigner{ui, auth, key}, nil
 }
 
 type SnapshotSignedURLClaims struct {
@@ -192,6 +244,10 @@ func (signer SnapshotReaderURLSigner) Sign(w http.ResponseWriter, r *http.Reques
 	}
 	snapshotId := fmt.Sprintf("%0x", snapshotID32[:])
 
+	if len(signer.key) == 0 {
+		return fmt.Errorf("no URL signing key")
+	}
+
 	now := time.Now()
 	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, SnapshotSignedURLClaims{
 		SnapshotID: snapshotId,
@@ -203,7 +259,7 @@ func (signer SnapshotReaderURLSigner) Sign(w http.ResponseWriter, r *http.Reques
 		},
 	})
 
-	signature, err := jwtToken.SignedString([]byte(signer.token))
+	signature, err := jwtToken.SignedString(signer.key)
 	if err != nil {
 		return err
 	}
@@ -226,7 +282,12 @@ func (signer SnapshotReaderURLSigner) VerifyMiddleware(next http.Handler) http.H
 
 		// No signature provided, fall back to Authorization header
 		if signature == "" {
-			TokenAuthMiddleware(signer.token)(next).ServeHTTP(w, r)
+			signer.auth(next).ServeHTTP(w, r)
+			return
+		}
+
+		if len(signer.key) == 0 {
+			handleError(w, r, authError("invalid URL signature"))
 			return
 		}
 
@@ -234,7 +295,7 @@ func (signer SnapshotReaderURLSigner) VerifyMiddleware(next http.Handler) http.H
 			if _, ok := jwtToken.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, authError(fmt.Sprintf("unexpected signing method: %v", jwtToken.Header["alg"]))
 			}
-			return []byte(signer.token), nil
+			return signer.key, nil
 		})
 
 		if err != nil {
@@ -487,6 +548,23 @@ func (ui *uiserver) snapshotVFSSearch(w http.ResponseWriter, r *http.Request) er
 		pattern = str
 	}
 
+	modAfter, err := QueryParamToTime(r, "mod_after")
+	if err != nil {
+		return err
+	}
+	modBefore, err := QueryParamToTime(r, "mod_before")
+	if err != nil {
+		return err
+	}
+
+	var pathRegex *regexp.Regexp
+	if str := r.URL.Query().Get("path_regex"); str != "" {
+		pathRegex, err = regexp.Compile(str)
+		if err != nil {
+			return parameterError("path_regex", InvalidArgument, err)
+		}
+	}
+
 	snap, err := loadsnap(ui.repository, snapshotID32)
 	if err != nil {
 		return err
@@ -496,6 +574,16 @@ func (ui *uiserver) snapshotVFSSearch(w http.ResponseWriter, r *http.Request) er
 		path = "/"
 	}
 
+	// The VFS of a snapshot never changes once committed, so the
+	// same query on the same snapshot always yields the same page.
+	etag := searchETag(ui.repository, snap.Header.GetSource(0).VFS.Root, path, r.URL.Query())
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "max-age=60")
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
 	// for pagination: fetch one more item so we know
 	// whether there's a next page of results.
 	limit++
@@ -510,6 +598,15 @@ func (ui *uiserver) snapshotVFSSearch(w http.ResponseWriter, r *http.Request) er
 		Limit:  limit,
 	}
 
+	// SearchOpts has no notion of modification time nor of full path
+	// patterns, so when filtering on them the pagination has to happen
+	// here, after the filter.
+	postFilter := modAfter != nil || modBefore != nil || pathRegex != nil
+	if postFilter {
+		searchOpts.Offset = 0
+		searchOpts.Limit = 0
+	}
+
 	items := ItemsPage[*vfs.Entry]{
 		Items: []*vfs.Entry{},
 	}
@@ -519,6 +616,7 @@ func (ui *uiserver) snapshotVFSSearch(w http.ResponseWriter, r *http.Request) er
 		return err
 	}
 
+	skipped := 0
 	for entry, err := range it {
 		if err != nil {
 			if err == context.Canceled {
@@ -527,7 +625,27 @@ func (ui *uiserver) snapshotVFSSearch(w http.ResponseWriter, r *http.Request) er
 			return err
 		}
 
+		if postFilter {
+			mtime := entry.Stat().ModTime()
+			if modAfter != nil && mtime.Before(*modAfter) {
+				continue
+			}
+			if modBefore != nil && mtime.After(*modBefore) {
+				continue
+			}
+			if pathRegex != nil && !pathRegex.MatchString(entry.Path()) {
+				continue
+			}
+			if skipped < offset {
+				skipped++
+				continue
+			}
+		}
+
 		items.Items = append(items.Items, entry)
+		if len(items.Items) == limit {
+			break
+		}
 	}
 
 	if limit == len(items.Items) {
@@ -538,6 +656,27 @@ func (ui *uiserver) snapshotVFSSearch(w http.ResponseWriter, r *http.Request) er
 	return json.NewEncoder(w).Encode(items)
 }
 
+func searchETag(repo *repository.Repository, root objects.MAC, path string, query url.Values) string {
+	var buf bytes.Buffer
+	buf.Write(root[:])
+	buf.WriteString(path)
+	buf.WriteByte(0)
+	buf.WriteString(query.Encode())
+
+	mac := repo.ComputeMAC(buf.Bytes())
+	return fmt.Sprintf("%q", hex.EncodeToString(mac[:]))
+}
+
+func etagMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
 func (ui *uiserver) snapshotVFSErrors(w http.ResponseWriter, r *http.Request) error {
 	snapshotID32, path, err := SnapshotPathParam(r, ui.repository, "snapshot_path")
 	if err != nil {
@@ -687,6 +826,9 @@ func (ui *uiserver) snapshotVFSDownloaderSigned(w http.ResponseWriter, r *http.R
 	case snapshot.ArchiveZip:
 		mime = "application/zip"
 		ext = ".zip"
+	case archivePtar:
+		mime = "application/octet-stream"
+		ext = ".ptar"
 	default:
 		return &ApiError{
 			HttpCode: 400,
@@ -702,5 +844,221 @@ func (ui *uiserver) snapshotVFSDownloaderSigned(w http.ResponseWriter, r *http.R
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", name))
 	w.Header().Set("Content-Type", mime)
 
+	if format == archivePtar {
+		return ui.exportPtar(w, link.snapshotID, link.files, link.rebase)
+	}
+
 	return snap.Archive(w, format, link.files, link.rebase)
 }
+
+// exportPtar writes the snapshot, or only the given paths of it, as a
+// self-contained ptar archive to w.  The archive is built in a temporary
+// file, as the ptar layout needs to seek back to write its trailer, and
+// then streamed out.  It reuses the repository encryption parameters so
+// that the same passphrase unlocks it.
+func (ui *uiserver) exportPtar(w io.Writer, snapshotID objects.MAC, paths []string, rebase bool) error {
+	// don't use the snapcache here, the header gets rewritten while
+	// synchronizing.
+	snap, err := snapshot.Load(ui.repository, snapshotID)
+	if err != nil {
+		return err
+	}
+	defer snap.Close()
+
+	tmpdir, err := os.MkdirTemp("", "plakar-export-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	location := filepath.Join(tmpdir, "export.ptar")
+
+	srcConfig := ui.repository.Configuration()
+	config := storage.NewConfiguration()
+	config.RepositoryID = uuid.Must(uuid.NewRandom())
+	config.Compression = srcConfig.Compression
+	config.Hashing = srcConfig.Hashing
+	config.Encryption = srcConfig.Encryption
+
+	pw, err := utils.NewPtarWriter(ui.ctx, "ptar://"+location, config, ui.ctx.GetSecret())
+	if err != nil {
+		return err
+	}
+
+	if len(paths) == 0 || (len(paths) == 1 && path.Clean(paths[0]) == "/") {
+		err = ptarSynchronize(snap, pw.RepositoryWriter)
+	} else {
+		err = ptarSelection(ui.ctx, snap, pw.RepositoryWriter, paths, rebase)
+	}
+	if err != nil {
+		pw.Close()
+		return err
+	}
+
+	if err := pw.Commit(); err != nil {
+		return err
+	}
+
+	fp, err := os.Open(location)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	_, err = io.Copy(w, fp)
+	return err
+}
+
+// ptarSynchronize copies the whole snapshot as is.
+func ptarSynchronize(src *snapshot.Snapshot, repoWriter *repository.RepositoryWriter) error {
+	dst, err := snapshot.CreateWithRepositoryWriter(repoWriter)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	// keep the original snapshot info
+	dst.Header = src.Header
+
+	if err := src.Synchronize(dst); err != nil {
+		return err
+	}
+
+	return dst.Commit(nil, false)
+}
+
+// ptarSelection backs up the given paths of the snapshot into a new one,
+// rebased at the root when asked to, the same way the other archive
+// formats lay them out.
+func ptarSelection(ctx *appcontext.AppContext, src *snapshot.Snapshot, repoWriter *repository.RepositoryWriter, paths []string, rebase bool) error {
+	fs, err := src.Filesystem()
+	if err != nil {
+		return err
+	}
+
+	dst, err := snapshot.CreateWithRepositoryWriter(repoWriter)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	imp := &snapshotImporter{
+		fs:     fs,
+		source: src.Header.GetSource(0),
+		paths:  paths,
+		rebase: rebase,
+	}
+
+	return dst.Backup(imp, &snapshot.BackupOptions{
+		Name:            src.Header.Name,
+		Tags:            src.Header.Tags,
+		MaxConcurrency:  uint64(ctx.MaxConcurrency),
+		NoCheckpoint:    true,
+		NoCommit:        true,
+		CleanupVFSCache: true,
+	})
+}
+
+// snapshotImporter imports a selection of paths out of a snapshot.
+type snapshotImporter struct {
+	fs     *vfs.Filesystem
+	source *header.Source
+	paths  []string
+	rebase bool
+}
+
+func (imp *snapshotImporter) Origin() string { return imp.source.Importer.Origin }
+func (imp *snapshotImporter) Type() string   { return imp.source.Importer.Type }
+func (imp *snapshotImporter) Root() string   { return "/" }
+func (imp *snapshotImporter) Close() error   { return nil }
+
+func (imp *snapshotImporter) Scan() (<-chan *importer.ScanResult, error) {
+	results := make(chan *importer.ScanResult)
+
+	go func() {
+		defer close(results)
+
+		seen := make(map[string]struct{})
+		emit := func(pathname string, entry *vfs.Entry) {
+			if _, ok := seen[pathname]; ok {
+				return
+			}
+			seen[pathname] = struct{}{}
+
+			fileinfo := entry.FileInfo
+			fileinfo.Lname = path.Base(pathname)
+			if entry.IsDir() {
+				results <- &importer.ScanResult{
+					Record: &importer.ScanRecord{
+						Pathname: pathname,
+						FileInfo: fileinfo,
+					},
+				}
+				return
+			}
+			results <- importer.NewScanRecord(pathname, entry.SymlinkTarget, fileinfo, nil,
+				func() (io.ReadCloser, error) {
+					return entry.Open(imp.fs), nil
+				})
+		}
+		fail := func(pathname string, err error) {
+			results <- &importer.ScanResult{
+				Error: &importer.ScanError{Pathname: pathname, Err: err},
+			}
+		}
+
+		for _, selected := range imp.paths {
+			selected = path.Clean("/" + selected)
+
+			// keep the parent directories of the selection, unless it
+			// is rebased at the root.
+			if !imp.rebase {
+				for dir := path.Dir(selected); dir != selected; dir = path.Dir(dir) {
+					entry, err := imp.fs.GetEntry(dir)
+					if err != nil {
+						fail(dir, err)
+						break
+					}
+					emit(dir, entry)
+					if dir == "/" {
+						break
+					}
+				}
+			}
+
+			err := imp.fs.WalkDir(selected, func(entrypath string, entry *vfs.Entry, err error) error {
+				if err != nil {
+					return err
+				}
+
+				pathname := entrypath
+				if imp.rebase {
+					pathname = strings.TrimPrefix(entrypath, selected)
+					if pathname == "" && !entry.IsDir() {
+						pathname = path.Base(entrypath)
+					}
+					pathname = path.Join("/", pathname)
+				}
+
+				emit(pathname, entry)
+				return nil
+			})
+			if err != nil {
+				fail(selected, err)
+				continue
+			}
+
+			// a file rebased at the root still needs a root directory
+			if _, ok := seen["/"]; !ok {
+				entry, err := imp.fs.GetEntry(path.Dir(selected))
+				if err != nil {
+					fail(path.Dir(selected), err)
+					continue
+				}
+				emit("/", entry)
+			}
+		}
+	}()
+
+	return results, nil
+}
@@ -7,18 +7,24 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/PlakarKorp/kloset/caching"
 	"github.com/PlakarKorp/kloset/hashing"
 	"github.com/PlakarKorp/kloset/logging"
 	"github.com/PlakarKorp/kloset/repository"
 	"github.com/PlakarKorp/kloset/resources"
+	"github.com/PlakarKorp/kloset/snapshot"
 	"github.com/PlakarKorp/kloset/storage"
 	"github.com/PlakarKorp/kloset/versioning"
 	"github.com/PlakarKorp/plakar/appcontext"
+	_ "github.com/PlakarKorp/plakar/connectors/ptar/storage"
 	ptesting "github.com/PlakarKorp/plakar/testing"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/require"
 )
 
@@ -299,7 +305,8 @@ func _TestSnapshotSign(t *testing.T) {
 			require.NoError(t, err, "creating request")
 
 			w := httptest.NewRecorder()
-			urlSigner := NewSnapshotReaderURLSigner(nil, token)
+			urlSigner, err := NewSnapshotReaderURLSigner(nil, TokenAuthMiddleware(token))
+			require.NoError(t, err)
 			urlSigner.Sign(w, req)
 
 			response := w.Result()
@@ -326,3 +333,340 @@ func _TestSnapshotSign(t *testing.T) {
 		})
 	}
 }
+
+func TestSnapshotReaderSignedURL(t *testing.T) {
+	repo, ctx := ptesting.GenerateRepository(t, bytes.NewBuffer(nil), bytes.NewBuffer(nil), nil)
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/dummy.txt", 0644, "hello dummy"),
+	})
+	defer snap.Close()
+
+	snapshotPath := fmt.Sprintf("%x:/subdir/dummy.txt", snap.Header.Identifier)
+
+	// a signature made with the given key, as the server used to
+	forge := func(key string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, SnapshotSignedURLClaims{
+			SnapshotID: fmt.Sprintf("%0x", snap.Header.Identifier[:]),
+			Path:       "/subdir/dummy.txt",
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		})
+		signature, err := token.SignedString([]byte(key))
+		require.NoError(t, err)
+		return signature
+	}
+
+	read := func(mux *http.ServeMux, signature string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/snapshot/reader/"+snapshotPath+"?signature="+signature, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	token := "test-token"
+	mux := http.NewServeMux()
+	require.NoError(t, SetupRoutes(mux, repo, ctx, token))
+
+	req := httptest.NewRequest("POST", "/api/snapshot/reader-sign-url/"+snapshotPath, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var signed Item[struct {
+		Signature string `json:"signature"`
+	}]
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&signed))
+
+	w = read(mux, signed.Item.Signature)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "hello dummy", w.Body.String())
+
+	require.Equal(t, http.StatusUnauthorized, read(mux, forge(token)).Code)
+
+	// with OIDC alone there is no token, signatures can't be made with
+	// an empty key and unsigned requests still need to authenticate
+	t.Setenv("OIDC_ISSUER", "http://127.0.0.1:1")
+	t.Setenv("OIDC_CLIENT_ID", "plakar")
+	mux = http.NewServeMux()
+	require.NoError(t, SetupRoutes(mux, repo, ctx, ""))
+
+	require.Equal(t, http.StatusUnauthorized, read(mux, forge("")).Code)
+	require.Equal(t, http.StatusUnauthorized, read(mux, "").Code)
+
+	t.Setenv("OIDC_CLIENT_ID", "")
+	require.ErrorContains(t, SetupRoutes(http.NewServeMux(), repo, ctx, ""), "OIDC_CLIENT_ID")
+}
+
+func TestEtagMatch(t *testing.T) {
+	etag := `"0123abcd"`
+
+	require.False(t, etagMatch("", etag))
+	require.True(t, etagMatch(etag, etag))
+	require.True(t, etagMatch(`W/"0123abcd"`, etag))
+	require.True(t, etagMatch(`"ffff", "0123abcd"`, etag))
+	require.True(t, etagMatch("*", etag))
+	require.False(t, etagMatch(`"ffff"`, etag))
+}
+
+func TestSnapshotVFSSearchETag(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/dummy.txt", 0644, "hello dummy"),
+	})
+	defer snap.Close()
+
+	var noToken string
+	mux := http.NewServeMux()
+	SetupRoutes(mux, repo, ctx, noToken)
+
+	search := func(query, ifNoneMatch string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", fmt.Sprintf("/api/snapshot/vfs/search/%x:/?%s", snap.Header.Identifier, query), nil)
+		require.NoError(t, err, "creating request")
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := search("recursive=true", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	require.Equal(t, "max-age=60", w.Header().Get("Cache-Control"))
+	require.NotZero(t, w.Body.Len())
+
+	// the same query yields the same ETag, another query another one
+	require.Equal(t, etag, search("recursive=true", "").Header().Get("ETag"))
+	require.NotEqual(t, etag, search("recursive=false", "").Header().Get("ETag"))
+
+	w = search("recursive=true", etag)
+	require.Equal(t, http.StatusNotModified, w.Code)
+	require.Equal(t, etag, w.Header().Get("ETag"))
+	require.Zero(t, w.Body.Len())
+
+	w = search("recursive=true", `"ffff"`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotZero(t, w.Body.Len())
+}
+
+// searchNames runs a recursive search on the root of snap with the
+// extra query parameters and returns the status code and, on success,
+// the names of the entries found.
+func searchNames(t *testing.T, mux *http.ServeMux, snap *snapshot.Snapshot, query string) (int, []string) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("/api/snapshot/vfs/search/%x:/?recursive=true&limit=10&%s", snap.Header.Identifier, query), nil)
+	require.NoError(t, err, "creating request")
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		return w.Code, nil
+	}
+
+	var page struct {
+		Items []struct {
+			FileInfo struct {
+				Name string `json:"name"`
+			} `json:"file_info"`
+		} `json:"items"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&page))
+
+	var names []string
+	for _, item := range page.Items {
+		names = append(names, item.FileInfo.Name)
+	}
+	return w.Code, names
+}
+
+func TestSnapshotVFSSearchModTime(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+
+	day := func(d int) time.Time {
+		return time.Date(2025, time.January, d, 12, 0, 0, 0, time.UTC)
+	}
+
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFileModTime("subdir/old.txt", 0644, "subdir/old.txt", day(1)),
+		ptesting.NewMockFileModTime("subdir/middle.txt", 0644, "subdir/middle.txt", day(10)),
+		ptesting.NewMockFileModTime("subdir/recent.txt", 0644, "subdir/recent.txt", day(20)),
+	})
+	defer snap.Close()
+
+	var noToken string
+	mux := http.NewServeMux()
+	SetupRoutes(mux, repo, ctx, noToken)
+
+	search := func(query string) (int, []string) {
+		return searchNames(t, mux, snap, query)
+	}
+
+	_, names := search("")
+	require.ElementsMatch(t, []string{"old.txt", "middle.txt", "recent.txt"}, names)
+
+	_, names = search("mod_after=" + day(5).Format(time.RFC3339))
+	require.ElementsMatch(t, []string{"middle.txt", "recent.txt"}, names)
+
+	_, names = search("mod_before=" + day(15).Format(time.RFC3339))
+	require.ElementsMatch(t, []string{"old.txt", "middle.txt"}, names)
+
+	_, names = search("mod_after=" + day(5).Format(time.RFC3339) + "&mod_before=" + day(15).Format(time.RFC3339))
+	require.Equal(t, []string{"middle.txt"}, names)
+
+	_, names = search("mod_after=" + day(25).Format(time.RFC3339))
+	require.Empty(t, names)
+
+	code, _ := search("mod_after=yesterday")
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestSnapshotVFSSearchPathRegex(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("logs"),
+		ptesting.NewMockDir("logs/old"),
+		ptesting.NewMockDir("other"),
+		ptesting.NewMockFile("top.log", 0644, "top"),
+		ptesting.NewMockFile("logs/app.log", 0644, "app"),
+		ptesting.NewMockFile("logs/readme.txt", 0644, "readme"),
+		ptesting.NewMockFile("logs/old/app1.log", 0644, "app1"),
+		ptesting.NewMockFile("other/app2.log", 0644, "app2"),
+	})
+	defer snap.Close()
+
+	var noToken string
+	mux := http.NewServeMux()
+	SetupRoutes(mux, repo, ctx, noToken)
+
+	search := func(pattern string) (int, []string) {
+		return searchNames(t, mux, snap, "path_regex="+url.QueryEscape(pattern))
+	}
+
+	_, names := search(`\.log$`)
+	require.ElementsMatch(t, []string{"top.log", "app.log", "app1.log", "app2.log"}, names)
+
+	_, names = search(`/logs/.*\.log$`)
+	require.ElementsMatch(t, []string{"app.log", "app1.log"}, names)
+
+	_, names = search(`/logs/[^/]+$`)
+	require.ElementsMatch(t, []string{"app.log", "readme.txt"}, names)
+
+	code, _ := search(`(`)
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestSnapshotDownloadPtarSelection(t *testing.T) {
+	repo, ctx := ptesting.GenerateRepository(t, bytes.NewBuffer(nil), bytes.NewBuffer(nil), nil)
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockDir("other"),
+		ptesting.NewMockFile("subdir/a.txt", 0644, "hello a"),
+		ptesting.NewMockFile("subdir/b.txt", 0644, "hello b"),
+		ptesting.NewMockFile("other/c.txt", 0644, "hello c"),
+	})
+	snap.Close()
+
+	var noToken string
+	mux := http.NewServeMux()
+	SetupRoutes(mux, repo, ctx, noToken)
+
+	download := func(query DownloadQuery) string {
+		body, err := json.Marshal(query)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest("POST", fmt.Sprintf("/api/snapshot/vfs/downloader/%x:/", snap.Header.Identifier), bytes.NewReader(body))
+		require.NoError(t, err, "creating request")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var link struct {
+			Id string `json:"id"`
+		}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+
+		req, err = http.NewRequest("GET", fmt.Sprintf("/api/snapshot/vfs/downloader-sign-url/%s?format=ptar", link.Id), nil)
+		require.NoError(t, err, "creating request")
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		archive := filepath.Join(t.TempDir(), "export.ptar")
+		require.NoError(t, os.WriteFile(archive, w.Body.Bytes(), 0600))
+		return archive
+	}
+
+	contents := func(archive string) map[string]string {
+		st, config, err := storage.Open(ctx.GetInner(), map[string]string{"location": "ptar://" + archive})
+		require.NoError(t, err)
+		defer st.Close()
+
+		ptarRepo, err := repository.New(ctx.GetInner(), nil, st, config)
+		require.NoError(t, err)
+
+		ret := make(map[string]string)
+		for snapshotID := range ptarRepo.ListSnapshots() {
+			ptarSnap, err := snapshot.Load(ptarRepo, snapshotID)
+			require.NoError(t, err)
+			require.Equal(t, snap.Header.Name, ptarSnap.Header.Name)
+
+			fs, err := ptarSnap.Filesystem()
+			require.NoError(t, err)
+			for entry, err := range fs.Files("/") {
+				require.NoError(t, err)
+				if !entry.FileInfo.Mode().IsRegular() {
+					continue
+				}
+				fp, err := fs.Open(entry.Path())
+				require.NoError(t, err)
+				data, err := io.ReadAll(fp)
+				require.NoError(t, err)
+				fp.Close()
+				ret[entry.Path()] = string(data)
+			}
+			ptarSnap.Close()
+		}
+		return ret
+	}
+
+	selection := []DownloadItem{{Pathname: "/subdir"}}
+
+	archive := download(DownloadQuery{Items: selection})
+	require.Equal(t, map[string]string{
+		"/subdir/a.txt": "hello a",
+		"/subdir/b.txt": "hello b",
+	}, contents(archive))
+
+	archive = download(DownloadQuery{Items: selection, Rebase: true})
+	require.Equal(t, map[string]string{
+		"/a.txt": "hello a",
+		"/b.txt": "hello b",
+	}, contents(archive))
+
+	archive = download(DownloadQuery{Items: []DownloadItem{{Pathname: "/other/c.txt"}}, Rebase: true})
+	require.Equal(t, map[string]string{
+		"/c.txt": "hello c",
+	}, contents(archive))
+
+	archive = download(DownloadQuery{Items: []DownloadItem{{Pathname: "/"}}})
+	require.Equal(t, map[string]string{
+		"/subdir/a.txt": "hello a",
+		"/subdir/b.txt": "hello b",
+		"/other/c.txt":  "hello c",
+	}, contents(archive))
+}
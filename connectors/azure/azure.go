@@ -0,0 +1,5 @@
+package azure
+
+import (
+	_ "github.com/PlakarKorp/plakar/connectors/azure/storage"
+)
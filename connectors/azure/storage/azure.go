@@ -0,0 +1,378 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/storage"
+)
+
+// BLOCK_SIZE is the size of the blocks staged when uploading a
+// packfile, packfiles are committed once all their blocks are staged.
+const BLOCK_SIZE = 4 << 20
+
+type Store struct {
+	location  string
+	ctx       context.Context
+	container string
+	prefixDir string
+
+	serviceURL       string
+	accountName      string
+	accountKey       string
+	sasToken         string
+	connectionString string
+
+	client *container.Client
+}
+
+func init() {
+	storage.Register("azure", 0, NewStore)
+}
+
+func NewStore(ctx context.Context, proto string, storeConfig map[string]string) (storage.Store, error) {
+	location := storeConfig["location"]
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("parse location: %w", err)
+	}
+	if parsed.Scheme != "azure" {
+		return nil, fmt.Errorf("invalid location %q, expected azure://container[/prefix]", location)
+	}
+
+	containerName := parsed.Host
+	if value, ok := storeConfig["container"]; ok {
+		if containerName != "" && value != containerName {
+			return nil, fmt.Errorf("container %q does not match location %q", value, location)
+		}
+		containerName = value
+	}
+	if containerName == "" {
+		return nil, fmt.Errorf("missing container")
+	}
+
+	prefix := strings.Trim(parsed.Path, "/")
+	if value, ok := storeConfig["prefix"]; ok && prefix == "" {
+		prefix = strings.Trim(value, "/")
+	}
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	s := &Store{
+		location:         location,
+		ctx:              ctx,
+		container:        containerName,
+		prefixDir:        prefix,
+		accountName:      storeConfig["account_name"],
+		accountKey:       storeConfig["account_key"],
+		sasToken:         strings.TrimPrefix(storeConfig["sas_token"], "?"),
+		connectionString: storeConfig["connection_string"],
+	}
+
+	if s.connectionString == "" {
+		if s.accountName == "" {
+			return nil, fmt.Errorf("missing account_name")
+		}
+		if s.sasToken == "" && s.accountKey == "" {
+			return nil, fmt.Errorf("missing sas_token, account_key or connection_string")
+		}
+	}
+
+	s.serviceURL = fmt.Sprintf("https://%s.blob.core.windows.net/", s.accountName)
+	if value, ok := storeConfig["endpoint"]; ok {
+		s.serviceURL = strings.TrimSuffix(value, "/") + "/"
+	}
+
+	return s, nil
+}
+
+func (s *Store) Location() string {
+	return s.location
+}
+
+func (s *Store) realpath(path string) string {
+	return s.prefixDir + path
+}
+
+func (s *Store) connect() error {
+	var client *azblob.Client
+	var err error
+
+	switch {
+	case s.connectionString != "":
+		client, err = azblob.NewClientFromConnectionString(s.connectionString, nil)
+	case s.accountKey != "":
+		var cred *azblob.SharedKeyCredential
+		cred, err = azblob.NewSharedKeyCredential(s.accountName, s.accountKey)
+		if err == nil {
+			client, err = azblob.NewClientWithSharedKeyCredential(s.serviceURL, cred, nil)
+		}
+	default:
+		client, err = azblob.NewClientWithNoCredential(s.serviceURL+"?"+s.sasToken, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("create azure client: %w", err)
+	}
+
+	s.client = client.ServiceClient().NewContainerClient(s.container)
+	return nil
+}
+
+func (s *Store) Create(ctx context.Context, config []byte) error {
+	if err := s.connect(); err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+
+	_, err := s.client.Create(s.ctx, nil)
+	if err != nil && !bloberror.HasCode(err, bloberror.ContainerAlreadyExists) {
+		return fmt.Errorf("create container: %w", err)
+	}
+
+	_, err = s.client.NewBlobClient(s.realpath("CONFIG")).GetProperties(s.ctx, nil)
+	if err == nil {
+		return fmt.Errorf("container already initialized")
+	} else if !bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return fmt.Errorf("stat blob CONFIG: %w", err)
+	}
+
+	if _, err := s.put(s.realpath("CONFIG"), bytes.NewReader(config)); err != nil {
+		return fmt.Errorf("put blob CONFIG: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) Open(ctx context.Context) ([]byte, error) {
+	if err := s.connect(); err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+
+	rd, err := s.get(s.realpath("CONFIG"), nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.ContainerNotFound) {
+			return nil, fmt.Errorf("container does not exist")
+		}
+		return nil, fmt.Errorf("get blob CONFIG: %w", err)
+	}
+	defer rd.Close()
+
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, fmt.Errorf("read blob CONFIG: %w", err)
+	}
+	return data, nil
+}
+
+func (s *Store) Close() error {
+	return nil
+}
+
+func (s *Store) Mode() storage.Mode {
+	return storage.ModeRead | storage.ModeWrite
+}
+
+func (s *Store) Size() int64 {
+	prefix := s.prefixDir
+	pager := s.client.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{
+		Prefix: &prefix,
+	})
+
+	var size int64
+	for pager.More() {
+		page, err := pager.NextPage(s.ctx)
+		if err != nil {
+			return -1
+		}
+		for _, item := range page.Segment.BlobItems {
+			if item.Properties != nil && item.Properties.ContentLength != nil {
+				size += *item.Properties.ContentLength
+			}
+		}
+	}
+	return size
+}
+
+// put uploads rd as a block blob, staging it in blocks of BLOCK_SIZE so
+// that large objects never need to be held in memory at once.
+func (s *Store) put(name string, rd io.Reader) (int64, error) {
+	bb := s.client.NewBlockBlobClient(name)
+
+	var blockIDs []string
+	var size int64
+	buf := make([]byte, BLOCK_SIZE)
+	for {
+		n, err := io.ReadFull(rd, buf)
+		if len(blockIDs) == 0 && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+			// small enough to fit in a single request
+			body := streaming.NopCloser(bytes.NewReader(buf[:n]))
+			if _, err := bb.Upload(s.ctx, body, nil); err != nil {
+				return 0, fmt.Errorf("upload blob: %w", err)
+			}
+			return int64(n), nil
+		}
+		if n > 0 {
+			blockID := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%08d", len(blockIDs))))
+			body := streaming.NopCloser(bytes.NewReader(buf[:n]))
+			if _, err := bb.StageBlock(s.ctx, blockID, body, nil); err != nil {
+				return 0, fmt.Errorf("stage block: %w", err)
+			}
+			blockIDs = append(blockIDs, blockID)
+			size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := bb.CommitBlockList(s.ctx, blockIDs, nil); err != nil {
+		return 0, fmt.Errorf("commit block list: %w", err)
+	}
+	return size, nil
+}
+
+func (s *Store) get(name string, httpRange *blob.HTTPRange) (io.ReadCloser, error) {
+	opts := &blob.DownloadStreamOptions{}
+	if httpRange != nil {
+		opts.Range = *httpRange
+	}
+
+	resp, err := s.client.NewBlobClient(name).DownloadStream(s.ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *Store) delete(name string) error {
+	_, err := s.client.NewBlobClient(name).Delete(s.ctx, nil)
+	return err
+}
+
+// list returns the MACs encoded in the names of the blobs under prefix,
+// skipping the first skip bytes of the name past the prefix.
+func (s *Store) list(prefix string, skip int) ([]objects.MAC, error) {
+	prefix = s.realpath(prefix)
+	pager := s.client.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{
+		Prefix: &prefix,
+	})
+
+	ret := make([]objects.MAC, 0)
+	for pager.More() {
+		page, err := pager.NextPage(s.ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list blobs: %w", err)
+		}
+		for _, item := range page.Segment.BlobItems {
+			if item.Name == nil || len(*item.Name) < len(prefix)+skip {
+				continue
+			}
+			t, err := hex.DecodeString((*item.Name)[len(prefix)+skip:])
+			if err != nil {
+				return nil, fmt.Errorf("decode blob name: %w", err)
+			}
+			if len(t) != 32 {
+				continue
+			}
+			ret = append(ret, objects.MAC(t))
+		}
+	}
+	return ret, nil
+}
+
+// states
+func (s *Store) GetStates() ([]objects.MAC, error) {
+	return s.list("states/", 3)
+}
+
+func (s *Store) PutState(mac objects.MAC, rd io.Reader) (int64, error) {
+	return s.put(s.realpath(fmt.Sprintf("states/%02x/%016x", mac[0], mac)), rd)
+}
+
+func (s *Store) GetState(mac objects.MAC) (io.Reader, error) {
+	rd, err := s.get(s.realpath(fmt.Sprintf("states/%02x/%016x", mac[0], mac)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("get blob: %w", err)
+	}
+	return rd, nil
+}
+
+func (s *Store) DeleteState(mac objects.MAC) error {
+	if err := s.delete(s.realpath(fmt.Sprintf("states/%02x/%016x", mac[0], mac))); err != nil {
+		return fmt.Errorf("delete blob: %w", err)
+	}
+	return nil
+}
+
+// packfiles
+func (s *Store) GetPackfiles() ([]objects.MAC, error) {
+	return s.list("packfiles/", 3)
+}
+
+func (s *Store) PutPackfile(mac objects.MAC, rd io.Reader) (int64, error) {
+	return s.put(s.realpath(fmt.Sprintf("packfiles/%02x/%016x", mac[0], mac)), rd)
+}
+
+func (s *Store) GetPackfile(mac objects.MAC) (io.Reader, error) {
+	rd, err := s.get(s.realpath(fmt.Sprintf("packfiles/%02x/%016x", mac[0], mac)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("get blob: %w", err)
+	}
+	return rd, nil
+}
+
+func (s *Store) GetPackfileBlob(mac objects.MAC, offset uint64, length uint32) (io.Reader, error) {
+	rd, err := s.get(s.realpath(fmt.Sprintf("packfiles/%02x/%016x", mac[0], mac)), &blob.HTTPRange{
+		Offset: int64(offset),
+		Count:  int64(length),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get blob: %w", err)
+	}
+	return rd, nil
+}
+
+func (s *Store) DeletePackfile(mac objects.MAC) error {
+	if err := s.delete(s.realpath(fmt.Sprintf("packfiles/%02x/%016x", mac[0], mac))); err != nil {
+		return fmt.Errorf("delete blob: %w", err)
+	}
+	return nil
+}
+
+// locks
+func (s *Store) GetLocks() ([]objects.MAC, error) {
+	return s.list("locks/", 0)
+}
+
+func (s *Store) PutLock(lockID objects.MAC, rd io.Reader) (int64, error) {
+	return s.put(s.realpath(fmt.Sprintf("locks/%016x", lockID)), rd)
+}
+
+func (s *Store) GetLock(lockID objects.MAC) (io.Reader, error) {
+	rd, err := s.get(s.realpath(fmt.Sprintf("locks/%016x", lockID)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("get blob: %w", err)
+	}
+	return rd, nil
+}
+
+func (s *Store) DeleteLock(lockID objects.MAC) error {
+	if err := s.delete(s.realpath(fmt.Sprintf("locks/%016x", lockID))); err != nil {
+		return fmt.Errorf("delete blob: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package azure
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/storage"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/testing/storagetest"
+	"github.com/stretchr/testify/require"
+)
+
+// well-known credentials of the azurite emulator
+const (
+	azuriteAccount = "devstoreaccount1"
+	azuriteKey     = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+// TestAzureBackendSuite runs against an azurite emulator whose blob
+// endpoint is given in AZURITE_BLOB_ENDPOINT, for instance
+// http://127.0.0.1:10000/devstoreaccount1, and is skipped otherwise.
+func TestAzureBackendSuite(t *testing.T) {
+	endpoint := os.Getenv("AZURITE_BLOB_ENDPOINT")
+
+	storagetest.BackendTestSuite(t, func(t *testing.T) storage.Store {
+		if endpoint == "" {
+			return nil
+		}
+
+		ctx := appcontext.NewAppContext()
+		t.Cleanup(ctx.Close)
+
+		suffix := make([]byte, 8)
+		_, err := rand.Read(suffix)
+		require.NoError(t, err)
+
+		repo, err := NewStore(ctx, "azure", map[string]string{
+			"location":     "azure://plakar-" + hex.EncodeToString(suffix) + "/backups",
+			"account_name": azuriteAccount,
+			"account_key":  azuriteKey,
+			"endpoint":     endpoint,
+		})
+		require.NoError(t, err)
+
+		config, err := storage.NewConfiguration().ToBytes()
+		require.NoError(t, err)
+		require.NoError(t, repo.Create(ctx, config))
+
+		_, err = repo.Open(ctx)
+		require.NoError(t, err)
+		return repo
+	})
+}
+
+func TestAzureBackendConfig(t *testing.T) {
+	ctx := appcontext.NewAppContext()
+	defer ctx.Close()
+
+	repo, err := NewStore(ctx, "azure", map[string]string{
+		"location":     "azure://mycontainer",
+		"account_name": "myaccount",
+		"sas_token":    "?sv=2022-11-02&sig=x",
+		"prefix":       "/backups/",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "azure://mycontainer", repo.Location())
+	require.Equal(t, "mycontainer", repo.(*Store).container)
+	require.Equal(t, "backups/", repo.(*Store).prefixDir)
+	require.Equal(t, "sv=2022-11-02&sig=x", repo.(*Store).sasToken)
+	require.Equal(t, "https://myaccount.blob.core.windows.net/", repo.(*Store).serviceURL)
+
+	_, err = NewStore(ctx, "azure", map[string]string{
+		"location":     "s3://mycontainer",
+		"account_name": "myaccount",
+		"sas_token":    "sig=x",
+	})
+	require.Error(t, err)
+
+	_, err = NewStore(ctx, "azure", map[string]string{
+		"location":     "azure://mycontainer",
+		"container":    "othercontainer",
+		"account_name": "myaccount",
+		"sas_token":    "sig=x",
+	})
+	require.Error(t, err)
+
+	_, err = NewStore(ctx, "azure", map[string]string{
+		"location":     "azure://mycontainer",
+		"account_name": "myaccount",
+	})
+	require.Error(t, err)
+}
+
+// fakeBlobService implements the few block blob operations the store
+// relies on to upload and read back blobs.
+type fakeBlobService struct {
+	mu     sync.Mutex
+	staged map[string][]byte
+	blobs  map[string][]byte
+	ops    []string
+}
+
+func (f *fakeBlobService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	query := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodPut && query.Get("comp") == "block":
+		data, _ := io.ReadAll(r.Body)
+		f.staged[name+"/"+query.Get("blockid")] = data
+		f.ops = append(f.ops, fmt.Sprintf("stage %d", len(data)))
+		w.WriteHeader(http.StatusCreated)
+
+	case r.Method == http.MethodPut && query.Get("comp") == "blocklist":
+		var list struct {
+			Latest []string `xml:"Latest"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&list); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var data []byte
+		for _, id := range list.Latest {
+			block, ok := f.staged[name+"/"+id]
+			if !ok {
+				http.Error(w, "unknown block", http.StatusBadRequest)
+				return
+			}
+			data = append(data, block...)
+		}
+		f.blobs[name] = data
+		f.ops = append(f.ops, fmt.Sprintf("commit %d", len(list.Latest)))
+		w.WriteHeader(http.StatusCreated)
+
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.blobs[name] = data
+		f.ops = append(f.ops, fmt.Sprintf("upload %d", len(data)))
+		w.WriteHeader(http.StatusCreated)
+
+	case r.Method == http.MethodGet:
+		data, ok := f.blobs[name]
+		if !ok {
+			w.Header().Set("x-ms-error-code", "BlobNotFound")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		status := http.StatusOK
+		if rng := r.Header.Get("x-ms-range"); rng != "" {
+			var start, end int
+			fmt.Sscanf(rng, "bytes=%d-%d", &start, &end)
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+			data = data[start : end+1]
+			status = http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.WriteHeader(status)
+		w.Write(data)
+
+	default:
+		http.Error(w, "unsupported", http.StatusNotImplemented)
+	}
+}
+
+func TestAzureBackendPut(t *testing.T) {
+	service := &fakeBlobService{
+		staged: make(map[string][]byte),
+		blobs:  make(map[string][]byte),
+	}
+	ts := httptest.NewServer(service)
+	t.Cleanup(ts.Close)
+
+	ctx := appcontext.NewAppContext()
+	defer ctx.Close()
+
+	repo, err := NewStore(ctx, "azure", map[string]string{
+		"location":     "azure://mycontainer/backups",
+		"account_name": azuriteAccount,
+		"sas_token":    "sig=x",
+		"endpoint":     ts.URL + "/" + azuriteAccount,
+	})
+	require.NoError(t, err)
+	require.NoError(t, repo.(*Store).connect())
+
+	// two full blocks and a partial one are staged, then committed
+	data := make([]byte, 2*BLOCK_SIZE+123)
+	_, err = rand.Read(data)
+	require.NoError(t, err)
+
+	mac := objects.MAC{0x12, 0x34}
+	n, err := repo.PutPackfile(mac, bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), n)
+	require.Equal(t, []string{
+		fmt.Sprintf("stage %d", BLOCK_SIZE),
+		fmt.Sprintf("stage %d", BLOCK_SIZE),
+		"stage 123",
+		"commit 3",
+	}, service.ops)
+	require.Equal(t, data, service.blobs[fmt.Sprintf("%s/mycontainer/backups/packfiles/12/%016x", azuriteAccount, mac)])
+
+	rd, err := repo.GetPackfileBlob(mac, BLOCK_SIZE-2, 4)
+	require.NoError(t, err)
+	blob, err := io.ReadAll(rd)
+	require.NoError(t, err)
+	require.Equal(t, data[BLOCK_SIZE-2:BLOCK_SIZE+2], blob)
+
+	// a blob of exactly one block is staged too
+	service.ops = nil
+	_, err = repo.PutState(mac, bytes.NewReader(data[:BLOCK_SIZE]))
+	require.NoError(t, err)
+	require.Equal(t, []string{fmt.Sprintf("stage %d", BLOCK_SIZE), "commit 1"}, service.ops)
+
+	// smaller ones are sent in a single request
+	service.ops = nil
+	_, err = repo.PutLock(mac, strings.NewReader("lock"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"upload 4"}, service.ops)
+
+	rd, err = repo.GetLock(mac)
+	require.NoError(t, err)
+	lock, err := io.ReadAll(rd)
+	require.NoError(t, err)
+	require.Equal(t, "lock", string(lock))
+}
@@ -18,17 +18,43 @@ package fs
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"os"
+	"os/user"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/PlakarKorp/kloset/location"
 	"github.com/PlakarKorp/kloset/objects"
 	"github.com/PlakarKorp/kloset/snapshot/exporter"
 )
 
+const (
+	OwnershipByUID       = "by-uid"
+	OwnershipByName      = "by-name"
+	OwnershipCurrentUser = "current-user"
+)
+
+// ValidateOwnership returns an error if mode is not one of the
+// Ownership* modes.
+func ValidateOwnership(mode string) error {
+	switch mode {
+	case OwnershipByUID, OwnershipByName, OwnershipCurrentUser:
+		return nil
+	}
+	return fmt.Errorf("invalid ownership mode %q, expected %s, %s or %s",
+		mode, OwnershipByUID, OwnershipByName, OwnershipCurrentUser)
+}
+
 type FSExporter struct {
-	rootDir string
+	rootDir   string
+	ownership string
+
+	idsMtx sync.Mutex
+	uids   map[string]int
+	gids   map[string]int
 }
 
 func init() {
@@ -36,8 +62,19 @@ func init() {
 }
 
 func NewFSExporter(ctx context.Context, opts *exporter.Options, name string, config map[string]string) (exporter.Exporter, error) {
+	ownership := OwnershipByUID
+	if value, ok := config["ownership"]; ok {
+		if err := ValidateOwnership(value); err != nil {
+			return nil, err
+		}
+		ownership = value
+	}
+
 	return &FSExporter{
-		rootDir: strings.TrimPrefix(config["location"], "fs://"),
+		rootDir:   strings.TrimPrefix(config["location"], "fs://"),
+		ownership: ownership,
+		uids:      make(map[string]int),
+		gids:      make(map[string]int),
 	}, nil
 }
 
@@ -73,8 +110,11 @@ func (p *FSExporter) SetPermissions(pathname string, fileinfo *objects.FileInfo)
 	if err := os.Chmod(pathname, fileinfo.Mode()); err != nil {
 		return err
 	}
-	if os.Getuid() == 0 {
-		if err := os.Chown(pathname, int(fileinfo.Uid()), int(fileinfo.Gid())); err != nil {
+	// files are created by the process, so they already belong to
+	// the current user if that's what was asked for.
+	if os.Getuid() == 0 && p.ownership != OwnershipCurrentUser {
+		uid, gid := p.owner(fileinfo)
+		if err := os.Chown(pathname, uid, gid); err != nil {
 			return err
 		}
 	}
@@ -84,6 +124,54 @@ func (p *FSExporter) SetPermissions(pathname string, fileinfo *objects.FileInfo)
 	return nil
 }
 
+// owner returns the uid and gid to restore fileinfo with.  In by-name
+// mode the username and groupname recorded at backup time are looked
+// up on this system, falling back to the numeric ids when they don't
+// exist here.
+func (p *FSExporter) owner(fileinfo *objects.FileInfo) (int, int) {
+	uid, gid := int(fileinfo.Uid()), int(fileinfo.Gid())
+	if p.ownership != OwnershipByName {
+		return uid, gid
+	}
+
+	p.idsMtx.Lock()
+	defer p.idsMtx.Unlock()
+
+	if name := fileinfo.Username(); name != "" {
+		id, ok := p.uids[name]
+		if !ok {
+			id = -1
+			if u, err := user.Lookup(name); err == nil {
+				if n, err := strconv.Atoi(u.Uid); err == nil {
+					id = n
+				}
+			}
+			p.uids[name] = id
+		}
+		if id != -1 {
+			uid = id
+		}
+	}
+
+	if name := fileinfo.Groupname(); name != "" {
+		id, ok := p.gids[name]
+		if !ok {
+			id = -1
+			if g, err := user.LookupGroup(name); err == nil {
+				if n, err := strconv.Atoi(g.Gid); err == nil {
+					id = n
+				}
+			}
+			p.gids[name] = id
+		}
+		if id != -1 {
+			gid = id
+		}
+	}
+
+	return uid, gid
+}
+
 func (p *FSExporter) Close() error {
 	return nil
 }
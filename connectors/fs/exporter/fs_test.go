@@ -3,6 +3,8 @@ package fs
 import (
 	"io"
 	"os"
+	"os/user"
+	"strconv"
 	"testing"
 
 	"github.com/PlakarKorp/kloset/objects"
@@ -59,3 +61,38 @@ func TestExporter(t *testing.T) {
 	err = exporterInstance.SetPermissions(tmpExportDir+"/dummy.txt", &objects.FileInfo{Lmode: 0644})
 	require.NoError(t, err)
 }
+
+func TestExporterOwnership(t *testing.T) {
+	appCtx := appcontext.NewAppContext()
+
+	_, err := exporter.NewExporter(appCtx.GetInner(), map[string]string{"location": "/tmp", "ownership": "bogus"})
+	require.Error(t, err)
+
+	current, err := user.Current()
+	require.NoError(t, err)
+	uid, err := strconv.Atoi(current.Uid)
+	require.NoError(t, err)
+
+	exp, err := exporter.NewExporter(appCtx.GetInner(), map[string]string{"location": "/tmp", "ownership": "by-name"})
+	require.NoError(t, err)
+	defer exp.Close()
+
+	fsexp := exp.(*FSExporter)
+
+	// known username, numeric id from the backup is ignored
+	gotUID, gotGID := fsexp.owner(&objects.FileInfo{Luid: 12345, Lgid: 54321, Lusername: current.Username})
+	require.Equal(t, uid, gotUID)
+	require.Equal(t, 54321, gotGID)
+
+	// unknown username, numeric id is kept
+	gotUID, _ = fsexp.owner(&objects.FileInfo{Luid: 12345, Lusername: "plakar-no-such-user"})
+	require.Equal(t, 12345, gotUID)
+
+	exp, err = exporter.NewExporter(appCtx.GetInner(), map[string]string{"location": "/tmp"})
+	require.NoError(t, err)
+	defer exp.Close()
+
+	gotUID, gotGID = exp.(*FSExporter).owner(&objects.FileInfo{Luid: 12345, Lgid: 54321, Lusername: current.Username})
+	require.Equal(t, 12345, gotUID)
+	require.Equal(t, 54321, gotGID)
+}
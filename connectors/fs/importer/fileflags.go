@@ -0,0 +1,24 @@
+//go:build !windows && !darwin
+
+package fs
+
+import (
+	"os"
+	"strings"
+)
+
+// flagHidden is not a filesystem flag, dotfiles are the only hidden
+// files here.
+const flagHidden = 0x00000001
+
+// excludableFlags maps importer options to the file flags they exclude.
+var excludableFlags = map[string]uint32{
+	"exclude_hidden": flagHidden,
+}
+
+func fileFlags(info os.FileInfo) uint32 {
+	if strings.HasPrefix(info.Name(), ".") {
+		return flagHidden
+	}
+	return 0
+}
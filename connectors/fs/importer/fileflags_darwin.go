@@ -0,0 +1,31 @@
+package fs
+
+import (
+	"os"
+	"strings"
+	"syscall"
+)
+
+const (
+	UF_IMMUTABLE = 0x00000002
+	UF_HIDDEN    = 0x00008000
+	SF_IMMUTABLE = 0x00020000
+)
+
+// excludableFlags maps importer options to the file flags they exclude.
+var excludableFlags = map[string]uint32{
+	"exclude_hidden":    UF_HIDDEN,
+	"exclude_immutable": UF_IMMUTABLE | SF_IMMUTABLE,
+}
+
+func fileFlags(info os.FileInfo) uint32 {
+	var flags uint32
+	if sb, ok := info.Sys().(*syscall.Stat_t); ok {
+		flags = sb.Flags
+	}
+	// dotfiles are hidden by the Finder too
+	if strings.HasPrefix(info.Name(), ".") {
+		flags |= UF_HIDDEN
+	}
+	return flags
+}
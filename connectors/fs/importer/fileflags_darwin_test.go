@@ -0,0 +1,60 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/stretchr/testify/require"
+)
+
+// hideFile sets the hidden flag on pathname and returns its name.
+func hideFile(t *testing.T, pathname string) string {
+	require.NoError(t, syscall.Chflags(pathname, UF_HIDDEN))
+	return filepath.Base(pathname)
+}
+
+func TestFSImporterExcludeDotfiles(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "visible.txt"), []byte("visible"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".dotfile"), []byte("hidden"), 0644))
+
+	names := scanNames(t, dir, map[string]string{"exclude_hidden": "true"})
+	require.Contains(t, names, "visible.txt")
+	require.NotContains(t, names, ".dotfile")
+}
+
+func TestFSImporterExcludeImmutable(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "mutable.txt"), []byte("mutable"), 0644))
+
+	immutable := filepath.Join(dir, "immutable.txt")
+	require.NoError(t, os.WriteFile(immutable, []byte("immutable"), 0644))
+	require.NoError(t, syscall.Chflags(immutable, UF_IMMUTABLE))
+	t.Cleanup(func() {
+		syscall.Chflags(immutable, 0)
+	})
+
+	names := scanNames(t, dir, map[string]string{})
+	require.Contains(t, names, "immutable.txt")
+
+	names = scanNames(t, dir, map[string]string{"exclude_immutable": "true"})
+	require.Contains(t, names, "mutable.txt")
+	require.NotContains(t, names, "immutable.txt")
+}
+
+func TestFSImporterUnsupportedFlags(t *testing.T) {
+	ctx := appcontext.NewAppContext()
+
+	for _, option := range []string{"exclude_system", "exclude_temporary"} {
+		_, err := NewFSImporter(ctx, ctx.ImporterOpts(), "fs", map[string]string{
+			"location": t.TempDir(),
+			option:     "true",
+		})
+		require.ErrorContains(t, err, option+" is not supported")
+	}
+}
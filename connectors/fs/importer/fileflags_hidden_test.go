@@ -0,0 +1,61 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/stretchr/testify/require"
+)
+
+// scanNames returns the base names of the files scanned below dir.
+func scanNames(t *testing.T, dir string, config map[string]string) []string {
+	ctx := appcontext.NewAppContext()
+
+	config["location"] = dir
+	imp, err := NewFSImporter(ctx, ctx.ImporterOpts(), "fs", config)
+	require.NoError(t, err)
+	defer imp.Close()
+
+	scanChan, err := imp.Scan()
+	require.NoError(t, err)
+
+	names := []string{}
+	for record := range scanChan {
+		require.Nil(t, record.Error)
+		if record.Record.IsXattr {
+			continue
+		}
+		if record.Record.Reader != nil {
+			record.Record.Reader.Close()
+		}
+		names = append(names, filepath.Base(record.Record.Pathname))
+	}
+	return names
+}
+
+func TestFSImporterExcludeHidden(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "visible.txt"), []byte("visible"), 0644))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hidden.txt"), []byte("hidden"), 0644))
+	hiddenFile := hideFile(t, filepath.Join(dir, "hidden.txt"))
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "hiddendir"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hiddendir", "inner.txt"), []byte("inner"), 0644))
+	hiddenDir := hideFile(t, filepath.Join(dir, "hiddendir"))
+
+	names := scanNames(t, dir, map[string]string{})
+	require.Contains(t, names, "visible.txt")
+	require.Contains(t, names, hiddenFile)
+	require.Contains(t, names, hiddenDir)
+	require.Contains(t, names, "inner.txt")
+
+	names = scanNames(t, dir, map[string]string{"exclude_hidden": "true"})
+	require.Contains(t, names, "visible.txt")
+	require.NotContains(t, names, hiddenFile)
+	require.NotContains(t, names, hiddenDir)
+	require.NotContains(t, names, "inner.txt")
+}
@@ -0,0 +1,31 @@
+//go:build !windows && !darwin
+
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/stretchr/testify/require"
+)
+
+// hideFile turns pathname into a dotfile and returns its new name.
+func hideFile(t *testing.T, pathname string) string {
+	name := "." + filepath.Base(pathname)
+	require.NoError(t, os.Rename(pathname, filepath.Join(filepath.Dir(pathname), name)))
+	return name
+}
+
+func TestFSImporterUnsupportedFlags(t *testing.T) {
+	ctx := appcontext.NewAppContext()
+
+	for _, option := range []string{"exclude_system", "exclude_temporary", "exclude_immutable"} {
+		_, err := NewFSImporter(ctx, ctx.ImporterOpts(), "fs", map[string]string{
+			"location": t.TempDir(),
+			option:     "true",
+		})
+		require.ErrorContains(t, err, option+" is not supported")
+	}
+}
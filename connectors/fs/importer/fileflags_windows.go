@@ -0,0 +1,26 @@
+package fs
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	FILE_ATTRIBUTE_HIDDEN    = 0x00000002
+	FILE_ATTRIBUTE_SYSTEM    = 0x00000004
+	FILE_ATTRIBUTE_TEMPORARY = 0x00000100
+)
+
+// excludableFlags maps importer options to the file flags they exclude.
+var excludableFlags = map[string]uint32{
+	"exclude_hidden":    FILE_ATTRIBUTE_HIDDEN,
+	"exclude_system":    FILE_ATTRIBUTE_SYSTEM,
+	"exclude_temporary": FILE_ATTRIBUTE_TEMPORARY,
+}
+
+func fileFlags(info os.FileInfo) uint32 {
+	if attrs, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return attrs.FileAttributes
+	}
+	return 0
+}
@@ -0,0 +1,57 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/stretchr/testify/require"
+)
+
+func setAttributes(t *testing.T, pathname string, attrs uint32) {
+	p, err := syscall.UTF16PtrFromString(pathname)
+	require.NoError(t, err)
+	require.NoError(t, syscall.SetFileAttributes(p, attrs))
+}
+
+// hideFile sets the hidden attribute on pathname and returns its name.
+func hideFile(t *testing.T, pathname string) string {
+	setAttributes(t, pathname, FILE_ATTRIBUTE_HIDDEN)
+	return filepath.Base(pathname)
+}
+
+func TestFSImporterExcludeAttributes(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "normal.txt"), []byte("normal"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "system.txt"), []byte("system"), 0644))
+	setAttributes(t, filepath.Join(dir, "system.txt"), FILE_ATTRIBUTE_SYSTEM)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "temporary.txt"), []byte("temporary"), 0644))
+	setAttributes(t, filepath.Join(dir, "temporary.txt"), FILE_ATTRIBUTE_TEMPORARY)
+
+	names := scanNames(t, dir, map[string]string{})
+	require.Contains(t, names, "system.txt")
+	require.Contains(t, names, "temporary.txt")
+
+	names = scanNames(t, dir, map[string]string{"exclude_system": "true"})
+	require.Contains(t, names, "normal.txt")
+	require.NotContains(t, names, "system.txt")
+	require.Contains(t, names, "temporary.txt")
+
+	names = scanNames(t, dir, map[string]string{"exclude_temporary": "true"})
+	require.Contains(t, names, "normal.txt")
+	require.Contains(t, names, "system.txt")
+	require.NotContains(t, names, "temporary.txt")
+}
+
+func TestFSImporterUnsupportedFlags(t *testing.T) {
+	ctx := appcontext.NewAppContext()
+
+	_, err := NewFSImporter(ctx, ctx.ImporterOpts(), "fs", map[string]string{
+		"location":          t.TempDir(),
+		"exclude_immutable": "true",
+	})
+	require.ErrorContains(t, err, "exclude_immutable is not supported")
+}
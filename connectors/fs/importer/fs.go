@@ -23,9 +23,11 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/PlakarKorp/kloset/location"
 	"github.com/PlakarKorp/kloset/snapshot/importer"
@@ -41,8 +43,18 @@ type FSImporter struct {
 	gidToName map[uint64]string
 	mu        sync.RWMutex
 
-	nocrossfs bool
-	devno     uint64
+	nocrossfs    bool
+	devno        uint64
+	excludeFlags uint32
+}
+
+// fileFlagOptions are the importer options excluding files by flag,
+// not all of them are supported on every platform.
+var fileFlagOptions = []string{
+	"exclude_hidden",
+	"exclude_system",
+	"exclude_temporary",
+	"exclude_immutable",
 }
 
 func init() {
@@ -61,20 +73,33 @@ func NewFSImporter(appCtx context.Context, opts *importer.Options, name string,
 
 	nocrossfs, _ := strconv.ParseBool(config["dont_traverse_fs"])
 
+	var excludeFlags uint32
+	for _, option := range fileFlagOptions {
+		if exclude, _ := strconv.ParseBool(config[option]); !exclude {
+			continue
+		}
+		mask, ok := excludableFlags[option]
+		if !ok {
+			return nil, fmt.Errorf("%s is not supported on %s", option, runtime.GOOS)
+		}
+		excludeFlags |= mask
+	}
+
 	realpath, devno, err := realpathFollow(rootDir)
 	if err != nil {
 		return nil, err
 	}
 
 	return &FSImporter{
-		ctx:       appCtx,
-		opts:      opts,
-		rootDir:   rootDir,
-		realpath:  realpath,
-		uidToName: make(map[uint64]string),
-		gidToName: make(map[uint64]string),
-		nocrossfs: nocrossfs,
-		devno:     devno,
+		ctx:          appCtx,
+		opts:         opts,
+		rootDir:      rootDir,
+		realpath:     realpath,
+		uidToName:    make(map[uint64]string),
+		gidToName:    make(map[uint64]string),
+		nocrossfs:    nocrossfs,
+		devno:        devno,
+		excludeFlags: excludeFlags,
 	}, nil
 }
 
@@ -87,13 +112,15 @@ func (p *FSImporter) Type() string {
 }
 
 func (p *FSImporter) Scan() (<-chan *importer.ScanResult, error) {
-	results := make(chan *importer.ScanResult, 1000)
-	go p.walkDir_walker(results, 256)
+	const numWorkers = 256
+
+	results := make(chan *importer.ScanResult, numWorkers*2)
+	go p.walkDir_walker(results, numWorkers)
 	return results, nil
 }
 
-func (f *FSImporter) walkDir_walker(results chan<- *importer.ScanResult, numWorkers int) {
-	jobs := make(chan string, 1000) // Buffered channel to feed paths to workers
+func (f *FSImporter) walkDir_walker(results chan *importer.ScanResult, numWorkers int) {
+	jobs := make(chan string, numWorkers*4) // Buffered channel to feed paths to workers
 	var wg sync.WaitGroup
 	for range numWorkers {
 		wg.Add(1)
@@ -128,6 +155,26 @@ func (f *FSImporter) walkDir_walker(results chan<- *importer.ScanResult, numWork
 			}
 		}
 
+		if f.excludeFlags != 0 && path != f.realpath {
+			info, err := d.Info()
+			if err != nil {
+				results <- importer.NewScanError(path, err)
+				return nil
+			}
+			if fileFlags(info)&f.excludeFlags != 0 {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		// don't race ahead of the consumer, every queued path ends
+		// up buffered in memory as a scan result.
+		for len(results) > cap(results)*8/10 && f.ctx.Err() == nil {
+			time.Sleep(time.Millisecond)
+		}
+
 		jobs <- path
 		return nil
 	})
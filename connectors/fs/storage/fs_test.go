@@ -9,6 +9,7 @@ import (
 	"github.com/PlakarKorp/kloset/objects"
 	"github.com/PlakarKorp/kloset/storage"
 	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/testing/storagetest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -109,3 +110,23 @@ func TestFsBackend(t *testing.T) {
 	require.Equal(t, "test4", buf.String())
 
 }
+
+func TestFsBackendSuite(t *testing.T) {
+	storagetest.BackendTestSuite(t, func(t *testing.T) storage.Store {
+		ctx := appcontext.NewAppContext()
+		t.Cleanup(ctx.Close)
+
+		repo, err := NewStore(ctx, "fs", map[string]string{"location": t.TempDir() + "/repo"})
+		require.NoError(t, err)
+
+		config, err := storage.NewConfiguration().ToBytes()
+		require.NoError(t, err)
+		require.NoError(t, repo.Create(ctx, config))
+
+		_, err = repo.Open(ctx)
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+
+		return repo
+	})
+}
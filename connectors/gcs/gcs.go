@@ -0,0 +1,5 @@
+package gcs
+
+import (
+	_ "github.com/PlakarKorp/plakar/connectors/gcs/storage"
+)
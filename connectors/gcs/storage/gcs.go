@@ -0,0 +1,79 @@
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PlakarKorp/kloset/storage"
+	s3 "github.com/PlakarKorp/plakar/connectors/s3/storage"
+)
+
+const DEFAULT_ENDPOINT = "storage.googleapis.com"
+
+// Store talks to Google Cloud Storage through its XML API, which is
+// compatible with S3 and authenticated with HMAC keys, so that it shares
+// the object layout and client of the s3 backend.
+type Store struct {
+	*s3.Store
+
+	location string
+}
+
+func init() {
+	storage.Register("gcs", 0, NewStore)
+}
+
+func NewStore(ctx context.Context, proto string, storeConfig map[string]string) (storage.Store, error) {
+	location := storeConfig["location"]
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("parse location: %w", err)
+	}
+	if parsed.Scheme != "gcs" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid location %q, expected gcs://bucket[/prefix]", location)
+	}
+
+	bucket := parsed.Host
+	prefix := strings.Trim(parsed.Path, "/")
+	if value, ok := storeConfig["bucket"]; ok && value != bucket {
+		return nil, fmt.Errorf("bucket %q does not match location %q", value, location)
+	}
+	if value, ok := storeConfig["prefix"]; ok && prefix == "" {
+		prefix = strings.Trim(value, "/")
+	}
+
+	endpoint := DEFAULT_ENDPOINT
+	if value, ok := storeConfig["endpoint"]; ok {
+		endpoint = value
+	}
+
+	s3Location := "s3://" + endpoint + "/" + bucket
+	if prefix != "" {
+		s3Location += "/" + prefix
+	}
+
+	s3Config := map[string]string{
+		"location": s3Location,
+	}
+	for _, key := range []string{"access_key", "secret_access_key", "use_tls"} {
+		if value, ok := storeConfig[key]; ok {
+			s3Config[key] = value
+		}
+	}
+
+	store, err := s3.NewStore(ctx, "s3", s3Config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		Store:    store.(*s3.Store),
+		location: location,
+	}, nil
+}
+
+func (s *Store) Location() string {
+	return s.location
+}
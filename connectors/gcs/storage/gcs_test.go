@@ -0,0 +1,62 @@
+package gcs
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PlakarKorp/kloset/storage"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/testing/storagetest"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGCSBackendSuite(t *testing.T) {
+	storagetest.BackendTestSuite(t, func(t *testing.T) storage.Store {
+		ctx := appcontext.NewAppContext()
+		t.Cleanup(ctx.Close)
+
+		ts := httptest.NewServer(gofakes3.New(s3mem.New()).Server())
+		t.Cleanup(ts.Close)
+
+		repo, err := NewStore(ctx, "gcs", map[string]string{
+			"location":          "gcs://testbucket/backups",
+			"endpoint":          strings.TrimPrefix(ts.URL, "http://"),
+			"access_key":        "",
+			"secret_access_key": "",
+			"use_tls":           "false",
+		})
+		require.NoError(t, err)
+		require.Equal(t, "gcs://testbucket/backups", repo.Location())
+
+		config, err := storage.NewConfiguration().ToBytes()
+		require.NoError(t, err)
+		require.NoError(t, repo.Create(ctx, config))
+
+		_, err = repo.Open(ctx)
+		require.NoError(t, err)
+		return repo
+	})
+}
+
+func TestGCSBackendLocation(t *testing.T) {
+	ctx := appcontext.NewAppContext()
+	defer ctx.Close()
+
+	_, err := NewStore(ctx, "gcs", map[string]string{
+		"location":          "s3://testbucket",
+		"access_key":        "",
+		"secret_access_key": "",
+	})
+	require.Error(t, err)
+
+	_, err = NewStore(ctx, "gcs", map[string]string{
+		"location":          "gcs://testbucket",
+		"bucket":            "otherbucket",
+		"access_key":        "",
+		"secret_access_key": "",
+	})
+	require.Error(t, err)
+}
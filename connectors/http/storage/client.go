@@ -245,6 +245,43 @@ func (s *Store) GetPackfile(MAC objects.MAC) (io.Reader, error) {
 }
 
 func (s *Store) GetPackfileBlob(MAC objects.MAC, offset uint64, length uint32) (io.Reader, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/packfile/%x", s.location, MAC), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+uint64(length)-1))
+
+	client := &http.Client{}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// the server ignored the range and sent the whole packfile
+		if _, err := io.CopyN(io.Discard, res.Body, int64(offset)); err != nil {
+			return nil, err
+		}
+	case http.StatusNotFound:
+		// older servers don't serve raw packfiles, or the packfile
+		// is missing and the JSON endpoint reports it
+		return s.getPackfileBlobJSON(MAC, offset, length)
+	default:
+		msg, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(res.Body, data); err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(data), nil
+}
+
+func (s *Store) getPackfileBlobJSON(MAC objects.MAC, offset uint64, length uint32) (io.Reader, error) {
 	r, err := s.sendRequest("GET", "/packfile/blob", network.ReqGetPackfileBlob{
 		MAC:    MAC,
 		Offset: offset,
@@ -3,10 +3,12 @@ package http
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/PlakarKorp/plakar/appcontext"
 	"github.com/PlakarKorp/kloset/objects"
@@ -141,25 +143,14 @@ func (h *MyHandler) GetPackfiles(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
-func (h *MyHandler) GetPackfileBlob(w http.ResponseWriter, r *http.Request) error {
-	var reqGetPackfileBlob network.ReqGetPackfileBlob
-	if err := json.NewDecoder(r.Body).Decode(&reqGetPackfileBlob); err != nil {
-		return err
-	}
-
-	w.WriteHeader(http.StatusOK)
-	var resGetState network.ResGetState
+func (h *MyHandler) GetPackfileRange(w http.ResponseWriter, r *http.Request) {
 	for _, packfile := range h.packfiles {
-		if packfile.MAC == reqGetPackfileBlob.MAC {
-			resGetState.Data = packfile.data[reqGetPackfileBlob.Offset : reqGetPackfileBlob.Offset+uint64(reqGetPackfileBlob.Length)]
-			break
+		if fmt.Sprintf("%x", packfile.MAC) == r.PathValue("mac") {
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(packfile.data))
+			return
 		}
 	}
-
-	if err := json.NewEncoder(w).Encode(resGetState); err != nil {
-		return err
-	}
-	return nil
+	http.NotFound(w, r)
 }
 
 func (h *MyHandler) DeletePackfile(w http.ResponseWriter, r *http.Request) error {
@@ -217,7 +208,7 @@ func TestHttpBackend(t *testing.T) {
 	mux.Handle("DELETE /state", api.JSONAPIView(handler.DeleteState))
 	mux.Handle("PUT /packfile", api.JSONAPIView(handler.PutPackfile))
 	mux.Handle("GET /packfiles", api.JSONAPIView(handler.GetPackfiles))
-	mux.Handle("GET /packfile/blob", api.JSONAPIView(handler.GetPackfileBlob))
+	mux.HandleFunc("GET /packfile/{mac}", handler.GetPackfileRange)
 	mux.Handle("DELETE /packfile", api.JSONAPIView(handler.DeletePackfile))
 	mux.Handle("GET /packfile", api.JSONAPIView(handler.GetPackfile))
 
@@ -317,3 +308,67 @@ func TestHttpBackend(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "test4", buf.String())
 }
+
+func TestHTTPBackendPackfileRange(t *testing.T) {
+	data := []byte("header-blob1-blob2-trailer")
+	mac := objects.MAC{0x01, 0x02}
+
+	var requests int
+	var ranges []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /packfile/{mac}", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		ranges = append(ranges, r.Header.Get("Range"))
+		require.Equal(t, fmt.Sprintf("%x", mac), r.PathValue("mac"))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	})
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	ctx := appcontext.NewAppContext()
+	defer ctx.Close()
+
+	repo, err := NewStore(ctx, "http", map[string]string{"location": ts.URL})
+	require.NoError(t, err)
+
+	rd, err := repo.GetPackfileBlob(mac, 7, 5)
+	require.NoError(t, err)
+	blob, err := io.ReadAll(rd)
+	require.NoError(t, err)
+	require.Equal(t, "blob1", string(blob))
+
+	require.Equal(t, 1, requests)
+	require.Equal(t, []string{"bytes=7-11"}, ranges)
+}
+
+func TestHTTPBackendPackfileBlobFallback(t *testing.T) {
+	data := []byte("header-blob1-blob2-trailer")
+	mac := objects.MAC{0x01, 0x02}
+
+	// a server predating raw packfiles only has the JSON endpoint
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /packfile/blob", func(w http.ResponseWriter, r *http.Request) {
+		var req network.ReqGetPackfileBlob
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, mac, req.MAC)
+
+		end := req.Offset + uint64(req.Length)
+		json.NewEncoder(w).Encode(network.ResGetPackfileBlob{Data: data[req.Offset:end]})
+	})
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	ctx := appcontext.NewAppContext()
+	defer ctx.Close()
+
+	repo, err := NewStore(ctx, "http", map[string]string{"location": ts.URL})
+	require.NoError(t, err)
+
+	rd, err := repo.GetPackfileBlob(mac, 13, 5)
+	require.NoError(t, err)
+	blob, err := io.ReadAll(rd)
+	require.NoError(t, err)
+	require.Equal(t, "blob2", string(blob))
+}
@@ -26,6 +26,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/PlakarKorp/kloset/objects"
 	"github.com/PlakarKorp/kloset/storage"
@@ -48,6 +49,9 @@ type Store struct {
 
 	storageClass string
 
+	objectLock      minio.RetentionMode
+	objectRetention time.Duration
+
 	bufPool sync.Pool
 
 	putObjectOptions minio.PutObjectOptions
@@ -89,12 +93,31 @@ func NewStore(ctx context.Context, proto string, storeConfig map[string]string)
 		}
 	}
 
+	var objectLock minio.RetentionMode
+	if value, ok := storeConfig["object_lock"]; ok {
+		objectLock = minio.RetentionMode(strings.ToUpper(value))
+		if !objectLock.IsValid() {
+			return nil, fmt.Errorf("invalid object_lock value")
+		}
+	}
+
+	objectRetention := 30 * 24 * time.Hour
+	if value, ok := storeConfig["object_lock_retention_days"]; ok {
+		days, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || days == 0 {
+			return nil, fmt.Errorf("invalid object_lock_retention_days value")
+		}
+		objectRetention = time.Duration(days) * 24 * time.Hour
+	}
+
 	return &Store{
 		location:        storeConfig["location"],
 		accessKey:       accessKey,
 		secretAccessKey: secretAccessKey,
 		useSsl:          useSsl,
 		storageClass:    storageClass,
+		objectLock:      objectLock,
+		objectRetention: objectRetention,
 		ctx:             ctx,
 
 		bufPool: sync.Pool{
@@ -159,7 +182,9 @@ func (s *Store) Create(ctx context.Context, config []byte) error {
 		return fmt.Errorf("check if bucket exists: %w", err)
 	}
 	if !exists {
-		err = s.minioClient.MakeBucket(s.ctx, s.bucketName, minio.MakeBucketOptions{})
+		err = s.minioClient.MakeBucket(s.ctx, s.bucketName, minio.MakeBucketOptions{
+			ObjectLocking: s.objectLock != "",
+		})
 		if err != nil {
 			return fmt.Errorf("make bucket: %w", err)
 		}
@@ -337,7 +362,13 @@ func (s *Store) PutPackfile(mac objects.MAC, rd io.Reader) (int64, error) {
 		return 0, fmt.Errorf("read packfile: %w", err)
 	}
 
-	info, err := s.minioClient.PutObject(s.ctx, s.bucketName, s.realpath(fmt.Sprintf("packfiles/%02x/%016x", mac[0], mac)), buf, copied, s.putObjectOptions)
+	putObjectOptions := s.putObjectOptions
+	if s.objectLock != "" {
+		putObjectOptions.Mode = s.objectLock
+		putObjectOptions.RetainUntilDate = time.Now().Add(s.objectRetention)
+	}
+
+	info, err := s.minioClient.PutObject(s.ctx, s.bucketName, s.realpath(fmt.Sprintf("packfiles/%02x/%016x", mac[0], mac)), buf, copied, putObjectOptions)
 	if err != nil {
 		return 0, fmt.Errorf("put object: %w", err)
 	}
@@ -366,6 +397,16 @@ func (s *Store) GetPackfileBlob(mac objects.MAC, offset uint64, length uint32) (
 }
 
 func (s *Store) DeletePackfile(mac objects.MAC) error {
+	if s.objectLock != "" {
+		immutable, err := s.IsPackfileImmutable(mac)
+		if err != nil {
+			return err
+		}
+		if immutable {
+			return fmt.Errorf("refusing to delete packfile %x: object lock retention is still active", mac)
+		}
+	}
+
 	err := s.minioClient.RemoveObject(s.ctx, s.bucketName, s.realpath(fmt.Sprintf("packfiles/%02x/%016x", mac[0], mac)), minio.RemoveObjectOptions{})
 	if err != nil {
 		return fmt.Errorf("remove object: %w", err)
@@ -373,6 +414,22 @@ func (s *Store) DeletePackfile(mac objects.MAC) error {
 	return nil
 }
 
+// IsPackfileImmutable reports whether the packfile is protected by an
+// object lock retention that has not expired yet.
+func (s *Store) IsPackfileImmutable(mac objects.MAC) (bool, error) {
+	mode, until, err := s.minioClient.GetObjectRetention(s.ctx, s.bucketName, s.realpath(fmt.Sprintf("packfiles/%02x/%016x", mac[0], mac)), "")
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchObjectLockConfiguration" {
+			return false, nil
+		}
+		return false, fmt.Errorf("get object retention: %w", err)
+	}
+	if mode == nil || !mode.IsValid() || until == nil {
+		return false, nil
+	}
+	return until.After(time.Now()), nil
+}
+
 func (s *Store) GetLocks() ([]objects.MAC, error) {
 	prefix := s.realpath("locks/")
 	prefixSize := len(prefix)
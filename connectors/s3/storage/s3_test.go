@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/PlakarKorp/plakar/appcontext"
 	"github.com/PlakarKorp/kloset/objects"
@@ -120,3 +124,92 @@ func TestS3Backend(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "test4", buf.String())
 }
+
+// retentionServer records the retention of the objects put with an object
+// lock and answers the retention requests gofakes3 does not implement.
+type retentionServer struct {
+	http.Handler
+
+	mu        sync.Mutex
+	retention map[string]time.Time
+}
+
+func (s *retentionServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, ok := r.URL.Query()["retention"]; ok && r.Method == http.MethodGet {
+		s.mu.Lock()
+		until, ok := s.retention[r.URL.Path]
+		s.mu.Unlock()
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchObjectLockConfiguration</Code></Error>`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprintf(w, `<Retention><Mode>GOVERNANCE</Mode><RetainUntilDate>%s</RetainUntilDate></Retention>`,
+			until.UTC().Format(time.RFC3339))
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		if value := r.Header.Get("X-Amz-Object-Lock-Retain-Until-Date"); value != "" {
+			until, err := time.Parse(time.RFC3339, value)
+			if err == nil {
+				s.mu.Lock()
+				s.retention[r.URL.Path] = until
+				s.mu.Unlock()
+			}
+		}
+	}
+	s.Handler.ServeHTTP(w, r)
+}
+
+func TestS3BackendObjectLock(t *testing.T) {
+	ctx := appcontext.NewAppContext()
+	defer ctx.Close()
+
+	faker := gofakes3.New(s3mem.New())
+	server := &retentionServer{Handler: faker.Server(), retention: make(map[string]time.Time)}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	repo, err := NewStore(ctx, "s3", map[string]string{
+		"location":          ts.URL + "/testbucket",
+		"access_key":        "",
+		"secret_access_key": "",
+		"use_tls":           "false",
+		"object_lock":       "governance",
+	})
+	require.NoError(t, err)
+
+	config := storage.NewConfiguration()
+	serializedConfig, err := config.ToBytes()
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(ctx, serializedConfig))
+
+	locked := objects.MAC{0x50, 0x60}
+	expired := objects.MAC{0x60, 0x70}
+	_, err = repo.PutPackfile(locked, bytes.NewReader([]byte("locked")))
+	require.NoError(t, err)
+	_, err = repo.PutPackfile(expired, bytes.NewReader([]byte("expired")))
+	require.NoError(t, err)
+
+	server.mu.Lock()
+	require.Len(t, server.retention, 2)
+	for path := range server.retention {
+		if strings.HasSuffix(path, fmt.Sprintf("%016x", expired)) {
+			server.retention[path] = time.Now().Add(-time.Hour)
+		}
+	}
+	server.mu.Unlock()
+
+	err = repo.DeletePackfile(locked)
+	require.ErrorContains(t, err, "object lock retention is still active")
+
+	require.NoError(t, repo.DeletePackfile(expired))
+
+	packfiles, err := repo.GetPackfiles()
+	require.NoError(t, err)
+	require.Len(t, packfiles, 1)
+	require.Equal(t, locked, packfiles[0])
+}
@@ -96,6 +96,9 @@ func (p *SFTPExporter) SetPermissions(pathname string, fileinfo *objects.FileInf
 			return err
 		}
 	}
+	if err := p.client.Chtimes(pathname, fileinfo.ModTime(), fileinfo.ModTime()); err != nil {
+		return err
+	}
 	return nil
 }
 
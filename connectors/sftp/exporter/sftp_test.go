@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/PlakarKorp/kloset/objects"
 	"github.com/PlakarKorp/kloset/snapshot/exporter"
@@ -88,9 +89,21 @@ func TestExporter(t *testing.T) {
 
 	// Test setting permissions
 	fileInfo := &objects.FileInfo{
-		Lmode: 0644,
+		Lmode:    0640,
+		LmodTime: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
 	}
 	if err := exporter.SetPermissions("file1.txt", fileInfo); err != nil {
 		t.Errorf("Failed to set permissions: %v", err)
 	}
+
+	st, err := exporter.(*SFTPExporter).client.Stat("file1.txt")
+	if err != nil {
+		t.Fatalf("Failed to stat file1.txt: %v", err)
+	}
+	if st.Mode().Perm() != 0640 {
+		t.Errorf("Expected mode 0640, got %o", st.Mode().Perm())
+	}
+	if !st.ModTime().Equal(fileInfo.ModTime()) {
+		t.Errorf("Expected modification time %s, got %s", fileInfo.ModTime(), st.ModTime())
+	}
 }
@@ -22,8 +22,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"runtime"
 	"strings"
-	"sync"
 
 	"github.com/PlakarKorp/kloset/location"
 	"github.com/PlakarKorp/kloset/objects"
@@ -37,8 +37,10 @@ import (
 type Store struct {
 	backend string
 
-	conn    *sql.DB
-	wrMutex sync.Mutex
+	// conn is a pool of connections used for reads, wrConn a single
+	// connection through which all writes are serialized.
+	conn   *sql.DB
+	wrConn *sql.DB
 
 	Repository string
 	location   string
@@ -64,25 +66,53 @@ func (s *Store) Location() string {
 	return s.location
 }
 
+// pragmas are applied to every connection of the pools: WAL lets the
+// readers proceed while a write is in progress, and synchronous=NORMAL
+// only syncs the WAL at checkpoints, which is safe in that mode.
+var pragmas = []string{
+	"busy_timeout(2000)",
+	"journal_mode(WAL)",
+	"synchronous(NORMAL)",
+}
+
 func (s *Store) connect(addr string) error {
-	conn, err := sql.Open(s.backend, addr)
+	if s.conn != nil {
+		return nil
+	}
+
+	dsn := addr
+	for i, pragma := range pragmas {
+		if i == 0 && !strings.Contains(addr, "?") {
+			dsn += "?"
+		} else {
+			dsn += "&"
+		}
+		dsn += "_pragma=" + pragma
+	}
+
+	wrConn, err := sql.Open(s.backend, dsn)
 	if err != nil {
 		return err
 	}
-	s.conn = conn
+	wrConn.SetMaxOpenConns(1)
 
-	if s.backend == "sqlite" {
-		_, err = s.conn.Exec("PRAGMA journal_mode=WAL;")
-		if err != nil {
-			return nil
-		}
-		_, err = s.conn.Exec("PRAGMA busy_timeout=2000;")
-		if err != nil {
-			return nil
-		}
+	// the first connection switches the database to WAL mode, which is
+	// persistent, before the readers open theirs.
+	if err := wrConn.Ping(); err != nil {
+		wrConn.Close()
+		return err
+	}
 
+	conn, err := sql.Open(s.backend, dsn)
+	if err != nil {
+		wrConn.Close()
+		return err
 	}
+	conn.SetMaxOpenConns(runtime.NumCPU())
+	conn.SetMaxIdleConns(runtime.NumCPU())
 
+	s.conn = conn
+	s.wrConn = wrConn
 	return nil
 }
 
@@ -93,7 +123,7 @@ func (s *Store) Create(ctx context.Context, config []byte) error {
 		return err
 	}
 
-	statement, err := s.conn.Prepare(`CREATE TABLE IF NOT EXISTS configuration (
+	statement, err := s.wrConn.Prepare(`CREATE TABLE IF NOT EXISTS configuration (
 		value	BLOB
 	);`)
 	if err != nil {
@@ -102,7 +132,7 @@ func (s *Store) Create(ctx context.Context, config []byte) error {
 	defer statement.Close()
 	statement.Exec()
 
-	statement, err = s.conn.Prepare(`CREATE TABLE IF NOT EXISTS states (
+	statement, err = s.wrConn.Prepare(`CREATE TABLE IF NOT EXISTS states (
 		mac	VARCHAR(64) NOT NULL PRIMARY KEY,
 		data		BLOB
 	);`)
@@ -112,7 +142,7 @@ func (s *Store) Create(ctx context.Context, config []byte) error {
 	defer statement.Close()
 	statement.Exec()
 
-	statement, err = s.conn.Prepare(`CREATE TABLE IF NOT EXISTS packfiles (
+	statement, err = s.wrConn.Prepare(`CREATE TABLE IF NOT EXISTS packfiles (
 		mac	VARCHAR(64) NOT NULL PRIMARY KEY,
 		data		BLOB
 	);`)
@@ -122,7 +152,7 @@ func (s *Store) Create(ctx context.Context, config []byte) error {
 	defer statement.Close()
 	statement.Exec()
 
-	statement, err = s.conn.Prepare(`CREATE TABLE IF NOT EXISTS locks (
+	statement, err = s.wrConn.Prepare(`CREATE TABLE IF NOT EXISTS locks (
 		mac	VARCHAR(64) NOT NULL PRIMARY KEY,
 		data		BLOB
 	);`)
@@ -132,7 +162,7 @@ func (s *Store) Create(ctx context.Context, config []byte) error {
 	defer statement.Close()
 	statement.Exec()
 
-	statement, err = s.conn.Prepare(`INSERT INTO configuration(value) VALUES(?)`)
+	statement, err = s.wrConn.Prepare(`INSERT INTO configuration(value) VALUES(?)`)
 	if err != nil {
 		return err
 	}
@@ -163,7 +193,14 @@ func (s *Store) Open(ctx context.Context) ([]byte, error) {
 }
 
 func (s *Store) Close() error {
-	return nil
+	if s.conn == nil {
+		return nil
+	}
+
+	err := errors.Join(s.conn.Close(), s.wrConn.Close())
+	s.conn = nil
+	s.wrConn = nil
+	return err
 }
 
 func (s *Store) Mode() storage.Mode {
@@ -202,15 +239,13 @@ func (s *Store) PutState(mac objects.MAC, rd io.Reader) (int64, error) {
 		return 0, err
 	}
 
-	statement, err := s.conn.Prepare(`INSERT INTO states (mac, data) VALUES(?, ?)`)
+	statement, err := s.wrConn.Prepare(`INSERT INTO states (mac, data) VALUES(?, ?)`)
 	if err != nil {
 		return 0, err
 	}
 	defer statement.Close()
 
-	s.wrMutex.Lock()
 	_, err = statement.Exec(mac[:], data)
-	s.wrMutex.Unlock()
 	if err != nil {
 		var sqliteErr *sqlite.Error
 		if !errors.As(err, &sqliteErr) {
@@ -234,15 +269,13 @@ func (s *Store) GetState(mac objects.MAC) (io.Reader, error) {
 }
 
 func (s *Store) DeleteState(mac objects.MAC) error {
-	statement, err := s.conn.Prepare(`DELETE FROM states WHERE mac=?`)
+	statement, err := s.wrConn.Prepare(`DELETE FROM states WHERE mac=?`)
 	if err != nil {
 		return err
 	}
 	defer statement.Close()
 
-	s.wrMutex.Lock()
 	_, err = statement.Exec(mac[:])
-	s.wrMutex.Unlock()
 	if err != nil {
 		// if err is that it's already present, we should discard err and assume a concurrent write
 		return err
@@ -278,15 +311,13 @@ func (s *Store) PutPackfile(mac objects.MAC, rd io.Reader) (int64, error) {
 		return 0, err
 	}
 
-	statement, err := s.conn.Prepare(`INSERT INTO packfiles (mac, data) VALUES(?, ?)`)
+	statement, err := s.wrConn.Prepare(`INSERT INTO packfiles (mac, data) VALUES(?, ?)`)
 	if err != nil {
 		return 0, err
 	}
 	defer statement.Close()
 
-	s.wrMutex.Lock()
 	_, err = statement.Exec(mac[:], data)
-	s.wrMutex.Unlock()
 	if err != nil {
 		var sqliteErr *sqlite.Error
 		if !errors.As(err, &sqliteErr) {
@@ -322,15 +353,13 @@ func (s *Store) GetPackfileBlob(mac objects.MAC, offset uint64, length uint32) (
 }
 
 func (s *Store) DeletePackfile(mac objects.MAC) error {
-	statement, err := s.conn.Prepare(`DELETE FROM packfiles WHERE mac=?`)
+	statement, err := s.wrConn.Prepare(`DELETE FROM packfiles WHERE mac=?`)
 	if err != nil {
 		return err
 	}
 	defer statement.Close()
 
-	s.wrMutex.Lock()
 	_, err = statement.Exec(mac[:])
-	s.wrMutex.Unlock()
 	if err != nil {
 		// if err is that it's already present, we should discard err and assume a concurrent write
 		return err
@@ -374,15 +403,13 @@ func (s *Store) PutLock(lockID objects.MAC, rd io.Reader) (int64, error) {
 		return 0, err
 	}
 
-	statement, err := s.conn.Prepare(`INSERT INTO locks (mac, data) VALUES(?, ?)`)
+	statement, err := s.wrConn.Prepare(`INSERT INTO locks (mac, data) VALUES(?, ?)`)
 	if err != nil {
 		return 0, err
 	}
 	defer statement.Close()
 
-	s.wrMutex.Lock()
 	_, err = statement.Exec(hex.EncodeToString(lockID[:]), data)
-	s.wrMutex.Unlock()
 	if err != nil {
 		var sqliteErr *sqlite.Error
 		if !errors.As(err, &sqliteErr) {
@@ -406,15 +433,13 @@ func (s *Store) GetLock(lockID objects.MAC) (io.Reader, error) {
 }
 
 func (s *Store) DeleteLock(lockID objects.MAC) error {
-	statement, err := s.conn.Prepare(`DELETE FROM locks WHERE mac=?`)
+	statement, err := s.wrConn.Prepare(`DELETE FROM locks WHERE mac=?`)
 	if err != nil {
 		return err
 	}
 	defer statement.Close()
 
-	s.wrMutex.Lock()
 	_, err = statement.Exec(hex.EncodeToString(lockID[:]))
-	s.wrMutex.Unlock()
 	if err != nil {
 		// if err is that it's already present, we should discard err and assume a concurrent write
 		return err
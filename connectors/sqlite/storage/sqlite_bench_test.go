@@ -0,0 +1,118 @@
+package database
+
+import (
+	"bytes"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/storage"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/stretchr/testify/require"
+)
+
+func benchmarkStore(b *testing.B) storage.Store {
+	ctx := appcontext.NewAppContext()
+	b.Cleanup(ctx.Close)
+
+	store, err := NewStore(ctx, "sqlite", map[string]string{"location": b.TempDir() + "/repo.db"})
+	require.NoError(b, err)
+
+	config, err := storage.NewConfiguration().ToBytes()
+	require.NoError(b, err)
+	require.NoError(b, store.Create(ctx, config))
+	_, err = store.Open(ctx)
+	require.NoError(b, err)
+	b.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+func BenchmarkPutPackfile(b *testing.B) {
+	store := benchmarkStore(b)
+	data := bytes.Repeat([]byte{0xaa}, 64*1024)
+
+	var n atomic.Uint64
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := n.Add(1)
+			mac := objects.MAC{byte(i), byte(i >> 8), byte(i >> 16), byte(i >> 24)}
+			if _, err := store.PutPackfile(mac, bytes.NewReader(data)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkGetPackfileBlob(b *testing.B) {
+	store := benchmarkStore(b)
+	data := bytes.Repeat([]byte{0xaa}, 1024*1024)
+
+	mac := objects.MAC{0x01}
+	_, err := store.PutPackfile(mac, bytes.NewReader(data))
+	require.NoError(b, err)
+
+	b.SetBytes(4096)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			rd, err := store.GetPackfileBlob(mac, 8192, 4096)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := io.Copy(io.Discard, rd); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkGetPackfileBlobWhileWriting measures the readers while a
+// writer keeps storing packfiles.
+func BenchmarkGetPackfileBlobWhileWriting(b *testing.B) {
+	store := benchmarkStore(b)
+	data := bytes.Repeat([]byte{0xaa}, 1024*1024)
+
+	mac := objects.MAC{0x01}
+	_, err := store.PutPackfile(mac, bytes.NewReader(data))
+	require.NoError(b, err)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			mac := objects.MAC{0x02, byte(i), byte(i >> 8), byte(i >> 16)}
+			if _, err := store.PutPackfile(mac, bytes.NewReader(data[:64*1024])); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	}()
+
+	b.SetBytes(4096)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			rd, err := store.GetPackfileBlob(mac, 8192, 4096)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := io.Copy(io.Discard, rd); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.StopTimer()
+
+	close(done)
+	<-stopped
+}
@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"io"
 	"os"
+	"sync"
 	"testing"
 
 	"github.com/PlakarKorp/plakar/appcontext"
 	"github.com/PlakarKorp/kloset/objects"
 	"github.com/PlakarKorp/kloset/storage"
+	"github.com/PlakarKorp/plakar/testing/storagetest"
 	"github.com/stretchr/testify/require"
 
 	_ "modernc.org/sqlite"
@@ -42,8 +44,14 @@ func TestDatabaseBackend(t *testing.T) {
 	require.NoError(t, err)
 	//	require.Equal(t, repo.Configuration().Version, versioning.FromString(storage.VERSION))
 
+	// closing releases the connections, opening again reconnects
 	err = repo.Close()
 	require.NoError(t, err)
+	require.Nil(t, repo.(*Store).conn)
+
+	_, err = repo.Open(ctx)
+	require.NoError(t, err)
+	defer repo.Close()
 
 	// states
 	mac1 := objects.MAC{0x10, 0x20}
@@ -112,3 +120,94 @@ func TestDatabaseBackend(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "test4", buf.String())
 }
+
+func TestDatabaseBackendConcurrentReads(t *testing.T) {
+	ctx := appcontext.NewAppContext()
+	defer ctx.Close()
+
+	store, err := NewStore(ctx, "sqlite", map[string]string{"location": t.TempDir() + "/repo.db"})
+	require.NoError(t, err)
+
+	config, err := storage.NewConfiguration().ToBytes()
+	require.NoError(t, err)
+	require.NoError(t, store.Create(ctx, config))
+	_, err = store.Open(ctx)
+	require.NoError(t, err)
+	defer store.Close()
+
+	var journalMode string
+	err = store.(*Store).conn.QueryRow("PRAGMA journal_mode").Scan(&journalMode)
+	require.NoError(t, err)
+	require.Equal(t, "wal", journalMode)
+
+	mac := objects.MAC{0x01}
+	_, err = store.PutPackfile(mac, bytes.NewReader([]byte("0123456789")))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+
+	// keep the writer busy while the readers fetch blobs
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 32; i++ {
+			if _, err := store.PutPackfile(objects.MAC{0x02, byte(i)}, bytes.NewReader([]byte("data"))); err != nil {
+				errs <- err
+			}
+		}
+	}()
+
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 32; j++ {
+				rd, err := store.GetPackfileBlob(mac, 2, 4)
+				if err != nil {
+					errs <- err
+					return
+				}
+				data, err := io.ReadAll(rd)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if string(data) != "2345" {
+					errs <- io.ErrUnexpectedEOF
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	packfiles, err := store.GetPackfiles()
+	require.NoError(t, err)
+	require.Len(t, packfiles, 33)
+}
+
+func TestDatabaseBackendSuite(t *testing.T) {
+	storagetest.BackendTestSuite(t, func(t *testing.T) storage.Store {
+		ctx := appcontext.NewAppContext()
+		t.Cleanup(ctx.Close)
+
+		repo, err := NewStore(ctx, "sqlite", map[string]string{"location": t.TempDir() + "/repo.db"})
+		require.NoError(t, err)
+
+		config, err := storage.NewConfiguration().ToBytes()
+		require.NoError(t, err)
+		require.NoError(t, repo.Create(ctx, config))
+
+		_, err = repo.Open(ctx)
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+
+		return repo
+	})
+}
@@ -0,0 +1,131 @@
+package stdio
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/snapshot/exporter"
+)
+
+// TarExporter writes the restored files as a PAX tar stream on the
+// standard output.
+//
+// The metadata of a file is only known once SetPermissions is called,
+// after its content was stored, so the content is spooled to a temporary
+// file until then. Entries are written one at a time as restores run
+// concurrently.
+//
+// Hard links are not supported: the restore links the other names of a
+// file with os.Link rather than through the exporter, so they never
+// reach the archive and fail instead.
+type TarExporter struct {
+	appCtx context.Context
+
+	mu      sync.Mutex
+	tw      *tar.Writer
+	pending map[string]*os.File
+}
+
+func init() {
+	exporter.Register("stdio", 0, NewTarExporter)
+}
+
+func NewTarExporter(appCtx context.Context, opts *exporter.Options, name string, config map[string]string) (exporter.Exporter, error) {
+	return &TarExporter{
+		appCtx:  appCtx,
+		tw:      tar.NewWriter(opts.Stdout),
+		pending: make(map[string]*os.File),
+	}, nil
+}
+
+func (p *TarExporter) Root() string {
+	return "/"
+}
+
+func (p *TarExporter) CreateDirectory(pathname string) error {
+	// directories are written once their permissions are known
+	return nil
+}
+
+func (p *TarExporter) StoreFile(pathname string, fp io.Reader, size int64) error {
+	spool, err := os.CreateTemp("", "plakar-tar-")
+	if err != nil {
+		return err
+	}
+	os.Remove(spool.Name())
+
+	if _, err := io.Copy(spool, fp); err != nil {
+		spool.Close()
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if previous, ok := p.pending[pathname]; ok {
+		previous.Close()
+	}
+	p.pending[pathname] = spool
+	return nil
+}
+
+func (p *TarExporter) SetPermissions(pathname string, fileinfo *objects.FileInfo) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	header := &tar.Header{
+		Name:    strings.TrimPrefix(pathname, "/"),
+		Mode:    int64(fileinfo.Mode().Perm()),
+		Uid:     int(fileinfo.Uid()),
+		Gid:     int(fileinfo.Gid()),
+		Uname:   fileinfo.Username(),
+		Gname:   fileinfo.Groupname(),
+		ModTime: fileinfo.ModTime(),
+		Format:  tar.FormatPAX,
+	}
+
+	if fileinfo.Mode().IsDir() {
+		header.Typeflag = tar.TypeDir
+		header.Name += "/"
+		return p.tw.WriteHeader(header)
+	}
+
+	spool, ok := p.pending[pathname]
+	if !ok {
+		return fmt.Errorf("no content stored for %s", pathname)
+	}
+	delete(p.pending, pathname)
+	defer spool.Close()
+
+	size, err := spool.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	header.Typeflag = tar.TypeReg
+	header.Size = size
+	if err := p.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.CopyN(p.tw, spool, size)
+	return err
+}
+
+func (p *TarExporter) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for pathname, spool := range p.pending {
+		spool.Close()
+		delete(p.pending, pathname)
+	}
+	return p.tw.Close()
+}
@@ -0,0 +1,164 @@
+package stdio
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/snapshot"
+	"github.com/PlakarKorp/kloset/snapshot/exporter"
+	"github.com/PlakarKorp/kloset/snapshot/importer"
+	ptesting "github.com/PlakarKorp/plakar/testing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTarExporter(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	mtime := time.Date(2025, time.March, 14, 15, 9, 26, 0, time.UTC)
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockDir("another_subdir"),
+		ptesting.NewMockFileModTime("subdir/dummy.txt", 0644, "hello dummy", mtime),
+		ptesting.NewMockFileModTime("subdir/foo.txt", 0600, "hello foo", mtime),
+		ptesting.NewMockFileModTime("another_subdir/bar.txt", 0755, "hello bar", mtime),
+	})
+	defer snap.Close()
+
+	var archive bytes.Buffer
+	exp, err := NewTarExporter(ctx, &exporter.Options{
+		Stdout: &archive,
+	}, "stdio", map[string]string{"location": "stdio://"})
+	require.NoError(t, err)
+	require.Equal(t, "/", exp.Root())
+
+	err = snap.Restore(exp, exp.Root(), "/", &snapshot.RestoreOptions{
+		MaxConcurrency: 2,
+		Strip:          "/",
+	})
+	require.NoError(t, err)
+	require.NoError(t, exp.Close())
+
+	fs, err := snap.Filesystem()
+	require.NoError(t, err)
+
+	expected := map[string]string{
+		"subdir/dummy.txt":       "hello dummy",
+		"subdir/foo.txt":         "hello foo",
+		"another_subdir/bar.txt": "hello bar",
+	}
+	found := map[string]bool{}
+
+	tr := tar.NewReader(&archive)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		found[header.Name] = true
+
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		content, ok := expected[header.Name]
+		require.True(t, ok, "unexpected entry %s", header.Name)
+
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		require.Equal(t, content, string(data))
+
+		entry, err := fs.GetEntry("/" + header.Name)
+		require.NoError(t, err)
+		require.Equal(t, int64(entry.Stat().Mode().Perm()), header.Mode)
+		require.True(t, mtime.Equal(header.ModTime))
+		require.True(t, entry.Stat().ModTime().Equal(header.ModTime))
+		require.Equal(t, "flan", header.Uname)
+		require.Equal(t, "hacker", header.Gname)
+	}
+
+	for _, name := range []string{"subdir/", "another_subdir/", "subdir/dummy.txt", "subdir/foo.txt", "another_subdir/bar.txt"} {
+		require.True(t, found[name], "missing entry %s", name)
+	}
+}
+
+func TestTarExporterHardlinks(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	// two names for the same file
+	hardlink := func(pathname string) *importer.ScanResult {
+		info := objects.FileInfo{
+			Lname:    path.Base(pathname),
+			Lsize:    int64(len("hello link")),
+			Lmode:    0644,
+			Ldev:     1,
+			Lino:     42,
+			Lnlink:   2,
+			LmodTime: time.Now(),
+		}
+		return importer.NewScanRecord(pathname, "", info, nil, func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader([]byte("hello link"))), nil
+		})
+	}
+
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	root, subdir := ptesting.NewMockDir("/"), ptesting.NewMockDir("/subdir")
+	snap := ptesting.GenerateSnapshot(t, repo, nil, ptesting.WithGenerator(func(ch chan<- *importer.ScanResult) {
+		ch <- root.ScanResult()
+		ch <- subdir.ScanResult()
+		ch <- hardlink("/subdir/a.txt")
+		ch <- hardlink("/subdir/b.txt")
+		close(ch)
+	}))
+	defer snap.Close()
+
+	fs, err := snap.Filesystem()
+	require.NoError(t, err)
+	for _, name := range []string{"/subdir/a.txt", "/subdir/b.txt"} {
+		entry, err := fs.GetEntry(name)
+		require.NoError(t, err)
+		require.Equal(t, uint16(2), entry.Stat().Nlink())
+	}
+
+	var archive bytes.Buffer
+	exp, err := NewTarExporter(ctx, &exporter.Options{
+		Stdout: &archive,
+	}, "stdio", map[string]string{"location": "stdio://"})
+	require.NoError(t, err)
+
+	err = snap.Restore(exp, exp.Root(), "/", &snapshot.RestoreOptions{
+		MaxConcurrency: 1,
+		Strip:          "/",
+	})
+	require.NoError(t, err)
+	require.NoError(t, exp.Close())
+
+	// only the first name makes it to the archive
+	var files []string
+	tr := tar.NewReader(&archive)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		files = append(files, header.Name)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		require.Equal(t, "hello link", string(data))
+	}
+	require.Len(t, files, 1)
+	require.Contains(t, []string{"subdir/a.txt", "subdir/b.txt"}, files[0])
+}
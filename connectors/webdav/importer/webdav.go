@@ -0,0 +1,266 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/snapshot/importer"
+)
+
+type WebDAVImporter struct {
+	ctx      context.Context
+	client   *http.Client
+	scheme   string
+	host     string
+	rootDir  string
+	username string
+	password string
+}
+
+func init() {
+	importer.Register("webdav", 0, NewWebDAVImporter)
+	importer.Register("webdavs", 0, NewWebDAVImporter)
+}
+
+// errFiniteDepth is returned by propfind when the server refuses to list
+// a whole hierarchy at once, as many do for performance reasons.
+var errFiniteDepth = errors.New("server does not allow infinite depth")
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:">
+  <D:prop>
+    <D:resourcetype/>
+    <D:getcontentlength/>
+    <D:getlastmodified/>
+  </D:prop>
+</D:propfind>`
+
+type multistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Propstats []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+				ContentLength int64  `xml:"DAV: getcontentlength"`
+				LastModified  string `xml:"DAV: getlastmodified"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+type davEntry struct {
+	pathname string
+	isDir    bool
+	size     int64
+	modTime  time.Time
+}
+
+func NewWebDAVImporter(ctx context.Context, opts *importer.Options, name string, config map[string]string) (importer.Importer, error) {
+	target := config["location"]
+
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := "http"
+	if name == "webdavs" {
+		scheme = "https"
+	}
+
+	return &WebDAVImporter{
+		ctx:      ctx,
+		client:   &http.Client{},
+		scheme:   scheme,
+		host:     parsed.Host,
+		rootDir:  path.Clean("/" + parsed.Path),
+		username: config["username"],
+		password: config["password"],
+	}, nil
+}
+
+func (p *WebDAVImporter) request(method, pathname string, body io.Reader) (*http.Request, error) {
+	u := url.URL{Scheme: p.scheme, Host: p.host, Path: pathname}
+	req, err := http.NewRequestWithContext(p.ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if p.username != "" || p.password != "" {
+		req.SetBasicAuth(p.username, p.password)
+	}
+	return req, nil
+}
+
+func (p *WebDAVImporter) propfind(pathname string, depth string) ([]davEntry, error) {
+	req, err := p.request("PROPFIND", pathname, strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusForbidden && depth == "infinity" {
+		return nil, errFiniteDepth
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("PROPFIND %s: %s", pathname, resp.Status)
+	}
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("PROPFIND %s: %w", pathname, err)
+	}
+
+	entries := make([]davEntry, 0, len(ms.Responses))
+	for _, r := range ms.Responses {
+		href, err := url.Parse(r.Href)
+		if err != nil {
+			return nil, fmt.Errorf("PROPFIND %s: invalid href %q: %w", pathname, r.Href, err)
+		}
+
+		entry := davEntry{pathname: path.Clean("/" + href.Path)}
+		for _, ps := range r.Propstats {
+			// properties the server does not know about, such as
+			// the length of a collection, are reported apart.
+			if !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			entry.isDir = ps.Prop.ResourceType.Collection != nil
+			entry.size = ps.Prop.ContentLength
+			if t, err := http.ParseTime(ps.Prop.LastModified); err == nil {
+				entry.modTime = t
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// walk lists the hierarchy below dir one level at a time, for servers
+// refusing infinite depth.
+func (p *WebDAVImporter) walk(dir string, fn func(davEntry)) error {
+	entries, err := p.propfind(dir, "1")
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		// each directory is part of its own listing, only the
+		// root is not reported by its parent.
+		if entry.pathname == dir {
+			if dir == p.rootDir {
+				fn(entry)
+			}
+			continue
+		}
+
+		fn(entry)
+		if entry.isDir {
+			if err := p.walk(entry.pathname, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *WebDAVImporter) record(entry davEntry) *importer.ScanResult {
+	if entry.isDir {
+		fi := objects.NewFileInfo(path.Base(entry.pathname), 0, 0700|os.ModeDir,
+			entry.modTime, 0, 0, 0, 0, 0)
+		return importer.NewScanRecord(entry.pathname, "", fi, nil, nil)
+	}
+
+	fi := objects.NewFileInfo(path.Base(entry.pathname), entry.size, 0600,
+		entry.modTime, 1, 0, 0, 0, 0)
+	return importer.NewScanRecord(entry.pathname, "", fi, nil, func() (io.ReadCloser, error) {
+		return p.NewReader(entry.pathname)
+	})
+}
+
+func (p *WebDAVImporter) Scan() (<-chan *importer.ScanResult, error) {
+	results := make(chan *importer.ScanResult, 1000)
+	go func() {
+		defer close(results)
+
+		// the parents of the root are not necessarily visible
+		// on the server, make them up.
+		parent := p.rootDir
+		for parent != "/" {
+			parent = path.Dir(parent)
+			fi := objects.NewFileInfo(path.Base(parent), 0, 0700|os.ModeDir,
+				time.Unix(0, 0), 0, 0, 0, 0, 0)
+			results <- importer.NewScanRecord(parent, "", fi, nil, nil)
+		}
+
+		emit := func(entry davEntry) {
+			results <- p.record(entry)
+		}
+
+		entries, err := p.propfind(p.rootDir, "infinity")
+		if errors.Is(err, errFiniteDepth) {
+			err = p.walk(p.rootDir, emit)
+		} else if err == nil {
+			for _, entry := range entries {
+				emit(entry)
+			}
+		}
+		if err != nil {
+			results <- importer.NewScanError(p.rootDir, err)
+		}
+	}()
+	return results, nil
+}
+
+func (p *WebDAVImporter) NewReader(pathname string) (io.ReadCloser, error) {
+	req, err := p.request(http.MethodGet, pathname, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", pathname, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func (p *WebDAVImporter) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
+
+func (p *WebDAVImporter) Root() string {
+	return p.rootDir
+}
+
+func (p *WebDAVImporter) Origin() string {
+	return p.host
+}
+
+func (p *WebDAVImporter) Type() string {
+	return "webdav"
+}
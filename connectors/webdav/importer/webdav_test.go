@@ -0,0 +1,124 @@
+package webdav
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+)
+
+func newServer(t *testing.T, files map[string]string, finiteDepth bool) *httptest.Server {
+	ctx := context.Background()
+
+	fs := webdav.NewMemFS()
+	for _, dir := range []string{"/data", "/data/subdir"} {
+		require.NoError(t, fs.Mkdir(ctx, dir, 0755))
+	}
+	for name, content := range files {
+		fp, err := fs.OpenFile(ctx, name, os.O_CREATE|os.O_WRONLY, 0644)
+		require.NoError(t, err)
+		_, err = fp.Write([]byte(content))
+		require.NoError(t, err)
+		require.NoError(t, fp.Close())
+	}
+
+	handler := &webdav.Handler{
+		FileSystem: fs,
+		LockSystem: webdav.NewMemLS(),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if finiteDepth && r.Method == "PROPFIND" && r.Header.Get("Depth") == "infinity" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testImporter(t *testing.T, finiteDepth bool) {
+	files := map[string]string{
+		"/data/file1.txt":        "content1",
+		"/data/subdir/file2.txt": "content2",
+	}
+	server := newServer(t, files, finiteDepth)
+
+	appCtx := appcontext.NewAppContext()
+	imp, err := NewWebDAVImporter(appCtx, nil, "webdav", map[string]string{
+		"location": "webdav://" + server.Listener.Addr().String() + "/data",
+		"username": "user",
+		"password": "secret",
+	})
+	require.NoError(t, err)
+	defer imp.Close()
+
+	require.Equal(t, "/data", imp.Root())
+	require.Equal(t, "webdav", imp.Type())
+	require.Equal(t, server.Listener.Addr().String(), imp.Origin())
+
+	results, err := imp.Scan()
+	require.NoError(t, err)
+
+	dirs := make(map[string]bool)
+	scanned := make(map[string]string)
+	for result := range results {
+		require.Nil(t, result.Error)
+
+		record := result.Record
+		if record.FileInfo.IsDir() {
+			dirs[record.Pathname] = true
+			continue
+		}
+
+		require.Equal(t, int64(len(files[record.Pathname])), record.FileInfo.Size())
+		content, err := io.ReadAll(record.Reader)
+		require.NoError(t, err)
+		require.NoError(t, record.Reader.Close())
+		scanned[record.Pathname] = string(content)
+	}
+
+	require.Equal(t, files, scanned)
+	require.Equal(t, map[string]bool{"/": true, "/data": true, "/data/subdir": true}, dirs)
+}
+
+func TestImporter(t *testing.T) {
+	testImporter(t, false)
+}
+
+func TestImporterFiniteDepth(t *testing.T) {
+	testImporter(t, true)
+}
+
+func TestImporterUnauthorized(t *testing.T) {
+	server := newServer(t, nil, false)
+
+	appCtx := appcontext.NewAppContext()
+	imp, err := NewWebDAVImporter(appCtx, nil, "webdav", map[string]string{
+		"location": "webdav://" + server.Listener.Addr().String() + "/data",
+	})
+	require.NoError(t, err)
+	defer imp.Close()
+
+	results, err := imp.Scan()
+	require.NoError(t, err)
+
+	var scanErr error
+	for result := range results {
+		if result.Error != nil {
+			scanErr = result.Error.Err
+		}
+	}
+	require.ErrorContains(t, scanErr, "401")
+}
@@ -0,0 +1,5 @@
+package webdav
+
+import (
+	_ "github.com/PlakarKorp/plakar/connectors/webdav/importer"
+)
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -76,6 +77,31 @@ func (c *Manager) PutRepositoryCookie(repositoryId uuid.UUID, name string) error
 	return err
 }
 
+// InvalidateRepositoryStats marks the statistics computed so far for the
+// repository as stale, for maintenance operations that change them.
+func (c *Manager) InvalidateRepositoryStats(repositoryId uuid.UUID) error {
+	err := os.MkdirAll(filepath.Join(c.cookiesDir, repositoryId.String()), 0700)
+	if err != nil {
+		return err
+	}
+	now := []byte(time.Now().Format(time.RFC3339Nano))
+	return os.WriteFile(filepath.Join(c.cookiesDir, repositoryId.String(), ".stats-invalidated"), now, 0600)
+}
+
+// RepositoryStatsInvalidatedAt returns when the statistics of the
+// repository were last invalidated, or the zero time if they never were.
+func (c *Manager) RepositoryStatsInvalidatedAt(repositoryId uuid.UUID) time.Time {
+	data, err := os.ReadFile(filepath.Join(c.cookiesDir, repositoryId.String(), ".stats-invalidated"))
+	if err != nil {
+		return time.Time{}
+	}
+	when, err := time.Parse(time.RFC3339Nano, string(data))
+	if err != nil {
+		return time.Time{}
+	}
+	return when
+}
+
 func (c *Manager) IsFirstRun() bool {
 	_, err := os.Stat(filepath.Join(c.cookiesDir, ".first-run"))
 	if os.IsNotExist(err) {
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
@@ -89,6 +90,29 @@ func TestRepositoryCookieOperations(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestRepositoryStatsInvalidation(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cookies_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	manager := NewManager(tmpDir)
+	repoID := uuid.New()
+
+	// Test initial state
+	require.True(t, manager.RepositoryStatsInvalidatedAt(repoID).IsZero())
+
+	before := time.Now()
+	err = manager.InvalidateRepositoryStats(repoID)
+	require.NoError(t, err)
+
+	invalidatedAt := manager.RepositoryStatsInvalidatedAt(repoID)
+	require.False(t, invalidatedAt.Before(before))
+	require.False(t, invalidatedAt.After(time.Now()))
+
+	// Other repositories are not affected
+	require.True(t, manager.RepositoryStatsInvalidatedAt(uuid.New()).IsZero())
+}
+
 func TestFirstRunOperations(t *testing.T) {
 	// Create a temporary directory for testing
 	tmpDir, err := os.MkdirTemp("", "cookies_test")
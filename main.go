@@ -1,14 +1,12 @@
 package main
 
 import (
-	"bufio"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"os/exec"
 	"os/signal"
 	"os/user"
 	"path/filepath"
@@ -42,6 +40,7 @@ import (
 	_ "github.com/PlakarKorp/plakar/subcommands/clone"
 	_ "github.com/PlakarKorp/plakar/subcommands/config"
 	_ "github.com/PlakarKorp/plakar/subcommands/create"
+	_ "github.com/PlakarKorp/plakar/subcommands/dedupcompare"
 	_ "github.com/PlakarKorp/plakar/subcommands/diag"
 	_ "github.com/PlakarKorp/plakar/subcommands/diff"
 	_ "github.com/PlakarKorp/plakar/subcommands/digest"
@@ -51,6 +50,8 @@ import (
 	_ "github.com/PlakarKorp/plakar/subcommands/login"
 	_ "github.com/PlakarKorp/plakar/subcommands/ls"
 	_ "github.com/PlakarKorp/plakar/subcommands/maintenance"
+	_ "github.com/PlakarKorp/plakar/subcommands/manifest"
+	_ "github.com/PlakarKorp/plakar/subcommands/merge"
 	_ "github.com/PlakarKorp/plakar/subcommands/mount"
 	_ "github.com/PlakarKorp/plakar/subcommands/pkg"
 	_ "github.com/PlakarKorp/plakar/subcommands/ptar"
@@ -58,11 +59,15 @@ import (
 	_ "github.com/PlakarKorp/plakar/subcommands/rm"
 	_ "github.com/PlakarKorp/plakar/subcommands/server"
 	_ "github.com/PlakarKorp/plakar/subcommands/services"
+	_ "github.com/PlakarKorp/plakar/subcommands/tag"
 	_ "github.com/PlakarKorp/plakar/subcommands/ui"
 	_ "github.com/PlakarKorp/plakar/subcommands/version"
+	_ "github.com/PlakarKorp/plakar/subcommands/watchrestore"
 
+	_ "github.com/PlakarKorp/plakar/connectors/azure"
 	_ "github.com/PlakarKorp/plakar/connectors/fs"
 	_ "github.com/PlakarKorp/plakar/connectors/ftp"
+	_ "github.com/PlakarKorp/plakar/connectors/gcs"
 	_ "github.com/PlakarKorp/plakar/connectors/http"
 	_ "github.com/PlakarKorp/plakar/connectors/ptar"
 	_ "github.com/PlakarKorp/plakar/connectors/s3"
@@ -70,6 +75,7 @@ import (
 	_ "github.com/PlakarKorp/plakar/connectors/sqlite"
 	_ "github.com/PlakarKorp/plakar/connectors/stdio"
 	_ "github.com/PlakarKorp/plakar/connectors/tar"
+	_ "github.com/PlakarKorp/plakar/connectors/webdav"
 )
 
 var ErrCantUnlock = errors.New("failed to unlock repository")
@@ -416,7 +422,7 @@ func entryPoint() int {
 
 	runWithoutAgent := opt_agentless || cmd.GetFlags()&subcommands.AgentSupport == 0
 	if runWithoutAgent {
-		status, err = task.RunCommand(ctx, cmd, repo, "@agentless")
+		status, err = task.RunCommand(ctx, cmd, repo, "@agentless", nil)
 	} else {
 		status, err = agent.ExecuteRPC(ctx, name, cmd, storeConfig)
 	}
@@ -518,66 +524,6 @@ func checkUpdate(ctx *appcontext.AppContext, disableSecurityCheck bool) {
 		concerns, rus.Latest, rus.FoundCount)
 }
 
-func getPassphraseFromEnv(ctx *appcontext.AppContext, params map[string]string) (string, error) {
-	if ctx.KeyFromFile != "" {
-		return ctx.KeyFromFile, nil
-	}
-
-	if pass, ok := params["passphrase"]; ok {
-		return pass, nil
-	}
-
-	if cmd, ok := params["passphrase_cmd"]; ok {
-		var c *exec.Cmd
-		switch runtime.GOOS {
-		case "windows":
-			c = exec.Command("cmd", "/C", cmd)
-		default: // assume unix-esque
-			c = exec.Command("/bin/sh", "-c", cmd)
-		}
-
-		stdout, err := c.StdoutPipe()
-		if err != nil {
-			return "", err
-		}
-
-		if err := c.Start(); err != nil {
-			return "", err
-		}
-
-		var pass string
-		var lines int
-		scan := bufio.NewScanner(stdout)
-		for scan.Scan() {
-			pass = scan.Text()
-			lines++
-		}
-
-		// don't deadlock in case the scanner fails
-		io.Copy(io.Discard, stdout)
-
-		if err := c.Wait(); err != nil {
-			return "", err
-		}
-
-		if err := scan.Err(); err != nil {
-			return "", err
-		}
-
-		if lines != 1 {
-			return "", fmt.Errorf("passphrase_cmd returned too many lines")
-		}
-
-		return pass, nil
-	}
-
-	if pass, ok := os.LookupEnv("PLAKAR_PASSPHRASE"); ok {
-		return pass, nil
-	}
-
-	return "", nil
-}
-
 func setupEncryption(ctx *appcontext.AppContext, config *storage.Configuration) error {
 	if config.Encryption == nil {
 		return nil
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/PlakarKorp/plakar/appcontext"
+)
+
+// A passphraseProvider returns the passphrase to unlock a store and
+// whether it has one to offer.  A passphrase it offers ends the lookup,
+// even if empty.
+type passphraseProvider func(ctx *appcontext.AppContext, params map[string]string) (string, bool, error)
+
+var passphraseProviders = map[string]passphraseProvider{
+	"config":  passphraseFromConfig,
+	"command": passphraseFromCommand,
+	"env":     passphraseFromEnv,
+}
+
+// defaultPassphraseProviders is the order in which providers are tried
+// unless the store configuration sets passphrase_providers.
+var defaultPassphraseProviders = []string{"config", "command", "env"}
+
+func getPassphraseFromEnv(ctx *appcontext.AppContext, params map[string]string) (string, error) {
+	if ctx.KeyFromFile != "" {
+		return ctx.KeyFromFile, nil
+	}
+
+	chain := defaultPassphraseProviders
+	if value, ok := params["passphrase_providers"]; ok {
+		chain = strings.Split(value, ",")
+	}
+
+	for _, name := range chain {
+		name = strings.TrimSpace(name)
+		provider, ok := passphraseProviders[name]
+		if !ok {
+			return "", fmt.Errorf("unknown passphrase provider: %s", name)
+		}
+
+		pass, ok, err := provider(ctx, params)
+		if err != nil {
+			return "", fmt.Errorf("passphrase provider %s: %w", name, err)
+		}
+		if ok {
+			return pass, nil
+		}
+	}
+
+	return "", nil
+}
+
+func passphraseFromConfig(ctx *appcontext.AppContext, params map[string]string) (string, bool, error) {
+	pass, ok := params["passphrase"]
+	return pass, ok, nil
+}
+
+func passphraseFromCommand(ctx *appcontext.AppContext, params map[string]string) (string, bool, error) {
+	cmd, ok := params["passphrase_cmd"]
+	if !ok {
+		return "", false, nil
+	}
+
+	var c *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		c = exec.Command("cmd", "/C", cmd)
+	default: // assume unix-esque
+		c = exec.Command("/bin/sh", "-c", cmd)
+	}
+
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		return "", false, err
+	}
+
+	if err := c.Start(); err != nil {
+		return "", false, err
+	}
+
+	var pass string
+	var lines int
+	scan := bufio.NewScanner(stdout)
+	for scan.Scan() {
+		pass = scan.Text()
+		lines++
+	}
+
+	// don't deadlock in case the scanner fails
+	io.Copy(io.Discard, stdout)
+
+	if err := c.Wait(); err != nil {
+		return "", false, err
+	}
+
+	if err := scan.Err(); err != nil {
+		return "", false, err
+	}
+
+	if lines != 1 {
+		return "", false, fmt.Errorf("passphrase_cmd returned too many lines")
+	}
+
+	return pass, true, nil
+}
+
+func passphraseFromEnv(ctx *appcontext.AppContext, params map[string]string) (string, bool, error) {
+	pass, ok := os.LookupEnv("PLAKAR_PASSPHRASE")
+	return pass, ok, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPassphraseFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     *string
+		keyfile string
+		params  map[string]string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "nothing set",
+		},
+		{
+			name:   "config first",
+			env:    ptr("from env"),
+			params: map[string]string{"passphrase": "from config", "passphrase_cmd": "echo from command"},
+			want:   "from config",
+		},
+		{
+			name:   "command before env",
+			env:    ptr("from env"),
+			params: map[string]string{"passphrase_cmd": "echo from command"},
+			want:   "from command",
+		},
+		{
+			name: "env last",
+			env:  ptr("from env"),
+			want: "from env",
+		},
+		{
+			name:   "empty config passphrase is authoritative",
+			env:    ptr("from env"),
+			params: map[string]string{"passphrase": "", "passphrase_cmd": "echo from command"},
+			want:   "",
+		},
+		{
+			name:   "empty env passphrase is authoritative",
+			env:    ptr(""),
+			params: map[string]string{"passphrase_providers": "env,config", "passphrase": "from config"},
+			want:   "",
+		},
+		{
+			name:    "keyfile wins",
+			env:     ptr("from env"),
+			keyfile: "from keyfile",
+			params:  map[string]string{"passphrase": "from config"},
+			want:    "from keyfile",
+		},
+		{
+			name:   "custom order",
+			env:    ptr("from env"),
+			params: map[string]string{"passphrase_providers": "env, config", "passphrase": "from config"},
+			want:   "from env",
+		},
+		{
+			name:   "skipped providers",
+			env:    ptr("from env"),
+			params: map[string]string{"passphrase_providers": "command", "passphrase": "from config"},
+			want:   "",
+		},
+		{
+			name:    "unknown provider",
+			params:  map[string]string{"passphrase_providers": "config,keychain"},
+			wantErr: "unknown passphrase provider: keychain",
+		},
+		{
+			name:    "failing command",
+			params:  map[string]string{"passphrase_cmd": "exit 1"},
+			wantErr: "passphrase provider command",
+		},
+		{
+			name:    "command printing several lines",
+			params:  map[string]string{"passphrase_cmd": "echo one; echo two"},
+			wantErr: "passphrase_cmd returned too many lines",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != nil {
+				t.Setenv("PLAKAR_PASSPHRASE", *tt.env)
+			} else {
+				// unset it for the duration of the test
+				t.Setenv("PLAKAR_PASSPHRASE", "")
+				require.NoError(t, os.Unsetenv("PLAKAR_PASSPHRASE"))
+			}
+
+			ctx := appcontext.NewAppContext()
+			defer ctx.Close()
+			ctx.KeyFromFile = tt.keyfile
+
+			params := tt.params
+			if params == nil {
+				params = map[string]string{}
+			}
+
+			pass, err := getPassphraseFromEnv(ctx, params)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, pass)
+		})
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
@@ -97,19 +97,23 @@ func (d *Dir) Attr(ctx context.Context, a *fuse.Attr) error {
 func (d *Dir) Lookup(ctx context.Context, name string) (fs.Node, error) {
 	if d.name == "/" {
 		return &Dir{parent: d, name: name, repo: d.repo}, nil
-	} else if d.parent.name == "/" {
-		return &Dir{parent: d, name: name}, nil
 	} else {
 		cleanpath := filepath.Clean(d.fullpath + "/" + name)
-		entry, err := d.vfs.GetEntry(cleanpath)
+		entry, err := lstat(d.vfs, cleanpath)
 		if err != nil {
 			return nil, err
 		}
+		if entry == nil {
+			return nil, syscall.ENOENT
+		}
 
+		if entry.Stat().Mode()&os.ModeSymlink != 0 {
+			return &Symlink{parent: d, name: name, entry: entry}, nil
+		}
 		if entry.Stat().IsDir() {
 			return &Dir{parent: d, name: name}, nil
 		}
-		return &File{parent: d, name: name}, nil
+		return newFile(d, name), nil
 	}
 }
 
@@ -149,7 +153,9 @@ func (d *Dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 			Name:  entry.Name(),
 			Type:  fuse.DT_File,
 		}
-		if entry.Stat().IsDir() {
+		if entry.Stat().Mode()&os.ModeSymlink != 0 {
+			dirEnt.Type = fuse.DT_Link
+		} else if entry.Stat().IsDir() {
 			dirEnt.Type = fuse.DT_Dir
 		}
 
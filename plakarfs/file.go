@@ -4,17 +4,20 @@ package plakarfs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
+	"sync"
 	"syscall"
 
 	"github.com/PlakarKorp/kloset/repository"
 	"github.com/PlakarKorp/kloset/snapshot/vfs"
 	"github.com/anacrolix/fuse"
+	"github.com/anacrolix/fuse/fs"
 )
 
-// File implements both Node and Handle for the hello file.
+// File is the node of a regular file within a snapshot.
 type File struct {
 	parent   *Dir
 	name     string
@@ -23,13 +26,17 @@ type File struct {
 	vfs      *vfs.Filesystem
 }
 
-func (f *File) Attr(ctx context.Context, a *fuse.Attr) error {
-	f.repo = f.parent.repo
-	f.vfs = f.parent.vfs
-	f.fullpath = f.parent.fullpath + "/" + f.name
-
-	f.fullpath = filepath.Clean(f.fullpath)
+func newFile(parent *Dir, name string) *File {
+	return &File{
+		parent:   parent,
+		name:     name,
+		fullpath: filepath.Clean(parent.fullpath + "/" + name),
+		repo:     parent.repo,
+		vfs:      parent.vfs,
+	}
+}
 
+func (f *File) Attr(ctx context.Context, a *fuse.Attr) error {
 	entry, err := f.vfs.GetEntry(f.fullpath)
 	if err != nil {
 		return syscall.ENOENT
@@ -51,10 +58,50 @@ func (f *File) Attr(ctx context.Context, a *fuse.Attr) error {
 	return nil
 }
 
-func (f *File) ReadAll(ctx context.Context) ([]byte, error) {
-	rd, err := f.parent.snap.NewReader(f.fullpath)
+func (f *File) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	if !req.Flags.IsReadOnly() {
+		return nil, syscall.EROFS
+	}
+
+	entry, err := f.vfs.GetEntry(f.fullpath)
 	if err != nil {
-		return nil, err
+		return nil, syscall.ENOENT
+	}
+
+	rd, ok := entry.Open(f.vfs).(io.ReadSeekCloser)
+	if !ok {
+		return nil, syscall.EINVAL
+	}
+	return &fileHandle{rd: rd}, nil
+}
+
+// fileHandle streams the content of an opened file: each read only
+// fetches the chunks covering the requested range.
+type fileHandle struct {
+	mu sync.Mutex
+	rd io.ReadSeekCloser
+}
+
+func (h *fileHandle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, err := h.rd.Seek(req.Offset, io.SeekStart); err != nil {
+		return err
 	}
-	return io.ReadAll(rd)
+
+	buf := make([]byte, req.Size)
+	n, err := io.ReadFull(h.rd, buf)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	resp.Data = buf[:n]
+	return nil
+}
+
+func (h *fileHandle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.rd.Close()
 }
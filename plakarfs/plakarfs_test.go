@@ -0,0 +1,107 @@
+//go:build linux || darwin
+
+package plakarfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	ptesting "github.com/PlakarKorp/plakar/testing"
+	"github.com/anacrolix/fuse"
+	"github.com/anacrolix/fuse/fs"
+	"github.com/stretchr/testify/require"
+)
+
+// lookup resolves name in node and fetches its attributes, the way the
+// FUSE server does before handing the node to the kernel.
+func lookup(t *testing.T, node fs.Node, name string) (fs.Node, fuse.Attr) {
+	node, err := node.(fs.NodeStringLookuper).Lookup(context.Background(), name)
+	require.NoError(t, err)
+
+	var attr fuse.Attr
+	require.NoError(t, node.Attr(context.Background(), &attr))
+	return node, attr
+}
+
+func TestPlakarFS(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	content := bytes.Repeat([]byte("hello plakar\n"), 10000)
+
+	repo, _ := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/dummy.txt", 0644, string(content)),
+		ptesting.NewMockSymlink("subdir/link", "dummy.txt"),
+	})
+	defer snap.Close()
+
+	root, err := NewFS(repo, "/mnt").Root()
+	require.NoError(t, err)
+
+	var attr fuse.Attr
+	require.NoError(t, root.Attr(context.Background(), &attr))
+	require.True(t, attr.Mode.IsDir())
+
+	snapdir, attr := lookup(t, root, hex.EncodeToString(snap.Header.Identifier[:]))
+	require.True(t, attr.Mode.IsDir())
+
+	subdir, attr := lookup(t, snapdir, "subdir")
+	require.True(t, attr.Mode.IsDir())
+
+	dirents, err := subdir.(fs.HandleReadDirAller).ReadDirAll(context.Background())
+	require.NoError(t, err)
+	types := make(map[string]fuse.DirentType)
+	for _, dirent := range dirents {
+		types[dirent.Name] = dirent.Type
+	}
+	require.Equal(t, map[string]fuse.DirentType{
+		"dummy.txt": fuse.DT_File,
+		"link":      fuse.DT_Link,
+	}, types)
+
+	file, attr := lookup(t, subdir, "dummy.txt")
+	require.True(t, attr.Mode.IsRegular())
+	require.Equal(t, uint64(len(content)), attr.Size)
+
+	handle, err := file.(fs.NodeOpener).Open(context.Background(),
+		&fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{})
+	require.NoError(t, err)
+
+	// read the file back in chunks, the last one past the end of it
+	var data []byte
+	for offset := 0; offset < len(content); offset += 4096 {
+		resp := &fuse.ReadResponse{}
+		err := handle.(fs.HandleReader).Read(context.Background(),
+			&fuse.ReadRequest{Offset: int64(offset), Size: 4096}, resp)
+		require.NoError(t, err)
+		data = append(data, resp.Data...)
+	}
+	require.Equal(t, content, data)
+
+	// reads may come in any order
+	resp := &fuse.ReadResponse{}
+	err = handle.(fs.HandleReader).Read(context.Background(),
+		&fuse.ReadRequest{Offset: 13, Size: 12}, resp)
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello plakar"), resp.Data)
+
+	require.NoError(t, handle.(fs.HandleReleaser).Release(context.Background(), &fuse.ReleaseRequest{}))
+
+	_, err = file.(fs.NodeOpener).Open(context.Background(),
+		&fuse.OpenRequest{Flags: fuse.OpenReadWrite}, &fuse.OpenResponse{})
+	require.Error(t, err)
+
+	link, attr := lookup(t, subdir, "link")
+	require.NotZero(t, attr.Mode&os.ModeSymlink)
+	target, err := link.(fs.NodeReadlinker).Readlink(context.Background(), &fuse.ReadlinkRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "dummy.txt", target)
+
+	_, err = subdir.(fs.NodeStringLookuper).Lookup(context.Background(), "missing")
+	require.Error(t, err)
+}
@@ -0,0 +1,52 @@
+//go:build linux || darwin
+
+package plakarfs
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/PlakarKorp/kloset/snapshot/vfs"
+	"github.com/anacrolix/fuse"
+)
+
+// Symlink is the node of a symbolic link within a snapshot.  The link
+// itself is exposed rather than its target, so that the kernel resolves
+// it relative to the mountpoint like on the original filesystem.
+type Symlink struct {
+	parent *Dir
+	name   string
+	entry  *vfs.Entry
+}
+
+func (s *Symlink) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Rdev = uint32(s.entry.Stat().Dev())
+	a.Inode = s.entry.Stat().Ino()
+	a.Mode = s.entry.Stat().Mode()
+	a.Uid = uint32(s.entry.Stat().Uid())
+	a.Gid = uint32(s.entry.Stat().Gid())
+	a.Ctime = s.entry.Stat().ModTime()
+	a.Mtime = s.entry.Stat().ModTime()
+	a.Size = uint64(len(s.entry.SymlinkTarget))
+	a.Nlink = uint32(s.entry.Stat().Nlink())
+	return nil
+}
+
+func (s *Symlink) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string, error) {
+	return s.entry.SymlinkTarget, nil
+}
+
+// lstat returns the entry at pathname without following it if it is a
+// symbolic link, unlike vfs.Filesystem.GetEntry.
+func lstat(fsc *vfs.Filesystem, pathname string) (*vfs.Entry, error) {
+	tree, _, _ := fsc.BTrees()
+
+	csum, found, err := tree.Find(filepath.Clean(pathname))
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	return fsc.ResolveEntry(csum)
+}
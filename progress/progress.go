@@ -0,0 +1,168 @@
+// Package progress renders a single-line progress bar from the events
+// emitted during a backup or a restore.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PlakarKorp/kloset/events"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/dustin/go-humanize"
+	"golang.org/x/term"
+)
+
+// refreshInterval bounds how often the progress line is redrawn.
+const refreshInterval = 100 * time.Millisecond
+
+// Tracker counts the files processed from the events it receives and,
+// when its output is a terminal, keeps a progress line up to date. On
+// any other output it only counts.
+type Tracker struct {
+	out     io.Writer
+	enabled bool
+	width   int
+
+	mu         sync.Mutex
+	started    time.Time
+	files      uint64
+	errors     uint64
+	bytes      uint64
+	totalBytes uint64
+	lastDraw   time.Time
+
+	done chan struct{}
+}
+
+// NewTracker returns a tracker rendering to out, which only draws if out
+// is a terminal.
+func NewTracker(out *os.File) *Tracker {
+	t := &Tracker{
+		out:  out,
+		done: make(chan struct{}, 1),
+	}
+	if term.IsTerminal(int(out.Fd())) {
+		t.enabled = true
+		if width, _, err := term.GetSize(int(out.Fd())); err == nil {
+			t.width = width
+		}
+	}
+	return t
+}
+
+// SetTotal sets the number of bytes expected, which enables the ETA.
+func (t *Tracker) SetTotal(totalBytes uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.totalBytes = totalBytes
+}
+
+// Start subscribes the tracker to the events of ctx.
+func (t *Tracker) Start(ctx *appcontext.AppContext) {
+	listener := ctx.Events().Listen()
+	go func() {
+		for event := range listener {
+			t.Handle(event)
+		}
+	}()
+}
+
+// Close waits for the Done event and terminates the progress line.
+func (t *Tracker) Close() {
+	<-t.done
+}
+
+// Handle accounts for a single event.
+func (t *Tracker) Handle(event any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch event := event.(type) {
+	case events.Start:
+		t.started = event.Timestamp
+		t.files, t.errors, t.bytes = 0, 0, 0
+	case events.FileOK:
+		t.files++
+		t.bytes += uint64(event.Size)
+	case events.FileError, events.DirectoryError, events.PathError:
+		t.errors++
+	case events.Done:
+		t.draw(event.Timestamp, true)
+		if t.enabled {
+			fmt.Fprintln(t.out)
+		}
+		select {
+		case t.done <- struct{}{}:
+		default:
+		}
+		return
+	default:
+		return
+	}
+
+	t.draw(time.Now(), false)
+}
+
+// Files returns the number of files processed successfully.
+func (t *Tracker) Files() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.files
+}
+
+// Errors returns the number of errors reported.
+func (t *Tracker) Errors() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.errors
+}
+
+// Bytes returns the size of the files processed successfully.
+func (t *Tracker) Bytes() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.bytes
+}
+
+func (t *Tracker) draw(now time.Time, force bool) {
+	if !t.enabled {
+		return
+	}
+	if !force && now.Sub(t.lastDraw) < refreshInterval {
+		return
+	}
+	t.lastDraw = now
+
+	elapsed := now.Sub(t.started).Truncate(time.Second)
+	line := fmt.Sprintf("%d files, %s, %d errors, %s elapsed",
+		t.files, humanize.Bytes(t.bytes), t.errors, elapsed)
+
+	if t.totalBytes != 0 {
+		ratio := min(float64(t.bytes)/float64(t.totalBytes), 1)
+		line = fmt.Sprintf("%3.0f%% %s", ratio*100, line)
+		if t.bytes != 0 && ratio < 1 {
+			eta := time.Duration(float64(now.Sub(t.started)) * (1/ratio - 1))
+			line += fmt.Sprintf(", ETA %s", eta.Truncate(time.Second))
+		}
+
+		barWidth := t.width - len(line) - 3
+		if barWidth > 40 {
+			barWidth = 40
+		}
+		if barWidth > 0 {
+			filled := int(ratio * float64(barWidth))
+			line = "[" + strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled) + "] " + line
+		}
+	}
+
+	if t.width > 0 && len(line) > t.width {
+		line = line[:t.width]
+	}
+
+	// return to the start of the line and clear it
+	fmt.Fprintf(t.out, "\r\x1b[2K%s", line)
+}
@@ -0,0 +1,62 @@
+package progress
+
+import (
+	"os"
+	"testing"
+
+	"github.com/PlakarKorp/kloset/events"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackerCounters(t *testing.T) {
+	out, err := os.CreateTemp(t.TempDir(), "progress")
+	require.NoError(t, err)
+	defer out.Close()
+
+	tracker := NewTracker(out)
+	require.False(t, tracker.enabled)
+
+	var snapshotID [32]byte
+	tracker.Handle(events.StartEvent())
+	tracker.Handle(events.FileOKEvent(snapshotID, "/a", 100))
+	tracker.Handle(events.FileOKEvent(snapshotID, "/b", 23))
+	tracker.Handle(events.FileErrorEvent(snapshotID, "/c", "permission denied"))
+	tracker.Handle(events.DirectoryOKEvent(snapshotID, "/"))
+	tracker.Handle(events.DoneEvent())
+	tracker.Close()
+
+	require.Equal(t, uint64(2), tracker.Files())
+	require.Equal(t, uint64(123), tracker.Bytes())
+	require.Equal(t, uint64(1), tracker.Errors())
+
+	// nothing is drawn when the output is not a terminal
+	info, err := out.Stat()
+	require.NoError(t, err)
+	require.Zero(t, info.Size())
+
+	// a new operation starts from scratch
+	tracker.Handle(events.StartEvent())
+	require.Zero(t, tracker.Files())
+	require.Zero(t, tracker.Bytes())
+}
+
+func TestTrackerDraw(t *testing.T) {
+	out, err := os.CreateTemp(t.TempDir(), "progress")
+	require.NoError(t, err)
+	defer out.Close()
+
+	tracker := NewTracker(out)
+	tracker.enabled = true
+	tracker.width = 120
+	tracker.SetTotal(200)
+
+	var snapshotID [32]byte
+	tracker.Handle(events.StartEvent())
+	tracker.Handle(events.FileOKEvent(snapshotID, "/a", 100))
+	tracker.Handle(events.DoneEvent())
+
+	data, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	require.Contains(t, string(data), "\r\x1b[2K")
+	require.Contains(t, string(data), " 50% 1 files, 100 B, 0 errors")
+}
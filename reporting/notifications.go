@@ -0,0 +1,322 @@
+package reporting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"runtime"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/PlakarKorp/kloset/logging"
+	"github.com/PlakarKorp/plakar/utils"
+)
+
+const PAGERDUTY_EVENTS_URL = "https://events.pagerduty.com/v2/enqueue"
+
+// DefaultNotificationsRateLimit is the minimum delay between two
+// notifications for the same task and status when none is configured.
+const DefaultNotificationsRateLimit = 15 * time.Minute
+
+type NotifierConfig struct {
+	Type string `validate:"required,oneof=email slack pagerduty webhook"`
+
+	// slack, webhook and optionally pagerduty
+	URL string `validate:"required_if=Type slack,required_if=Type webhook"`
+
+	// pagerduty
+	RoutingKey string `mapstructure:"routing_key" validate:"required_if=Type pagerduty"`
+
+	// webhook: text/template executed on the report, JSON by default
+	Template    string
+	ContentType string `mapstructure:"content_type"`
+
+	// email
+	SMTP     string `validate:"required_if=Type email"`
+	Username string
+	Password string
+	From     string   `validate:"required_if=Type email"`
+	To       []string `validate:"required_if=Type email"`
+}
+
+type NotificationsConfig struct {
+	OnFailure []NotifierConfig `mapstructure:"on_failure" validate:"dive"`
+	OnWarning []NotifierConfig `mapstructure:"on_warning" validate:"dive"`
+	OnSuccess []NotifierConfig `mapstructure:"on_success" validate:"dive"`
+	RateLimit time.Duration    `mapstructure:"rate_limit"`
+}
+
+// Dispatcher sends reports to the notifiers configured for their status,
+// at most once per rate limit period for a given task and status so that
+// a failing task doesn't cause an alert storm.
+type Dispatcher struct {
+	notifiers map[TaskStatus][]Emitter
+	rateLimit time.Duration
+
+	mu         sync.Mutex
+	lastSent   map[string]time.Time
+	suppressed map[string]int
+}
+
+func NewDispatcher(config *NotificationsConfig) (*Dispatcher, error) {
+	d := &Dispatcher{
+		notifiers:  make(map[TaskStatus][]Emitter),
+		rateLimit:  config.RateLimit,
+		lastSent:   make(map[string]time.Time),
+		suppressed: make(map[string]int),
+	}
+	if d.rateLimit == 0 {
+		d.rateLimit = DefaultNotificationsRateLimit
+	}
+
+	for status, configs := range map[TaskStatus][]NotifierConfig{
+		StatusFailed:  config.OnFailure,
+		StatusWarning: config.OnWarning,
+		StatusOK:      config.OnSuccess,
+	} {
+		for _, c := range configs {
+			notifier, err := NewNotifier(c)
+			if err != nil {
+				return nil, err
+			}
+			d.notifiers[status] = append(d.notifiers[status], notifier)
+		}
+	}
+
+	return d, nil
+}
+
+func NewNotifier(config NotifierConfig) (Emitter, error) {
+	switch config.Type {
+	case "email":
+		return &EmailEmitter{
+			addr:     config.SMTP,
+			username: config.Username,
+			password: config.Password,
+			from:     config.From,
+			to:       config.To,
+		}, nil
+	case "slack":
+		return &SlackEmitter{url: config.URL}, nil
+	case "pagerduty":
+		url := config.URL
+		if url == "" {
+			url = PAGERDUTY_EVENTS_URL
+		}
+		return &PagerDutyEmitter{url: url, routingKey: config.RoutingKey}, nil
+	case "webhook":
+		emitter := &WebhookEmitter{url: config.URL, contentType: config.ContentType}
+		if config.Template != "" {
+			tmpl, err := template.New("webhook").Parse(config.Template)
+			if err != nil {
+				return nil, fmt.Errorf("invalid webhook template: %w", err)
+			}
+			emitter.template = tmpl
+		}
+		if emitter.contentType == "" {
+			emitter.contentType = "application/json"
+		}
+		return emitter, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q", config.Type)
+	}
+}
+
+func (d *Dispatcher) Emit(report Report, logger *logging.Logger) {
+	if d == nil || report.Task == nil {
+		return
+	}
+
+	notifiers := d.notifiers[report.Task.Status]
+	if len(notifiers) == 0 {
+		return
+	}
+
+	key := fmt.Sprintf("%s\x00%s\x00%s", report.Task.Status, report.Task.Type, report.Task.Name)
+	if report.Repository != nil {
+		key += "\x00" + report.Repository.Name
+	}
+
+	d.mu.Lock()
+	if last, ok := d.lastSent[key]; ok && report.Timestamp.Sub(last) < d.rateLimit {
+		d.suppressed[key]++
+		d.mu.Unlock()
+		logger.Info("notification for %s task %s rate-limited", report.Task.Type, report.Task.Name)
+		return
+	}
+	d.lastSent[key] = report.Timestamp
+	if n := d.suppressed[key]; n != 0 {
+		// the task is shared with the other emitters
+		task := *report.Task
+		task.ErrorMessage += fmt.Sprintf(" (%d similar notifications suppressed)", n)
+		report.Task = &task
+		delete(d.suppressed, key)
+	}
+	d.mu.Unlock()
+
+	for _, notifier := range notifiers {
+		notifier.Emit(report, logger)
+	}
+}
+
+// summary is the one-line description of a report used by the chat and
+// paging notifiers.
+func summary(report Report) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "plakar: %s task %s", report.Task.Type, report.Task.Name)
+	if report.Repository != nil {
+		fmt.Fprintf(&sb, " on %s", report.Repository.Name)
+	}
+	fmt.Fprintf(&sb, ": %s", report.Task.Status)
+	if report.Task.ErrorMessage != "" {
+		fmt.Fprintf(&sb, ": %s", report.Task.ErrorMessage)
+	}
+	return sb.String()
+}
+
+func post(url string, contentType string, data []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("plakar/%s (%s/%s)", utils.VERSION, runtime.GOOS, runtime.GOARCH))
+	req.Header.Set("Content-Type", contentType)
+
+	client := http.Client{Timeout: 30 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if 200 <= res.StatusCode && res.StatusCode < 300 {
+		return nil
+	}
+	return fmt.Errorf("request failed with status %s", res.Status)
+}
+
+type EmailEmitter struct {
+	addr     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func (emitter *EmailEmitter) Emit(report Report, logger *logging.Logger) {
+	subject := summary(report)
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", emitter.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(emitter.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.ReplaceAll(subject, "\n", " "))
+	fmt.Fprintf(&msg, "Date: %s\r\n", report.Timestamp.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n\r\n", subject)
+	fmt.Fprintf(&msg, "Started: %s\r\n", report.Task.StartTime.Format(time.RFC3339))
+	fmt.Fprintf(&msg, "Duration: %s\r\n", report.Task.Duration)
+	if report.Snapshot != nil {
+		fmt.Fprintf(&msg, "Snapshot: %x\r\n", report.Snapshot.Identifier)
+	}
+
+	var auth smtp.Auth
+	if emitter.username != "" {
+		host := emitter.addr
+		if i := strings.LastIndex(host, ":"); i != -1 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", emitter.username, emitter.password, host)
+	}
+
+	if err := smtp.SendMail(emitter.addr, auth, emitter.from, emitter.to, msg.Bytes()); err != nil {
+		logger.Error("failed to send notification email: %s", err)
+	}
+}
+
+type SlackEmitter struct {
+	url string
+}
+
+func (emitter *SlackEmitter) Emit(report Report, logger *logging.Logger) {
+	data, err := json.Marshal(map[string]string{"text": summary(report)})
+	if err != nil {
+		logger.Error("failed to encode slack notification: %s", err)
+		return
+	}
+	if err := post(emitter.url, "application/json", data); err != nil {
+		logger.Error("failed to send slack notification: %s", err)
+	}
+}
+
+type PagerDutyEmitter struct {
+	url        string
+	routingKey string
+}
+
+func (emitter *PagerDutyEmitter) Emit(report Report, logger *logging.Logger) {
+	severity := "info"
+	switch report.Task.Status {
+	case StatusFailed:
+		severity = "error"
+	case StatusWarning:
+		severity = "warning"
+	}
+
+	source := "plakar"
+	if report.Repository != nil {
+		source = report.Repository.Name
+	}
+
+	data, err := json.Marshal(map[string]any{
+		"routing_key":  emitter.routingKey,
+		"event_action": "trigger",
+		"payload": map[string]any{
+			"summary":   summary(report),
+			"source":    source,
+			"severity":  severity,
+			"timestamp": report.Timestamp.Format(time.RFC3339),
+			"component": report.Task.Type,
+			"group":     report.Task.Name,
+		},
+	})
+	if err != nil {
+		logger.Error("failed to encode pagerduty event: %s", err)
+		return
+	}
+	if err := post(emitter.url, "application/json", data); err != nil {
+		logger.Error("failed to send pagerduty event: %s", err)
+	}
+}
+
+type WebhookEmitter struct {
+	url         string
+	contentType string
+	template    *template.Template
+}
+
+func (emitter *WebhookEmitter) Emit(report Report, logger *logging.Logger) {
+	var data []byte
+	if emitter.template == nil {
+		var err error
+		data, err = json.Marshal(report)
+		if err != nil {
+			logger.Error("failed to encode report: %s", err)
+			return
+		}
+	} else {
+		var buf bytes.Buffer
+		if err := emitter.template.Execute(&buf, report); err != nil {
+			logger.Error("failed to render webhook template: %s", err)
+			return
+		}
+		data = buf.Bytes()
+	}
+
+	if err := post(emitter.url, emitter.contentType, data); err != nil {
+		logger.Error("failed to send webhook notification: %s", err)
+	}
+}
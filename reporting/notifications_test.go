@@ -0,0 +1,82 @@
+package reporting
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PlakarKorp/kloset/logging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatcher(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string][]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received[r.URL.Path] = append(received[r.URL.Path], string(body))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	dispatcher, err := NewDispatcher(&NotificationsConfig{
+		OnFailure: []NotifierConfig{
+			{Type: "slack", URL: server.URL + "/slack"},
+			{Type: "pagerduty", URL: server.URL + "/pagerduty", RoutingKey: "key"},
+		},
+		OnSuccess: []NotifierConfig{
+			{Type: "webhook", URL: server.URL + "/webhook", Template: "{{ .Task.Name }} {{ .Task.Status }}"},
+		},
+		RateLimit: time.Hour,
+	})
+	require.NoError(t, err)
+
+	logger := logging.NewLogger(io.Discard, io.Discard)
+	now := time.Now()
+	failure := func(at time.Time) Report {
+		return Report{
+			Timestamp:  at,
+			Task:       &ReportTask{Type: "backup", Name: "system", Status: StatusFailed, ErrorMessage: "boom"},
+			Repository: &ReportRepository{Name: "fs:///backup"},
+		}
+	}
+
+	dispatcher.Emit(failure(now), logger)
+	dispatcher.Emit(failure(now.Add(time.Minute)), logger)
+	dispatcher.Emit(failure(now.Add(2*time.Hour)), logger)
+	dispatcher.Emit(Report{
+		Timestamp: now,
+		Task:      &ReportTask{Type: "backup", Name: "system", Status: StatusOK},
+	}, logger)
+	dispatcher.Emit(Report{
+		Timestamp: now,
+		Task:      &ReportTask{Type: "backup", Name: "system", Status: StatusWarning},
+	}, logger)
+
+	require.Len(t, received["/slack"], 2)
+	require.Len(t, received["/pagerduty"], 2)
+	require.Len(t, received["/webhook"], 1)
+
+	var slack map[string]string
+	require.NoError(t, json.Unmarshal([]byte(received["/slack"][0]), &slack))
+	require.Equal(t, "plakar: backup task system on fs:///backup: FAILURE: boom", slack["text"])
+	require.NoError(t, json.Unmarshal([]byte(received["/slack"][1]), &slack))
+	require.Contains(t, slack["text"], "(1 similar notifications suppressed)")
+
+	var event map[string]any
+	require.NoError(t, json.Unmarshal([]byte(received["/pagerduty"][0]), &event))
+	require.Equal(t, "key", event["routing_key"])
+	require.Equal(t, "error", event["payload"].(map[string]any)["severity"])
+
+	require.Equal(t, "system OK", received["/webhook"][0])
+
+	_, err = NewDispatcher(&NotificationsConfig{
+		OnFailure: []NotifierConfig{{Type: "webhook", URL: server.URL, Template: "{{"}},
+	})
+	require.Error(t, err)
+}
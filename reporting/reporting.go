@@ -22,6 +22,7 @@ type Reporter struct {
 	repository        *repository.Repository
 	logger            *logging.Logger
 	emitter           Emitter
+	dispatcher        *Dispatcher
 	currentTask       *ReportTask
 	currentRepository *ReportRepository
 	currentSnapshot   *ReportSnapshot
@@ -64,6 +65,12 @@ func NewReporter(ctx *appcontext.AppContext, reporting bool, repository *reposit
 	}
 }
 
+// WithDispatcher also sends the reports to the notifiers of dispatcher,
+// which is shared between reporters for rate-limiting to apply.
+func (reporter *Reporter) WithDispatcher(dispatcher *Dispatcher) {
+	reporter.dispatcher = dispatcher
+}
+
 func (reporter *Reporter) TaskStart(kind string, name string) {
 	if reporter.currentTask != nil {
 		reporter.logger.Warn("already in a task")
@@ -143,4 +150,7 @@ func (reporter *Reporter) taskEnd(status TaskStatus, errorCode TaskErrorCode, er
 	reporter.currentRepository = nil
 	reporter.currentSnapshot = nil
 	go reporter.emitter.Emit(report, reporter.logger)
+	if reporter.dispatcher != nil {
+		go reporter.dispatcher.Emit(report, reporter.logger)
+	}
 }
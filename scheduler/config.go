@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/PlakarKorp/plakar/reporting"
 	"github.com/go-playground/validator/v10"
 	"github.com/go-viper/mapstructure/v2"
 
@@ -13,7 +14,8 @@ import (
 )
 
 type Configuration struct {
-	Agent AgentConfig `yaml:"agent"`
+	Agent         AgentConfig                   `yaml:"agent"`
+	Notifications reporting.NotificationsConfig `yaml:"notifications"`
 }
 
 type AgentConfig struct {
@@ -11,9 +11,10 @@ import (
 )
 
 type Scheduler struct {
-	config *Configuration
-	ctx    *appcontext.AppContext
-	wg     sync.WaitGroup
+	config   *Configuration
+	ctx      *appcontext.AppContext
+	wg       sync.WaitGroup
+	notifier *reporting.Dispatcher
 }
 
 func stringToDuration(s string) (time.Duration, error) {
@@ -24,11 +25,12 @@ func stringToDuration(s string) (time.Duration, error) {
 	return d, nil
 }
 
-func NewScheduler(ctx *appcontext.AppContext, config *Configuration) *Scheduler {
+func NewScheduler(ctx *appcontext.AppContext, config *Configuration, notifier *reporting.Dispatcher) *Scheduler {
 	return &Scheduler{
-		ctx:    ctx,
-		config: config,
-		wg:     sync.WaitGroup{},
+		ctx:      ctx,
+		config:   config,
+		wg:       sync.WaitGroup{},
+		notifier: notifier,
 	}
 }
 
@@ -69,6 +71,7 @@ func (s *Scheduler) NewTaskReporter(ctx *appcontext.AppContext, repo *repository
 		}
 	}
 	reporter := reporting.NewReporter(ctx, doReport, repo, s.ctx.GetLogger())
+	reporter.WithDispatcher(s.notifier)
 	reporter.TaskStart(taskType, taskName)
 	reporter.WithRepositoryName(repoName)
 	reporter.WithRepository(repo)
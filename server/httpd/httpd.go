@@ -3,11 +3,18 @@ package httpd
 import (
 	"bytes"
 	"context"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/PlakarKorp/kloset/objects"
 	"github.com/PlakarKorp/kloset/repository"
 	"github.com/PlakarKorp/kloset/storage"
 	"github.com/PlakarKorp/plakar/network"
@@ -218,6 +225,99 @@ func (s *server) GetPackfileBlob(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// parseRange parses a Range header asking for a single range with both
+// bounds, the only form clients fetching a blob send.
+func parseRange(header string) (offset uint64, length uint32, ok bool) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	first, last, found := strings.Cut(spec, "-")
+	if !found {
+		return 0, 0, false
+	}
+
+	start, err := strconv.ParseUint(strings.TrimSpace(first), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	end, err := strconv.ParseUint(strings.TrimSpace(last), 10, 64)
+	if err != nil || end < start || end-start >= math.MaxUint32 {
+		return 0, 0, false
+	}
+	return start, uint32(end - start + 1), true
+}
+
+// getPackfileRange serves the raw packfile and honors Range requests,
+// so that clients fetching a single blob don't transfer the whole
+// packfile over the network.
+func (s *server) getPackfileRange(w http.ResponseWriter, r *http.Request) {
+	var mac objects.MAC
+	b, err := hex.DecodeString(r.PathValue("mac"))
+	if err != nil || len(b) != len(mac) {
+		http.Error(w, "invalid packfile MAC", http.StatusBadRequest)
+		return
+	}
+	copy(mac[:], b)
+
+	// a single range is read from the store directly rather than
+	// out of the whole packfile
+	if offset, length, ok := parseRange(r.Header.Get("Range")); ok {
+		rd, err := s.store.GetPackfileBlob(mac, offset, length)
+		if err != nil {
+			if errors.Is(err, repository.ErrPackfileNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		if closer, ok := rd.(io.Closer); ok {
+			defer closer.Close()
+		}
+		data, err := io.ReadAll(rd)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(data) == 0 {
+			// the range starts past the end of the packfile
+			http.Error(w, "range not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/*", offset, offset+uint64(len(data))-1))
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(data)
+		return
+	}
+
+	rd, err := s.store.GetPackfile(mac)
+	if err != nil {
+		if errors.Is(err, repository.ErrPackfileNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if closer, ok := rd.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if rs, ok := rd.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, "", time.Time{}, rs)
+		return
+	}
+
+	// other ranges can't be served without reading the packfile into
+	// memory, the whole of it is sent instead
+	io.Copy(w, rd)
+}
+
 func (s *server) deletePackfile(w http.ResponseWriter, r *http.Request) {
 	if s.noDelete {
 		http.Error(w, fmt.Errorf("not allowed to delete").Error(), http.StatusForbidden)
@@ -343,6 +443,7 @@ func Server(ctx context.Context, repo *repository.Repository, addr string, noDel
 	mux.HandleFunc("PUT /packfile", s.putPackfile)
 	mux.HandleFunc("GET /packfile", s.getPackfile)
 	mux.HandleFunc("GET /packfile/blob", s.GetPackfileBlob)
+	mux.HandleFunc("GET /packfile/{mac}", s.getPackfileRange)
 	mux.HandleFunc("DELETE /packfile", s.deletePackfile)
 
 	mux.HandleFunc("GET /locks", s.getLocks)
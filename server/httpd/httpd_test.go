@@ -0,0 +1,134 @@
+package httpd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/storage"
+	ptesting "github.com/PlakarKorp/plakar/testing"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore hands out packfiles read in memory, that can be seeked
+// unless stream is set.
+type memoryStore struct {
+	storage.Store
+	stream bool
+}
+
+func (s memoryStore) GetPackfile(mac objects.MAC) (io.Reader, error) {
+	rd, err := s.Store.GetPackfile(mac)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(rd)
+	if closer, ok := rd.(io.Closer); ok {
+		closer.Close()
+	}
+	if s.stream {
+		return io.MultiReader(bytes.NewReader(data)), err
+	}
+	return bytes.NewReader(data), err
+}
+
+// GetPackfileBlob returns whatever part of the range is in the
+// packfile, possibly nothing.
+func (s memoryStore) GetPackfileBlob(mac objects.MAC, offset uint64, length uint32) (io.Reader, error) {
+	rd, err := s.GetPackfile(mac)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+	start := min(offset, uint64(len(data)))
+	end := min(offset+uint64(length), uint64(len(data)))
+	return bytes.NewReader(data[start:end]), nil
+}
+
+func TestParseRange(t *testing.T) {
+	offset, length, ok := parseRange("bytes=10-19")
+	require.True(t, ok)
+	require.Equal(t, uint64(10), offset)
+	require.Equal(t, uint32(10), length)
+
+	for _, header := range []string{"", "bytes=10-", "bytes=-10", "bytes=0-1,4-5", "bytes=5-1", "items=0-1", "bytes=a-b"} {
+		_, _, ok := parseRange(header)
+		require.False(t, ok, header)
+	}
+}
+
+func TestGetPackfileRange(t *testing.T) {
+	repo, _ := ptesting.GenerateRepository(t, nil, nil, nil)
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/dummy.txt", 0644, "hello dummy"),
+	})
+	snap.Close()
+
+	packfiles, err := repo.Store().GetPackfiles()
+	require.NoError(t, err)
+	require.NotEmpty(t, packfiles)
+	mac := packfiles[0]
+
+	rd, err := repo.Store().GetPackfile(mac)
+	require.NoError(t, err)
+	packfile, err := io.ReadAll(rd)
+	require.NoError(t, err)
+	require.Greater(t, len(packfile), 16)
+
+	get := func(store storage.Store, rangeHeader string) *http.Response {
+		s := &server{store: store}
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /packfile/{mac}", s.getPackfileRange)
+
+		req := httptest.NewRequest("GET", fmt.Sprintf("/packfile/%x", mac), nil)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	body := func(res *http.Response) []byte {
+		data, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return data
+	}
+
+	seekable := memoryStore{Store: repo.Store()}
+	stream := memoryStore{Store: repo.Store(), stream: true}
+
+	for _, store := range []storage.Store{repo.Store(), seekable, stream} {
+		// a single range is read from the store
+		res := get(store, "bytes=4-11")
+		require.Equal(t, http.StatusPartialContent, res.StatusCode)
+		require.Equal(t, "bytes 4-11/*", res.Header.Get("Content-Range"))
+		require.Equal(t, packfile[4:12], body(res))
+
+		res = get(store, "")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, packfile, body(res))
+	}
+
+	// a range starting past the end of the packfile yields no data
+	res := get(seekable, fmt.Sprintf("bytes=%d-%d", len(packfile), len(packfile)+3))
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, res.StatusCode)
+
+	// other ranges are served from seekable packfiles...
+	res = get(seekable, "bytes=-4")
+	require.Equal(t, http.StatusPartialContent, res.StatusCode)
+	require.Equal(t, packfile[len(packfile)-4:], body(res))
+
+	// ...and ignored otherwise
+	res = get(stream, "bytes=-4")
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, packfile, body(res))
+}
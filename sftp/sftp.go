@@ -13,6 +13,7 @@ import (
 	"os/exec"
 	"os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -35,7 +36,9 @@ type config struct {
 	proxyCmd           string
 }
 
-func Connect(endpoint *url.URL, params map[string]string) (*sftp.Client, error) {
+// sshArgs returns the arguments of the ssh command connecting to
+// endpoint with the given connector parameters.
+func sshArgs(endpoint *url.URL, params map[string]string) ([]string, error) {
 	var args []string
 
 	// Due to the agent, we can't have anything interactive right now (password/known host check etc)
@@ -50,6 +53,24 @@ func Connect(endpoint *url.URL, params map[string]string) (*sftp.Client, error)
 		args = append(args, "-i", id)
 	}
 
+	if value := params["connect_timeout"]; value != "" {
+		timeout, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid connect_timeout value: %w", err)
+		}
+		args = append(args, "-o", fmt.Sprintf("ConnectTimeout=%d", timeout))
+	}
+
+	// Without keepalives, a connection idling behind a NAT or firewall
+	// is silently dropped and the next operation hangs.
+	if value := params["keepalive_interval"]; value != "" {
+		interval, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keepalive_interval value: %w", err)
+		}
+		args = append(args, "-o", fmt.Sprintf("ServerAliveInterval=%d", interval))
+	}
+
 	if endpoint.User != nil && params["username"] != "" {
 		return nil, fmt.Errorf("can not use user@host foo syntax and username parameter.")
 	} else if endpoint.User != nil {
@@ -67,6 +88,15 @@ func Connect(endpoint *url.URL, params map[string]string) (*sftp.Client, error)
 	// This one must be after the host, tell the ssh command to load the sftp subsystem
 	args = append(args, "-s", "sftp")
 
+	return args, nil
+}
+
+func Connect(endpoint *url.URL, params map[string]string) (*sftp.Client, error) {
+	args, err := sshArgs(endpoint, params)
+	if err != nil {
+		return nil, err
+	}
+
 	cmd := exec.Command("ssh", args...)
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
@@ -0,0 +1,35 @@
+package sftp
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSHArgs(t *testing.T) {
+	endpoint, err := url.Parse("sftp://backup@example.org:2222/backups")
+	require.NoError(t, err)
+
+	args, err := sshArgs(endpoint, map[string]string{
+		"connect_timeout":    "10",
+		"keepalive_interval": "30",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"-o", "BatchMode=yes",
+		"-o", "ConnectTimeout=10",
+		"-o", "ServerAliveInterval=30",
+		"-l", "backup",
+		"-p", "2222",
+		"example.org",
+		"-s", "sftp",
+	}, args)
+
+	for _, option := range []string{"connect_timeout", "keepalive_interval"} {
+		for _, value := range []string{"10s", "-1", "1.5", "soon", "99999999999"} {
+			_, err := sshArgs(endpoint, map[string]string{option: value})
+			require.ErrorContains(t, err, "invalid "+option+" value", "%s=%s", option, value)
+		}
+	}
+}
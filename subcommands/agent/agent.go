@@ -36,6 +36,7 @@ import (
 	"github.com/PlakarKorp/kloset/storage"
 	"github.com/PlakarKorp/plakar/agent"
 	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/reporting"
 	"github.com/PlakarKorp/plakar/scheduler"
 	"github.com/PlakarKorp/plakar/subcommands"
 	"github.com/PlakarKorp/plakar/task"
@@ -125,6 +126,7 @@ type AgentContext struct {
 	schedulerCtx    *appcontext.AppContext
 	schedulerConfig *scheduler.Configuration
 	schedulerState  schedulerState
+	notifier        *reporting.Dispatcher
 	mtx             sync.Mutex
 }
 
@@ -470,7 +472,11 @@ func handleClient(ctx *appcontext.AppContext, wg *sync.WaitGroup, conn net.Conn)
 		eventsDone <- struct{}{}
 	}()
 
-	status, err := task.RunCommand(clientContext, subcommand, repo, "@agent")
+	agentContextSingleton.mtx.Lock()
+	notifier := agentContextSingleton.notifier
+	agentContextSingleton.mtx.Unlock()
+
+	status, err := task.RunCommand(clientContext, subcommand, repo, "@agent", notifier)
 
 	errStr := ""
 	if err != nil {
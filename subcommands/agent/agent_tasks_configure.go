@@ -7,6 +7,7 @@ import (
 
 	"github.com/PlakarKorp/plakar/appcontext"
 	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/plakar/reporting"
 	"github.com/PlakarKorp/plakar/scheduler"
 	"github.com/PlakarKorp/plakar/subcommands"
 )
@@ -53,6 +54,11 @@ func (cmd *AgentTasksConfigure) Execute(ctx *appcontext.AppContext, repo *reposi
 		return 1, err
 	}
 
+	notifier, err := reporting.NewDispatcher(&schedConfig.Notifications)
+	if err != nil {
+		return 1, err
+	}
+
 	agentContextSingleton.mtx.Lock()
 	defer agentContextSingleton.mtx.Unlock()
 
@@ -61,11 +67,12 @@ func (cmd *AgentTasksConfigure) Execute(ctx *appcontext.AppContext, repo *reposi
 		agentContextSingleton.schedulerCtx.Cancel()
 		agentContextSingleton.schedulerCtx = appcontext.NewAppContextFrom(agentContextSingleton.agentCtx)
 
-		go scheduler.NewScheduler(agentContextSingleton.schedulerCtx, schedConfig).Run()
+		go scheduler.NewScheduler(agentContextSingleton.schedulerCtx, schedConfig, notifier).Run()
 
 		fmt.Fprintf(ctx.Stderr, "done !\n")
 	}
 
 	agentContextSingleton.schedulerConfig = schedConfig
+	agentContextSingleton.notifier = notifier
 	return 0, nil
 }
@@ -47,7 +47,7 @@ func (cmd *AgentTasksStart) Execute(ctx *appcontext.AppContext, repo *repository
 
 	// this needs to execute in the agent context, not the client context
 	agentContextSingleton.schedulerCtx = appcontext.NewAppContextFrom(agentContextSingleton.agentCtx)
-	go scheduler.NewScheduler(agentContextSingleton.schedulerCtx, agentContextSingleton.schedulerConfig).Run()
+	go scheduler.NewScheduler(agentContextSingleton.schedulerCtx, agentContextSingleton.schedulerConfig, agentContextSingleton.notifier).Run()
 
 	agentContextSingleton.schedulerState = AGENT_SCHEDULER_RUNNING
 	return 0, nil
@@ -43,7 +43,7 @@ func (cmd *Archive) Parse(ctx *appcontext.AppContext, args []string) error {
 
 	flags.StringVar(&cmd.Output, "output", "", "archive pathname")
 	flags.BoolVar(&cmd.Rebase, "rebase", false, "strip pathname when pulling")
-	flags.StringVar(&cmd.Format, "format", "tarball", "archive format: tar, tarball, zip")
+	flags.StringVar(&cmd.Format, "format", "tarball", "archive format: tar, tarball, zip, restic")
 	flags.Parse(args)
 
 	if flags.NArg() == 0 {
@@ -55,6 +55,7 @@ func (cmd *Archive) Parse(ctx *appcontext.AppContext, args []string) error {
 		"tar":     "tar",
 		"tarball": "tar.gz",
 		"zip":     "zip",
+		"restic":  "restic",
 	}
 	if _, ok := supportedFormats[cmd.Format]; !ok {
 		return fmt.Errorf("unsupported format %s", cmd.Format)
@@ -64,6 +65,10 @@ func (cmd *Archive) Parse(ctx *appcontext.AppContext, args []string) error {
 		cmd.Output = fmt.Sprintf("plakar-%s.%s", time.Now().UTC().Format(time.RFC3339), supportedFormats[cmd.Format])
 	}
 
+	if cmd.Format == "restic" && cmd.Output == "-" {
+		return fmt.Errorf("restic repositories can not be written to stdout")
+	}
+
 	return nil
 }
 
@@ -83,6 +88,20 @@ func (cmd *Archive) Execute(ctx *appcontext.AppContext, repo *repository.Reposit
 	}
 	defer snap.Close()
 
+	if cmd.Format == "restic" {
+		password := []byte(os.Getenv("RESTIC_PASSWORD"))
+		if len(password) == 0 {
+			password, err = utils.GetPassphraseConfirm("restic repository", 0)
+			if err != nil {
+				return 1, err
+			}
+		}
+		if err := exportRestic(snap, pathname, cmd.Output, cmd.Rebase, password); err != nil {
+			return 1, fmt.Errorf("archive: %w", err)
+		}
+		return 0, nil
+	}
+
 	var out io.Writer
 	if cmd.Output == "-" {
 		out = ctx.Stdout
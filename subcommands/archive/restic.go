@@ -0,0 +1,556 @@
+package archive
+
+// This file writes a snapshot as a version 1 restic repository, so that
+// it can be restored with restic.  The format is documented at
+// https://restic.readthedocs.io/en/stable/100_references.html and only
+// the subset needed to hold a single snapshot is implemented: blobs are
+// neither compressed nor content-defined chunked.
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PlakarKorp/kloset/snapshot"
+	"github.com/PlakarKorp/kloset/snapshot/vfs"
+	"golang.org/x/crypto/poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	resticBlobSize = 4 << 20
+	resticPackSize = 16 << 20
+
+	// an irreducible polynomial of degree 53, restic refuses to open a
+	// repository whose configuration does not have one.
+	resticChunkerPolynomial = 0x3DA3358B4DC173
+
+	resticScryptN = 32768
+	resticScryptR = 8
+	resticScryptP = 1
+)
+
+type resticID [sha256.Size]byte
+
+func (id resticID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(id[:]))
+}
+
+type resticKey struct {
+	encrypt [32]byte
+	macK    [16]byte
+	macR    [16]byte
+}
+
+func newResticKey(material []byte) *resticKey {
+	key := &resticKey{}
+	copy(key.encrypt[:], material[:32])
+	copy(key.macK[:], material[32:48])
+	copy(key.macR[:], material[48:64])
+
+	// poly1305 requires some bits of r to be cleared
+	mask := [16]byte{0xff, 0xff, 0xff, 0x0f, 0xfc, 0xff, 0xff, 0x0f, 0xfc, 0xff, 0xff, 0x0f, 0xfc, 0xff, 0xff, 0x0f}
+	for i := range key.macR {
+		key.macR[i] &= mask[i]
+	}
+	return key
+}
+
+// seal encrypts plaintext as IV || AES-256-CTR(plaintext) || Poly1305-AES.
+func (key *resticKey) seal(plaintext []byte) ([]byte, error) {
+	out := make([]byte, aes.BlockSize+len(plaintext)+poly1305.TagSize)
+
+	nonce := out[:aes.BlockSize]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key.encrypt[:])
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := out[aes.BlockSize : aes.BlockSize+len(plaintext)]
+	cipher.NewCTR(block, nonce).XORKeyStream(ciphertext, plaintext)
+
+	macBlock, err := aes.NewCipher(key.macK[:])
+	if err != nil {
+		return nil, err
+	}
+	var polyKey [32]byte
+	copy(polyKey[:16], key.macR[:])
+	macBlock.Encrypt(polyKey[16:], nonce)
+
+	var tag [poly1305.TagSize]byte
+	poly1305.Sum(&tag, ciphertext, &polyKey)
+	copy(out[aes.BlockSize+len(plaintext):], tag[:])
+
+	return out, nil
+}
+
+type resticBlob struct {
+	ID     resticID `json:"id"`
+	Type   string   `json:"type"`
+	Offset int      `json:"offset"`
+	Length int      `json:"length"`
+}
+
+type resticPack struct {
+	ID    resticID     `json:"id"`
+	Blobs []resticBlob `json:"blobs"`
+}
+
+type resticNode struct {
+	Name       string      `json:"name"`
+	Type       string      `json:"type"`
+	Mode       os.FileMode `json:"mode,omitempty"`
+	ModTime    time.Time   `json:"mtime,omitempty"`
+	AccessTime time.Time   `json:"atime,omitempty"`
+	ChangeTime time.Time   `json:"ctime,omitempty"`
+	UID        uint64      `json:"uid"`
+	GID        uint64      `json:"gid"`
+	User       string      `json:"user,omitempty"`
+	Group      string      `json:"group,omitempty"`
+	Inode      uint64      `json:"inode,omitempty"`
+	Size       uint64      `json:"size,omitempty"`
+	Links      uint64      `json:"links,omitempty"`
+	LinkTarget string      `json:"linktarget,omitempty"`
+	Device     uint64      `json:"device,omitempty"`
+	Content    []resticID  `json:"content"`
+	Subtree    *resticID   `json:"subtree,omitempty"`
+}
+
+type resticWriter struct {
+	root string
+	key  *resticKey
+
+	known map[resticID]struct{}
+	pack  bytes.Buffer
+	blobs []resticBlob
+	index []resticPack
+}
+
+func exportRestic(snap *snapshot.Snapshot, pathname, output string, rebase bool, password []byte) error {
+	if _, err := os.Stat(output); err == nil {
+		return fmt.Errorf("%s: already exists", output)
+	}
+
+	for _, dir := range []string{"data", "index", "keys", "locks", "snapshots"} {
+		if err := os.MkdirAll(filepath.Join(output, dir), 0700); err != nil {
+			return err
+		}
+	}
+	for i := range 256 {
+		if err := os.MkdirAll(filepath.Join(output, "data", fmt.Sprintf("%02x", i)), 0700); err != nil {
+			return err
+		}
+	}
+
+	material := make([]byte, 64)
+	if _, err := rand.Read(material); err != nil {
+		return err
+	}
+
+	w := &resticWriter{
+		root:  output,
+		key:   newResticKey(material),
+		known: make(map[resticID]struct{}),
+	}
+
+	if err := w.writeKey(password, material); err != nil {
+		return err
+	}
+	if err := w.writeConfig(); err != nil {
+		return err
+	}
+
+	fs, err := snap.Filesystem()
+	if err != nil {
+		return err
+	}
+
+	entry, err := fs.GetEntry(pathname)
+	if err != nil {
+		return err
+	}
+
+	var tree resticID
+	paths := []string{pathname}
+	if rebase || pathname == "/" {
+		if entry.IsDir() {
+			tree, err = w.saveDir(fs, entry, "")
+		} else {
+			var node *resticNode
+			if node, err = w.saveNode(fs, entry); err == nil {
+				tree, err = w.saveTree([]resticNode{*node})
+			}
+		}
+		if rebase {
+			paths = []string{"/"}
+		}
+	} else {
+		var root *vfs.Entry
+		if root, err = fs.GetEntry("/"); err == nil {
+			tree, err = w.saveDir(fs, root, pathname)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := w.flushPack(); err != nil {
+		return err
+	}
+
+	if _, err := w.writeJSON("index", struct {
+		Packs []resticPack `json:"packs"`
+	}{w.index}); err != nil {
+		return err
+	}
+
+	source := snap.Header.GetSource(0)
+	_, err = w.writeJSON("snapshots", struct {
+		Time     time.Time `json:"time"`
+		Tree     resticID  `json:"tree"`
+		Paths    []string  `json:"paths"`
+		Hostname string    `json:"hostname,omitempty"`
+		Tags     []string  `json:"tags,omitempty"`
+	}{
+		Time:     snap.Header.Timestamp,
+		Tree:     tree,
+		Paths:    paths,
+		Hostname: source.Importer.Origin,
+		Tags:     snap.Header.Tags,
+	})
+	return err
+}
+
+// saveDir stores the tree for dir.  When only is set, dir is an ancestor
+// of it and the tree only holds the child leading to it, like restic
+// does for the parents of the paths it backs up.
+func (w *resticWriter) saveDir(fs *vfs.Filesystem, dir *vfs.Entry, only string) (resticID, error) {
+	children, err := dir.Getdents(fs)
+	if err != nil {
+		return resticID{}, err
+	}
+
+	nodes := make([]resticNode, 0)
+	for child, err := range children {
+		if err != nil {
+			return resticID{}, err
+		}
+
+		var node *resticNode
+		if only != "" {
+			childpath := child.Path()
+			if childpath == only {
+				node, err = w.saveNode(fs, child)
+			} else if strings.HasPrefix(only, childpath+"/") {
+				node, err = w.nodeFor(child)
+				if err == nil {
+					var subtree resticID
+					subtree, err = w.saveDir(fs, child, only)
+					node.Subtree = &subtree
+				}
+			} else {
+				continue
+			}
+		} else {
+			node, err = w.saveNode(fs, child)
+		}
+		if err != nil {
+			return resticID{}, err
+		}
+		nodes = append(nodes, *node)
+	}
+
+	return w.saveTree(nodes)
+}
+
+func (w *resticWriter) nodeFor(entry *vfs.Entry) (*resticNode, error) {
+	fi := entry.Stat()
+	mode := fi.Mode()
+
+	node := &resticNode{
+		Name:       fi.Name(),
+		Mode:       mode,
+		ModTime:    fi.ModTime(),
+		AccessTime: fi.ModTime(),
+		ChangeTime: fi.ModTime(),
+		UID:        fi.Uid(),
+		GID:        fi.Gid(),
+		User:       fi.Username(),
+		Group:      fi.Groupname(),
+		Inode:      fi.Ino(),
+		Links:      uint64(fi.Nlink()),
+	}
+
+	switch {
+	case mode.IsDir():
+		node.Type = "dir"
+	case mode.IsRegular():
+		node.Type = "file"
+		node.Size = uint64(fi.Size())
+	case mode&os.ModeSymlink != 0:
+		node.Type = "symlink"
+		node.LinkTarget = entry.SymlinkTarget
+	case mode&os.ModeCharDevice != 0:
+		node.Type = "chardev"
+		node.Device = fi.Dev()
+	case mode&os.ModeDevice != 0:
+		node.Type = "dev"
+		node.Device = fi.Dev()
+	case mode&os.ModeNamedPipe != 0:
+		node.Type = "fifo"
+	case mode&os.ModeSocket != 0:
+		node.Type = "socket"
+	default:
+		return nil, fmt.Errorf("%s: unsupported file type %s", entry.Path(), mode.Type())
+	}
+
+	return node, nil
+}
+
+func (w *resticWriter) saveNode(fs *vfs.Filesystem, entry *vfs.Entry) (*resticNode, error) {
+	node, err := w.nodeFor(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	switch node.Type {
+	case "dir":
+		subtree, err := w.saveDir(fs, entry, "")
+		if err != nil {
+			return nil, err
+		}
+		node.Subtree = &subtree
+
+	case "file":
+		node.Content = []resticID{}
+		if node.Size == 0 {
+			break
+		}
+
+		fp := entry.Open(fs)
+		defer fp.Close()
+
+		buf := make([]byte, resticBlobSize)
+		for {
+			n, err := io.ReadFull(fp, buf)
+			if n > 0 {
+				id, err := w.saveBlob("data", buf[:n])
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, id)
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", entry.Path(), err)
+			}
+		}
+	}
+
+	return node, nil
+}
+
+func (w *resticWriter) saveTree(nodes []resticNode) (resticID, error) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+
+	data, err := json.Marshal(struct {
+		Nodes []resticNode `json:"nodes"`
+	}{nodes})
+	if err != nil {
+		return resticID{}, err
+	}
+	data = append(data, '\n')
+
+	return w.saveBlob("tree", data)
+}
+
+func (w *resticWriter) saveBlob(blobType string, data []byte) (resticID, error) {
+	id := resticID(sha256.Sum256(data))
+	if _, ok := w.known[id]; ok {
+		return id, nil
+	}
+
+	ciphertext, err := w.key.seal(data)
+	if err != nil {
+		return id, err
+	}
+
+	w.blobs = append(w.blobs, resticBlob{
+		ID:     id,
+		Type:   blobType,
+		Offset: w.pack.Len(),
+		Length: len(ciphertext),
+	})
+	w.pack.Write(ciphertext)
+	w.known[id] = struct{}{}
+
+	if w.pack.Len() >= resticPackSize {
+		return id, w.flushPack()
+	}
+	return id, nil
+}
+
+// flushPack appends the encrypted header, made of one entry per blob, and
+// its length to the pending pack and writes it to data/.
+func (w *resticWriter) flushPack() error {
+	if len(w.blobs) == 0 {
+		return nil
+	}
+
+	var header bytes.Buffer
+	for _, blob := range w.blobs {
+		blobType := uint8(0)
+		if blob.Type == "tree" {
+			blobType = 1
+		}
+		header.WriteByte(blobType)
+		binary.Write(&header, binary.LittleEndian, uint32(blob.Length))
+		header.Write(blob.ID[:])
+	}
+
+	sealed, err := w.key.seal(header.Bytes())
+	if err != nil {
+		return err
+	}
+	w.pack.Write(sealed)
+	binary.Write(&w.pack, binary.LittleEndian, uint32(len(sealed)))
+
+	id := resticID(sha256.Sum256(w.pack.Bytes()))
+	name := hex.EncodeToString(id[:])
+	if err := os.WriteFile(filepath.Join(w.root, "data", name[:2], name), w.pack.Bytes(), 0400); err != nil {
+		return err
+	}
+
+	w.index = append(w.index, resticPack{ID: id, Blobs: w.blobs})
+	w.blobs = nil
+	w.pack.Reset()
+	return nil
+}
+
+// writeJSON stores an encrypted JSON document in dir, named after the
+// hash of its content.
+func (w *resticWriter) writeJSON(dir string, v any) (resticID, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return resticID{}, err
+	}
+
+	sealed, err := w.key.seal(data)
+	if err != nil {
+		return resticID{}, err
+	}
+
+	id := resticID(sha256.Sum256(sealed))
+	return id, os.WriteFile(filepath.Join(w.root, dir, hex.EncodeToString(id[:])), sealed, 0400)
+}
+
+func (w *resticWriter) writeConfig() error {
+	repositoryID := make([]byte, 32)
+	if _, err := rand.Read(repositoryID); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(struct {
+		Version           int    `json:"version"`
+		ID                string `json:"id"`
+		ChunkerPolynomial string `json:"chunker_polynomial"`
+	}{
+		Version:           1,
+		ID:                hex.EncodeToString(repositoryID),
+		ChunkerPolynomial: fmt.Sprintf("%x", resticChunkerPolynomial),
+	})
+	if err != nil {
+		return err
+	}
+
+	sealed, err := w.key.seal(data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(w.root, "config"), sealed, 0400)
+}
+
+// writeKey stores the master key encrypted with a key derived from the
+// password, which is what restic asks for when opening the repository.
+func (w *resticWriter) writeKey(password, material []byte) error {
+	salt := make([]byte, 64)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	derived, err := scrypt.Key(password, salt, resticScryptN, resticScryptR, resticScryptP, 64)
+	if err != nil {
+		return err
+	}
+	userKey := newResticKey(derived)
+
+	masterKey, err := json.Marshal(struct {
+		MAC struct {
+			K []byte `json:"k"`
+			R []byte `json:"r"`
+		} `json:"mac"`
+		Encrypt []byte `json:"encrypt"`
+	}{
+		MAC: struct {
+			K []byte `json:"k"`
+			R []byte `json:"r"`
+		}{w.key.macK[:], w.key.macR[:]},
+		Encrypt: w.key.encrypt[:],
+	})
+	if err != nil {
+		return err
+	}
+
+	sealed, err := userKey.seal(masterKey)
+	if err != nil {
+		return err
+	}
+
+	hostname, _ := os.Hostname()
+	data, err := json.Marshal(struct {
+		Created  time.Time `json:"created"`
+		Username string    `json:"username"`
+		Hostname string    `json:"hostname"`
+		KDF      string    `json:"kdf"`
+		N        int       `json:"N"`
+		R        int       `json:"r"`
+		P        int       `json:"p"`
+		Salt     []byte    `json:"salt"`
+		Data     []byte    `json:"data"`
+	}{
+		Created:  time.Now(),
+		Username: os.Getenv("USER"),
+		Hostname: hostname,
+		KDF:      "scrypt",
+		N:        resticScryptN,
+		R:        resticScryptR,
+		P:        resticScryptP,
+		Salt:     salt,
+		Data:     sealed,
+	})
+	if err != nil {
+		return err
+	}
+
+	id := sha256.Sum256(data)
+	return os.WriteFile(filepath.Join(w.root, "keys", hex.EncodeToString(id[:])), data, 0400)
+}
@@ -0,0 +1,321 @@
+package archive
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ptesting "github.com/PlakarKorp/plakar/testing"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// resticReader reads back a restic repository the way restic does,
+// independently of the writer.
+type resticReader struct {
+	t    *testing.T
+	root string
+
+	encrypt []byte
+	macK    []byte
+	macR    []byte
+
+	blobs map[resticID][]byte
+}
+
+// resticOpen checks the MAC of IV || ciphertext || MAC and decrypts it.
+func resticOpen(encrypt, macK, macR, sealed []byte) ([]byte, error) {
+	if len(sealed) < aes.BlockSize+poly1305.TagSize {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	nonce := sealed[:aes.BlockSize]
+	ciphertext := sealed[aes.BlockSize : len(sealed)-poly1305.TagSize]
+
+	var tag [poly1305.TagSize]byte
+	copy(tag[:], sealed[len(sealed)-poly1305.TagSize:])
+
+	var polyKey [32]byte
+	mask := []byte{0xff, 0xff, 0xff, 0x0f, 0xfc, 0xff, 0xff, 0x0f, 0xfc, 0xff, 0xff, 0x0f, 0xfc, 0xff, 0xff, 0x0f}
+	for i := range 16 {
+		polyKey[i] = macR[i] & mask[i]
+	}
+	macBlock, err := aes.NewCipher(macK)
+	if err != nil {
+		return nil, err
+	}
+	macBlock.Encrypt(polyKey[16:], nonce)
+	if !poly1305.Verify(&tag, ciphertext, &polyKey) {
+		return nil, fmt.Errorf("MAC mismatch")
+	}
+
+	block, err := aes.NewCipher(encrypt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCTR(block, nonce).XORKeyStream(plaintext, ciphertext)
+	return plaintext, nil
+}
+
+func (r *resticReader) decrypt(sealed []byte) []byte {
+	plaintext, err := resticOpen(r.encrypt, r.macK, r.macR, sealed)
+	require.NoError(r.t, err)
+	return plaintext
+}
+
+func (r *resticReader) readFile(name string) []byte {
+	data, err := os.ReadFile(filepath.Join(r.root, name))
+	require.NoError(r.t, err)
+	return data
+}
+
+func (r *resticReader) list(dir string) []string {
+	entries, err := os.ReadDir(filepath.Join(r.root, dir))
+	require.NoError(r.t, err)
+
+	ret := []string{}
+	for _, entry := range entries {
+		ret = append(ret, filepath.Join(dir, entry.Name()))
+	}
+	return ret
+}
+
+// unlock derives the user key from password and decrypts the master key
+// with it.
+func (r *resticReader) unlock(password []byte) error {
+	keys := r.list("keys")
+	require.Len(r.t, keys, 1)
+
+	var key struct {
+		KDF  string `json:"kdf"`
+		N    int    `json:"N"`
+		R    int    `json:"r"`
+		P    int    `json:"p"`
+		Salt []byte `json:"salt"`
+		Data []byte `json:"data"`
+	}
+	require.NoError(r.t, json.Unmarshal(r.readFile(keys[0]), &key))
+	require.Equal(r.t, "scrypt", key.KDF)
+
+	derived, err := scrypt.Key(password, key.Salt, key.N, key.R, key.P, 64)
+	require.NoError(r.t, err)
+
+	plaintext, err := resticOpen(derived[:32], derived[32:48], derived[48:64], key.Data)
+	if err != nil {
+		return err
+	}
+
+	var master struct {
+		MAC struct {
+			K []byte `json:"k"`
+			R []byte `json:"r"`
+		} `json:"mac"`
+		Encrypt []byte `json:"encrypt"`
+	}
+	require.NoError(r.t, json.Unmarshal(plaintext, &master))
+	require.Len(r.t, master.Encrypt, 32)
+	require.Len(r.t, master.MAC.K, 16)
+	require.Len(r.t, master.MAC.R, 16)
+
+	r.encrypt = master.Encrypt
+	r.macK = master.MAC.K
+	r.macR = master.MAC.R
+	return nil
+}
+
+// loadPacks checks every pack against its header and the index, and
+// keeps the plaintext of the blobs they hold.
+func (r *resticReader) loadPacks() {
+	indexes := r.list("index")
+	require.Len(r.t, indexes, 1)
+
+	var index struct {
+		Packs []struct {
+			ID    string `json:"id"`
+			Blobs []struct {
+				ID     string `json:"id"`
+				Type   string `json:"type"`
+				Offset int    `json:"offset"`
+				Length int    `json:"length"`
+			} `json:"blobs"`
+		} `json:"packs"`
+	}
+	require.NoError(r.t, json.Unmarshal(r.decrypt(r.readFile(indexes[0])), &index))
+	require.NotEmpty(r.t, index.Packs)
+
+	r.blobs = make(map[resticID][]byte)
+	for _, pack := range index.Packs {
+		data := r.readFile(filepath.Join("data", pack.ID[:2], pack.ID))
+		sum := sha256.Sum256(data)
+		require.Equal(r.t, pack.ID, hex.EncodeToString(sum[:]))
+
+		headerLength := int(binary.LittleEndian.Uint32(data[len(data)-4:]))
+		headerStart := len(data) - 4 - headerLength
+		header := r.decrypt(data[headerStart : len(data)-4])
+
+		const entrySize = 1 + 4 + sha256.Size
+		require.Equal(r.t, len(pack.Blobs)*entrySize, len(header))
+
+		offset := 0
+		for i, blob := range pack.Blobs {
+			entry := header[i*entrySize : (i+1)*entrySize]
+
+			blobType := "data"
+			if entry[0] == 1 {
+				blobType = "tree"
+			}
+			require.Equal(r.t, blob.Type, blobType)
+			require.Equal(r.t, blob.Length, int(binary.LittleEndian.Uint32(entry[1:5])))
+			require.Equal(r.t, blob.ID, hex.EncodeToString(entry[5:]))
+			require.Equal(r.t, offset, blob.Offset)
+			offset += blob.Length
+
+			plaintext := r.decrypt(data[blob.Offset : blob.Offset+blob.Length])
+			id := resticID(sha256.Sum256(plaintext))
+			require.Equal(r.t, blob.ID, hex.EncodeToString(id[:]))
+			r.blobs[id] = plaintext
+		}
+		require.Equal(r.t, headerStart, offset)
+	}
+}
+
+func (r *resticReader) blob(id string) []byte {
+	var mac resticID
+	_, err := hex.Decode(mac[:], []byte(id))
+	require.NoError(r.t, err)
+
+	data, ok := r.blobs[mac]
+	require.True(r.t, ok, "blob %s is not in the index", id)
+	return data
+}
+
+// walk returns the content of the regular files below tree, by path.
+func (r *resticReader) walk(tree, dir string, files map[string]string) {
+	var nodes struct {
+		Nodes []struct {
+			Name    string   `json:"name"`
+			Type    string   `json:"type"`
+			Size    uint64   `json:"size"`
+			Content []string `json:"content"`
+			Subtree string   `json:"subtree"`
+		} `json:"nodes"`
+	}
+	require.NoError(r.t, json.Unmarshal(r.blob(tree), &nodes))
+
+	for _, node := range nodes.Nodes {
+		pathname := path.Join(dir, node.Name)
+		switch node.Type {
+		case "dir":
+			r.walk(node.Subtree, pathname, files)
+		case "file":
+			var content bytes.Buffer
+			for _, id := range node.Content {
+				content.Write(r.blob(id))
+			}
+			require.Equal(r.t, node.Size, uint64(content.Len()))
+			files[pathname] = content.String()
+		}
+	}
+}
+
+func TestExecuteCmdArchiveRestic(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	// larger than a blob, to be split
+	large := strings.Repeat("0123456789abcdef", resticBlobSize/16+1024)
+
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockDir("another_subdir"),
+		ptesting.NewMockFile("subdir/dummy.txt", 0644, "hello dummy"),
+		ptesting.NewMockFile("subdir/same.txt", 0644, "hello dummy"),
+		ptesting.NewMockFile("subdir/empty.txt", 0644, ""),
+		ptesting.NewMockFile("subdir/large.bin", 0644, large),
+		ptesting.NewMockFile("another_subdir/bar.txt", 0644, "hello bar"),
+	})
+	defer snap.Close()
+
+	// what the snapshot holds, to compare against
+	fs, err := snap.Filesystem()
+	require.NoError(t, err)
+	source := make(map[string]string)
+	for entry, err := range fs.Files("/") {
+		require.NoError(t, err)
+		if !entry.FileInfo.Mode().IsRegular() {
+			continue
+		}
+		fp, err := fs.Open(entry.Path())
+		require.NoError(t, err)
+		data, err := io.ReadAll(fp)
+		require.NoError(t, err)
+		fp.Close()
+		source[entry.Path()] = string(data)
+	}
+	require.Len(t, source, 5)
+
+	password := "restic password"
+	t.Setenv("RESTIC_PASSWORD", password)
+
+	output := filepath.Join(t.TempDir(), "restic")
+	indexId := snap.Header.GetIndexID()
+	args := []string{"-format", "restic", "-output", output, hex.EncodeToString(indexId[:])}
+
+	subcommand := &Archive{}
+	require.NoError(t, subcommand.Parse(ctx, args))
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	r := &resticReader{t: t, root: output}
+	require.Error(t, r.unlock([]byte("wrong password")))
+	require.NoError(t, r.unlock([]byte(password)))
+
+	var config struct {
+		Version           int    `json:"version"`
+		ID                string `json:"id"`
+		ChunkerPolynomial string `json:"chunker_polynomial"`
+	}
+	require.NoError(t, json.Unmarshal(r.decrypt(r.readFile("config")), &config))
+	require.Equal(t, 1, config.Version)
+	require.Len(t, config.ID, 64)
+	require.Equal(t, fmt.Sprintf("%x", resticChunkerPolynomial), config.ChunkerPolynomial)
+
+	r.loadPacks()
+
+	snapshots := r.list("snapshots")
+	require.Len(t, snapshots, 1)
+	var resticSnapshot struct {
+		Tree  string   `json:"tree"`
+		Paths []string `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(r.decrypt(r.readFile(snapshots[0])), &resticSnapshot))
+	require.Equal(t, []string{"/"}, resticSnapshot.Paths)
+
+	files := make(map[string]string)
+	r.walk(resticSnapshot.Tree, "/", files)
+	require.Equal(t, source, files)
+
+	// identical contents are stored once
+	count := 0
+	for _, data := range r.blobs {
+		if string(data) == "hello dummy" {
+			count++
+		}
+	}
+	require.Equal(t, 1, count)
+}
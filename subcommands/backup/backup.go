@@ -28,6 +28,7 @@ import (
 	"github.com/PlakarKorp/kloset/snapshot"
 	"github.com/PlakarKorp/kloset/snapshot/importer"
 	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/progress"
 	"github.com/PlakarKorp/plakar/subcommands"
 	"github.com/PlakarKorp/plakar/utils"
 	"github.com/dustin/go-humanize"
@@ -69,10 +70,38 @@ func (e *tagFlags) asList() []string {
 	return strings.Split(string(*e), ",")
 }
 
+// readExcludeFile returns the glob patterns listed in filename, one per
+// line, skipping blank lines and comments starting with '#'.
+func readExcludeFile(filename string) ([]string, error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open excludes file: %w", err)
+	}
+	defer fp.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := glob.Compile(line); err != nil {
+			return nil, fmt.Errorf("%s: failed to compile exclude pattern: %s", filename, line)
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return patterns, nil
+}
+
 func (cmd *Backup) Parse(ctx *appcontext.AppContext, args []string) error {
-	var opt_exclude_file string
+	var opt_exclude_files excludeFlags
 	var opt_exclude excludeFlags
 	var opt_tags tagFlags
+	var opt_exclude_hidden, opt_exclude_system, opt_exclude_temporary, opt_exclude_immutable bool
 
 	excludes := []string{}
 
@@ -88,13 +117,20 @@ func (cmd *Backup) Parse(ctx *appcontext.AppContext, args []string) error {
 
 	flags.Uint64Var(&cmd.Concurrency, "concurrency", uint64(ctx.MaxConcurrency), "maximum number of parallel tasks")
 	flags.Var(&opt_tags, "tag", "comma-separated list of tags to apply to the snapshot")
-	flags.StringVar(&opt_exclude_file, "exclude-file", "", "path to a file containing newline-separated regex patterns, treated as -exclude")
+	flags.Var(&opt_exclude_files, "exclude-from", "path to a file containing newline-separated glob patterns, treated as -exclude, can be specified multiple times")
+	flags.Var(&opt_exclude_files, "exclude-file", "alias for -exclude-from")
 	flags.Var(&opt_exclude, "exclude", "glob pattern to exclude files, can be specified multiple times to add several exclusion patterns")
+	flags.BoolVar(&opt_exclude_hidden, "exclude-hidden", false, "exclude hidden files and directories")
+	flags.BoolVar(&opt_exclude_system, "exclude-system", false, "exclude files and directories with the system attribute (Windows only)")
+	flags.BoolVar(&opt_exclude_temporary, "exclude-temporary", false, "exclude files and directories with the temporary attribute (Windows only)")
+	flags.BoolVar(&opt_exclude_immutable, "exclude-immutable", false, "exclude files and directories flagged as immutable (macOS only)")
 	flags.BoolVar(&cmd.Quiet, "quiet", false, "suppress output")
+	flags.BoolVar(&cmd.Progress, "progress", false, "display a progress bar on the terminal")
 	flags.BoolVar(&cmd.Silent, "silent", false, "suppress ALL output")
 	flags.BoolVar(&cmd.OptCheck, "check", false, "check the snapshot after creating it")
 	flags.Var(utils.NewOptsFlag(cmd.Opts), "o", "specify extra importer options")
 	flags.BoolVar(&cmd.DryRun, "scan", false, "do not actually perform a backup, just list the files")
+	flags.StringVar(&cmd.Since, "since", "", "only read files that changed since the given snapshot")
 	//flags.BoolVar(&opt_stdio, "stdio", false, "output one line per file to stdout instead of the default interactive output")
 	flags.Parse(args)
 
@@ -109,26 +145,23 @@ func (cmd *Backup) Parse(ctx *appcontext.AppContext, args []string) error {
 		excludes = append(excludes, item)
 	}
 
-	if opt_exclude_file != "" {
-		fp, err := os.Open(opt_exclude_file)
+	for _, filename := range opt_exclude_files {
+		patterns, err := readExcludeFile(filename)
 		if err != nil {
-			return fmt.Errorf("unable to open excludes file: %w", err)
-		}
-		defer fp.Close()
-
-		scanner := bufio.NewScanner(fp)
-		for scanner.Scan() {
-			line := scanner.Text()
-			_, err := glob.Compile(line)
-			if err != nil {
-				return fmt.Errorf("failed to compile exclude pattern: %s", line)
-			}
-			excludes = append(excludes, line)
-		}
-		if err := scanner.Err(); err != nil {
-			ctx.GetLogger().Error("%s", err)
 			return err
 		}
+		excludes = append(excludes, patterns...)
+	}
+
+	for option, enabled := range map[string]bool{
+		"exclude_hidden":    opt_exclude_hidden,
+		"exclude_system":    opt_exclude_system,
+		"exclude_temporary": opt_exclude_temporary,
+		"exclude_immutable": opt_exclude_immutable,
+	} {
+		if enabled {
+			cmd.Opts[option] = "true"
+		}
 	}
 
 	cmd.RepositorySecret = ctx.GetSecret()
@@ -152,10 +185,12 @@ type Backup struct {
 	Excludes    []string
 	Silent      bool
 	Quiet       bool
+	Progress    bool
 	Path        string
 	OptCheck    bool
 	Opts        map[string]string
 	DryRun      bool
+	Since       string
 }
 
 func (cmd *Backup) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
@@ -213,6 +248,29 @@ func (cmd *Backup) DoBackup(ctx *appcontext.AppContext, repo *repository.Reposit
 		return 0, nil, objects.MAC{}, nil
 	}
 
+	// With -since, the backup runs against a throwaway cache that only
+	// knows about the given snapshot; the user's cache is put back as
+	// soon as the backup is done.
+	restoreCache := func() {}
+	if cmd.Since != "" {
+		sinceID, err := utils.LocateSnapshotByPrefix(repo, cmd.Since)
+		if err != nil {
+			return 1, err, objects.MAC{}, nil
+		}
+		cache, err := newSinceCache(repo, imp, sinceID)
+		if err != nil {
+			return 1, fmt.Errorf("failed to load snapshot %x: %w", sinceID[:4], err), objects.MAC{}, nil
+		}
+		defer cache.Close()
+		ctx.GetLogger().Info("backup: comparing against %d files of snapshot %x", cache.nfiles, sinceID[:4])
+
+		userCache := ctx.GetCache()
+		ctx.SetCache(cache.Manager)
+		restoreCache = func() { ctx.SetCache(userCache) }
+		defer restoreCache()
+		opts.CleanupVFSCache = true
+	}
+
 	snap, err := snapshot.Create(repo, repository.DefaultType)
 	if err != nil {
 		ctx.GetLogger().Error("%s", err)
@@ -225,16 +283,27 @@ func (cmd *Backup) DoBackup(ctx *appcontext.AppContext, repo *repository.Reposit
 	}
 
 	if cmd.Silent {
-		if err := snap.Backup(imp, opts); err != nil {
+		err := snap.Backup(imp, opts)
+		restoreCache()
+		if err != nil {
 			return 1, fmt.Errorf("failed to create snapshot: %w", err), objects.MAC{}, nil
 		}
 	} else {
-		ep := startEventsProcessor(ctx, imp.Root(), true, cmd.Quiet)
-		if err := snap.Backup(imp, opts); err != nil {
-			ep.Close()
-			return 1, fmt.Errorf("failed to create snapshot: %w", err), objects.MAC{}, nil
+		var tracker *progress.Tracker
+		if cmd.Progress {
+			tracker = progress.NewTracker(os.Stderr)
+			tracker.Start(ctx)
 		}
+		ep := startEventsProcessor(ctx, imp.Root(), true, cmd.Quiet || cmd.Progress)
+		err := snap.Backup(imp, opts)
+		restoreCache()
 		ep.Close()
+		if tracker != nil {
+			tracker.Close()
+		}
+		if err != nil {
+			return 1, fmt.Errorf("failed to create snapshot: %w", err), objects.MAC{}, nil
+		}
 	}
 
 	if cmd.OptCheck {
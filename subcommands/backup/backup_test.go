@@ -11,8 +11,10 @@ import (
 	"github.com/PlakarKorp/kloset/caching"
 	"github.com/PlakarKorp/kloset/hashing"
 	"github.com/PlakarKorp/kloset/logging"
+	"github.com/PlakarKorp/kloset/objects"
 	"github.com/PlakarKorp/kloset/repository"
 	"github.com/PlakarKorp/kloset/resources"
+	"github.com/PlakarKorp/kloset/snapshot"
 	"github.com/PlakarKorp/kloset/storage"
 	"github.com/PlakarKorp/kloset/versioning"
 	"github.com/PlakarKorp/plakar/appcontext"
@@ -167,3 +169,144 @@ func TestExecuteCmdCreateDefaultWithExcludes(t *testing.T) {
 	lastline := lines[len(lines)-1]
 	require.Contains(t, lastline, "created unsigned snapshot")
 }
+
+func TestExecuteCmdCreateWithExcludeFrom(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, tmpBackupDir, ctx := generateFixtures(t, bufOut, bufErr)
+
+	excludeFile := t.TempDir() + "/excludes"
+	err := os.WriteFile(excludeFile, []byte("# skip these\n*/subdir/to_exclude\n\n*/subdir/foo.txt\n*/another_subdir/bar\n"), 0644)
+	require.NoError(t, err)
+
+	ctx.MaxConcurrency = 1
+	args := []string{"-exclude-from", excludeFile, tmpBackupDir}
+
+	subcommand := &Backup{}
+	err = subcommand.Parse(ctx, args)
+	require.NoError(t, err)
+	require.Len(t, subcommand.Excludes, 3)
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	output := bufOut.String()
+	require.Contains(t, output, tmpBackupDir+"/subdir/dummy.txt")
+	require.NotContains(t, output, tmpBackupDir+"/subdir/to_exclude")
+	require.NotContains(t, output, tmpBackupDir+"/subdir/foo.txt")
+	require.NotContains(t, output, tmpBackupDir+"/another_subdir/bar")
+}
+
+func TestExecuteCmdCreateWithMissingExcludeFrom(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	_, tmpBackupDir, ctx := generateFixtures(t, bufOut, bufErr)
+
+	args := []string{"-exclude-from", tmpBackupDir + "/does-not-exist", tmpBackupDir}
+
+	subcommand := &Backup{}
+	err := subcommand.Parse(ctx, args)
+	require.ErrorContains(t, err, "unable to open excludes file")
+}
+
+func TestParseExcludeFlags(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	_, tmpBackupDir, ctx := generateFixtures(t, bufOut, bufErr)
+
+	args := []string{"-exclude-hidden", "-exclude-immutable", tmpBackupDir}
+
+	subcommand := &Backup{}
+	err := subcommand.Parse(ctx, args)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"exclude_hidden":    "true",
+		"exclude_immutable": "true",
+	}, subcommand.Opts)
+}
+
+func TestExecuteCmdCreateSince(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, tmpBackupDir, ctx := generateFixtures(t, bufOut, bufErr)
+	ctx.MaxConcurrency = 1
+
+	// -silent: every run shares the same context and its events.
+	backup := func(args ...string) objects.MAC {
+		subcommand := &Backup{}
+		err := subcommand.Parse(ctx, append(append([]string{"-silent"}, args...), tmpBackupDir))
+		require.NoError(t, err)
+
+		status, err, snapshotID, _ := subcommand.DoBackup(ctx, repo)
+		require.NoError(t, err)
+		require.Equal(t, 0, status)
+		require.NoError(t, repo.RebuildState())
+		return snapshotID
+	}
+
+	fileObject := func(snapshotID objects.MAC, pathname string) objects.MAC {
+		snap, err := snapshot.Load(repo, snapshotID)
+		require.NoError(t, err)
+		defer snap.Close()
+
+		fs, err := snap.Filesystem()
+		require.NoError(t, err)
+
+		entry, err := fs.GetEntry(pathname)
+		require.NoError(t, err)
+		return entry.Object
+	}
+
+	packfiles := func() map[objects.MAC]struct{} {
+		macs, err := repo.GetPackfiles()
+		require.NoError(t, err)
+		set := make(map[objects.MAC]struct{})
+		for _, mac := range macs {
+			set[mac] = struct{}{}
+		}
+		return set
+	}
+
+	first := backup()
+	since := fmt.Sprintf("%x", first[:4])
+
+	// nothing changed: no file content is stored again
+	before := packfiles()
+	backup("-since", since)
+	require.Contains(t, bufOut.String(), "comparing against 4 files")
+	for mac := range packfiles() {
+		if _, found := before[mac]; found {
+			continue
+		}
+		p, err := repo.GetPackfile(mac)
+		require.NoError(t, err)
+		for _, blob := range p.Index {
+			require.NotEqual(t, resources.RT_CHUNK, blob.Type)
+			require.NotEqual(t, resources.RT_OBJECT, blob.Type)
+		}
+	}
+
+	// same size and mtime, different content: only the metadata
+	// tells whether the file changed.
+	foo := tmpBackupDir + "/subdir/foo.txt"
+	fi, err := os.Stat(foo)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(foo, []byte("HELLO FOO"), 0644))
+	require.NoError(t, os.Chtimes(foo, fi.ModTime(), fi.ModTime()))
+
+	// start from an empty user cache so that only -since can help
+	ctx.SetCache(caching.NewManager(t.TempDir()))
+	second := backup("-since", since)
+	require.Equal(t, fileObject(first, foo), fileObject(second, foo))
+	require.Equal(t, fileObject(first, tmpBackupDir+"/another_subdir/bar"),
+		fileObject(second, tmpBackupDir+"/another_subdir/bar"))
+
+	// the user cache was not seeded by -since
+	third := backup()
+	require.NotEqual(t, fileObject(first, foo), fileObject(third, foo))
+}
@@ -0,0 +1,107 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/PlakarKorp/kloset/caching"
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/resources"
+	"github.com/PlakarKorp/kloset/snapshot"
+	"github.com/PlakarKorp/kloset/snapshot/importer"
+	"github.com/PlakarKorp/kloset/snapshot/vfs"
+)
+
+// sinceCache is a throwaway cache manager whose VFS cache for an
+// importer only knows about the regular files of a previous snapshot.
+// Backing up with it reuses the entries and objects of every file whose
+// size, mode and mtime did not change since that snapshot, regardless
+// of what the user's cache holds, and leaves the latter untouched.
+type sinceCache struct {
+	*caching.Manager
+	dir    string
+	nfiles int
+}
+
+func newSinceCache(repo *repository.Repository, imp importer.Importer, snapshotID objects.MAC) (*sinceCache, error) {
+	dir, err := os.MkdirTemp("", "plakar-since-")
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &sinceCache{
+		Manager: caching.NewManager(dir),
+		dir:     dir,
+	}
+	if err := sc.seed(repo, imp, snapshotID); err != nil {
+		sc.Close()
+		return nil, err
+	}
+	return sc, nil
+}
+
+func (sc *sinceCache) seed(repo *repository.Repository, imp importer.Importer, snapshotID objects.MAC) error {
+	snap, err := snapshot.Load(repo, snapshotID)
+	if err != nil {
+		return err
+	}
+	defer snap.Close()
+
+	fs, err := snap.Filesystem()
+	if err != nil {
+		return err
+	}
+
+	vfsCache, err := sc.VFS(repo.Configuration().RepositoryID, imp.Type(), imp.Origin(), true)
+	if err != nil {
+		return err
+	}
+
+	tree, _, _ := fs.BTrees()
+	iter, err := tree.ScanAll()
+	if err != nil {
+		return err
+	}
+
+	for iter.Next() {
+		pathname, entryMAC := iter.Current()
+
+		serializedEntry, err := repo.GetBlobBytes(resources.RT_VFS_ENTRY, entryMAC)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pathname, err)
+		}
+
+		entry, err := vfs.EntryFromBytes(serializedEntry)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pathname, err)
+		}
+
+		if !entry.FileInfo.Mode().IsRegular() || !entry.HasObject() {
+			continue
+		}
+
+		serializedObject, err := repo.GetBlobBytes(resources.RT_OBJECT, entry.Object)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pathname, err)
+		}
+
+		if err := vfsCache.PutObject(entry.Object, serializedObject); err != nil {
+			return err
+		}
+		if err := vfsCache.PutFilename(pathname, serializedEntry); err != nil {
+			return err
+		}
+		sc.nfiles++
+	}
+
+	return iter.Err()
+}
+
+func (sc *sinceCache) Close() error {
+	err := sc.Manager.Close()
+	if err := os.RemoveAll(sc.dir); err != nil {
+		return err
+	}
+	return err
+}
@@ -0,0 +1,185 @@
+package check
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/PlakarKorp/kloset/encryption/keypair"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/snapshot"
+	"github.com/PlakarKorp/kloset/snapshot/vfs"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/subcommands"
+	"github.com/google/uuid"
+)
+
+func init() {
+	subcommands.Register(func() subcommands.Subcommand { return &VerifyCert{} }, subcommands.AgentSupport, "verify-cert")
+}
+
+const certificateVersion = 2
+
+// Certificate attests that a snapshot was successfully checked.  It is
+// signed with the keypair of whoever ran the check, so that it can't be
+// forged by someone merely able to open the repository, or at all if
+// the repository is not encrypted.
+type Certificate struct {
+	Version           int       `json:"version"`
+	RepositoryID      uuid.UUID `json:"repository_id"`
+	SnapshotID        string    `json:"snapshot_id"`
+	SnapshotTimestamp time.Time `json:"snapshot_timestamp"`
+	Path              string    `json:"path"`
+	VerifiedAt        time.Time `json:"verified_at"`
+	FastCheck         bool      `json:"fast_check"`
+	SignatureVerified bool      `json:"signature_verified"`
+
+	Directories uint64 `json:"directories"`
+	Files       uint64 `json:"files"`
+	Objects     uint64 `json:"objects"`
+	Chunks      uint64 `json:"chunks"`
+	Size        uint64 `json:"size"`
+
+	PublicKey string `json:"public_key"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// signedData returns what the signature covers: the certificate,
+// public key included, without the signature.
+func (c *Certificate) signedData() ([]byte, error) {
+	unsigned := *c
+	unsigned.Signature = ""
+	return json.Marshal(&unsigned)
+}
+
+func newCertificate(repo *repository.Repository, kp *keypair.KeyPair, snap *snapshot.Snapshot, pathname string, fastCheck, signatureVerified bool) (*Certificate, error) {
+	cert := &Certificate{
+		Version:           certificateVersion,
+		RepositoryID:      repo.Configuration().RepositoryID,
+		SnapshotID:        hex.EncodeToString(snap.Header.Identifier[:]),
+		SnapshotTimestamp: snap.Header.Timestamp.UTC(),
+		Path:              pathname,
+		VerifiedAt:        time.Now().UTC(),
+		FastCheck:         fastCheck,
+		SignatureVerified: signatureVerified,
+	}
+
+	fs, err := snap.Filesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	err = fs.WalkDir(pathname, func(path string, entry *vfs.Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			cert.Directories++
+			return nil
+		}
+
+		cert.Files++
+		if entry.ResolvedObject != nil {
+			cert.Objects++
+			cert.Chunks += uint64(len(entry.ResolvedObject.Chunks))
+			cert.Size += uint64(entry.Stat().Size())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cert.PublicKey = hex.EncodeToString(kp.PublicKey)
+	data, err := cert.signedData()
+	if err != nil {
+		return nil, err
+	}
+	cert.Signature = hex.EncodeToString(kp.Sign(data))
+
+	return cert, nil
+}
+
+type VerifyCert struct {
+	subcommands.SubcommandBase
+
+	PublicKey []byte
+	Path      string
+}
+
+func (cmd *VerifyCert) Parse(ctx *appcontext.AppContext, args []string) error {
+	flags := flag.NewFlagSet("verify-cert", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [OPTIONS] CERTIFICATE\n", flags.Name())
+		fmt.Fprintf(flags.Output(), "\nOPTIONS:\n")
+		flags.PrintDefaults()
+	}
+	var publicKey string
+	flags.StringVar(&publicKey, "public-key", "", "hex-encoded public key the certificate must be signed with")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: %s [OPTIONS] CERTIFICATE", flags.Name())
+	}
+
+	switch {
+	case publicKey != "":
+		key, err := hex.DecodeString(publicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid public key: %s", publicKey)
+		}
+		cmd.PublicKey = key
+	case ctx.Keypair != nil:
+		cmd.PublicKey = ctx.Keypair.PublicKey
+	default:
+		return fmt.Errorf("no public key to verify the certificate against, use -public-key")
+	}
+
+	cmd.RepositorySecret = ctx.GetSecret()
+	cmd.Path = flags.Arg(0)
+
+	return nil
+}
+
+func (cmd *VerifyCert) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
+	data, err := os.ReadFile(cmd.Path)
+	if err != nil {
+		return 1, err
+	}
+
+	var cert Certificate
+	if err := json.Unmarshal(data, &cert); err != nil {
+		return 1, fmt.Errorf("%s: %w", cmd.Path, err)
+	}
+	if cert.Version != certificateVersion {
+		return 1, fmt.Errorf("%s: unsupported certificate version %d", cmd.Path, cert.Version)
+	}
+	if cert.RepositoryID != repo.Configuration().RepositoryID {
+		return 1, fmt.Errorf("%s: certificate was issued for repository %s", cmd.Path, cert.RepositoryID)
+	}
+
+	publicKey, err := hex.DecodeString(cert.PublicKey)
+	if err != nil || !bytes.Equal(publicKey, cmd.PublicKey) {
+		return 1, fmt.Errorf("%s: certificate was not signed by %x", cmd.Path, cmd.PublicKey)
+	}
+	signature, err := hex.DecodeString(cert.Signature)
+	if err != nil {
+		return 1, fmt.Errorf("%s: invalid certificate", cmd.Path)
+	}
+	data, err = cert.signedData()
+	if err != nil {
+		return 1, err
+	}
+	if !keypair.FromPublicKey(publicKey).Verify(data, signature) {
+		return 1, fmt.Errorf("%s: invalid certificate", cmd.Path)
+	}
+
+	fmt.Fprintf(ctx.Stdout, "certificate for snapshot %s:%s, verified on %s: valid\n",
+		cert.SnapshotID[:8], cert.Path, cert.VerifiedAt.Format(time.RFC3339))
+	return 0, nil
+}
@@ -18,8 +18,10 @@ package check
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"os"
 
 	"github.com/PlakarKorp/plakar/appcontext"
 	"github.com/PlakarKorp/kloset/repository"
@@ -46,8 +48,11 @@ func (cmd *Check) Parse(ctx *appcontext.AppContext, args []string) error {
 	flags.Uint64Var(&cmd.Concurrency, "concurrency", uint64(ctx.MaxConcurrency), "maximum number of parallel tasks")
 	flags.BoolVar(&cmd.NoVerify, "no-verify", false, "disable signature verification")
 	flags.BoolVar(&cmd.FastCheck, "fast", false, "enable fast checking (no digest verification)")
+	flags.BoolVar(&cmd.Deep, "deep", false, "also verify every blob of every packfile in the repository")
+	flags.BoolVar(&cmd.VerifyData, "verify-data", false, "verify the MAC of every chunk of the checked files, reporting each failing chunk")
 	flags.BoolVar(&cmd.Quiet, "quiet", false, "suppress output")
 	flags.BoolVar(&cmd.Silent, "silent", false, "suppress ALL output")
+	flags.StringVar(&cmd.Certify, "certify", "", "write a verification certificate for the snapshot to `file`")
 	cmd.LocateOptions.InstallFlags(flags)
 
 	flags.Parse(args)
@@ -56,6 +61,10 @@ func (cmd *Check) Parse(ctx *appcontext.AppContext, args []string) error {
 		ctx.GetLogger().Warn("snapshot specified, filters will be ignored")
 	}
 
+	if cmd.Certify != "" && ctx.Keypair == nil {
+		return fmt.Errorf("-certify requires an identity to sign the certificate with")
+	}
+
 	cmd.LocateOptions.MaxConcurrency = ctx.MaxConcurrency
 	cmd.LocateOptions.SortOrder = utils.LocateSortOrderAscending
 	cmd.RepositorySecret = ctx.GetSecret()
@@ -70,10 +79,13 @@ type Check struct {
 	LocateOptions *utils.LocateOptions
 	Concurrency   uint64
 	FastCheck     bool
+	Deep          bool
+	VerifyData    bool
 	NoVerify      bool
 	Quiet         bool
 	Snapshots     []string
 	Silent        bool
+	Certify       string
 }
 
 func (cmd *Check) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
@@ -112,9 +124,15 @@ func (cmd *Check) Execute(ctx *appcontext.AppContext, repo *repository.Repositor
 		}
 	}
 
+	if cmd.Certify != "" && len(snapshots) != 1 {
+		return 1, fmt.Errorf("a certificate can only be issued for a single snapshot, %d selected", len(snapshots))
+	}
+
+	// -verify-data re-hashes every chunk itself and reports which one
+	// failed, so the snapshot check only needs to validate the structure
 	opts := &snapshot.CheckOptions{
 		MaxConcurrency: cmd.Concurrency,
-		FastCheck:      cmd.FastCheck,
+		FastCheck:      cmd.FastCheck || cmd.VerifyData,
 	}
 
 	checkCache, err := ctx.GetCache().Check()
@@ -123,6 +141,9 @@ func (cmd *Check) Execute(ctx *appcontext.AppContext, repo *repository.Repositor
 	}
 	defer checkCache.Close()
 
+	// the certificate is only written once every check, -deep
+	// included, has passed
+	var cert *Certificate
 	failures := false
 	for _, arg := range snapshots {
 		snap, pathname, err := utils.OpenSnapshotByPath(repo, arg)
@@ -132,6 +153,7 @@ func (cmd *Check) Execute(ctx *appcontext.AppContext, repo *repository.Repositor
 
 		snap.SetCheckCache(checkCache)
 
+		signatureVerified := false
 		if !cmd.NoVerify && snap.Header.Identity.Identifier != uuid.Nil {
 			if ok, err := snap.Verify(); err != nil {
 				ctx.GetLogger().Warn("%s", err)
@@ -140,6 +162,7 @@ func (cmd *Check) Execute(ctx *appcontext.AppContext, repo *repository.Repositor
 				failures = true
 			} else {
 				ctx.GetLogger().Info("snapshot %x signature verification succeeded", snap.Header.Identifier)
+				signatureVerified = true
 			}
 		}
 
@@ -148,18 +171,69 @@ func (cmd *Check) Execute(ctx *appcontext.AppContext, repo *repository.Repositor
 			failures = true
 		}
 
+		if cmd.VerifyData {
+			verifyErrors, err := VerifyData(ctx, repo, snap, pathname)
+			if err != nil {
+				snap.Close()
+				return 1, err
+			}
+			for _, e := range verifyErrors {
+				ctx.GetLogger().Warn("%s", e)
+			}
+			if len(verifyErrors) != 0 {
+				failures = true
+			}
+		}
+
 		if !failures {
 			ctx.GetLogger().Info("check: verification of %x:%s completed successfully",
 				snap.Header.GetIndexShortID(),
 				pathname)
+
+			if cmd.Certify != "" {
+				cert, err = newCertificate(repo, ctx.Keypair, snap, pathname, cmd.FastCheck, signatureVerified)
+				if err != nil {
+					snap.Close()
+					return 1, fmt.Errorf("could not issue certificate: %w", err)
+				}
+			}
 		}
 
 		snap.Close()
 	}
 
+	if cmd.Deep {
+		integrityErrors, err := VerifyIntegrity(ctx, repo)
+		if err != nil {
+			return 1, err
+		}
+		for _, e := range integrityErrors {
+			ctx.GetLogger().Warn("%s", e)
+		}
+		if len(integrityErrors) != 0 {
+			failures = true
+		} else {
+			ctx.GetLogger().Info("check: verification of the repository packfiles completed successfully")
+		}
+	}
+
 	if failures {
 		return 1, fmt.Errorf("check failed")
 	}
 
+	if cert != nil {
+		if err := cmd.writeCertificate(cert); err != nil {
+			return 1, fmt.Errorf("could not issue certificate: %w", err)
+		}
+	}
+
 	return 0, nil
 }
+
+func (cmd *Check) writeCertificate(cert *Certificate) error {
+	data, err := json.MarshalIndent(cert, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cmd.Certify, append(data, '\n'), 0644)
+}
@@ -3,13 +3,19 @@ package check
 import (
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/PlakarKorp/kloset/encryption/keypair"
+	"github.com/PlakarKorp/kloset/packfile"
 	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/resources"
 	"github.com/PlakarKorp/kloset/snapshot"
+	"github.com/PlakarKorp/kloset/storage"
 	"github.com/PlakarKorp/plakar/appcontext"
 	_ "github.com/PlakarKorp/plakar/connectors/fs/exporter"
 	ptesting "github.com/PlakarKorp/plakar/testing"
@@ -106,3 +112,205 @@ func TestExecuteCmdCheckSpecificSnapshot(t *testing.T) {
 	lastline := lines[len(lines)-1]
 	require.Contains(t, lastline, fmt.Sprintf("info: check: verification of %s:%s completed successfully", hex.EncodeToString(snap.Header.GetIndexShortID()[:]), snap.Header.GetSource(0).Importer.Directory))
 }
+
+func TestExecuteCmdCheckCertify(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, snap, ctx := generateSnapshot(t, bufOut, bufErr)
+	defer snap.Close()
+
+	certPath := filepath.Join(t.TempDir(), "cert.json")
+	indexId := snap.Header.GetIndexID()
+	args := []string{"-certify", certPath, hex.EncodeToString(indexId[:])}
+
+	// a certificate has to be signed by someone
+	subcommand := &Check{}
+	require.Error(t, subcommand.Parse(ctx, args))
+
+	kp, err := keypair.Generate()
+	require.NoError(t, err)
+	ctx.Keypair = kp
+
+	err = subcommand.Parse(ctx, args)
+	require.NoError(t, err)
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	data, err := os.ReadFile(certPath)
+	require.NoError(t, err)
+	var cert Certificate
+	require.NoError(t, json.Unmarshal(data, &cert))
+	require.Equal(t, hex.EncodeToString(snap.Header.Identifier[:]), cert.SnapshotID)
+	require.Equal(t, uint64(4), cert.Files)
+
+	verify := &VerifyCert{}
+	require.NoError(t, verify.Parse(ctx, []string{certPath}))
+	status, err = verify.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+	require.Contains(t, bufOut.String(), ": valid")
+
+	// so does anyone holding the public key
+	verify = &VerifyCert{}
+	require.NoError(t, verify.Parse(ctx, []string{"-public-key", hex.EncodeToString(kp.PublicKey), certPath}))
+	status, err = verify.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	// any change to the certificate must invalidate it
+	tampered := cert
+	tampered.Files++
+	data, err = json.Marshal(&tampered)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certPath, data, 0644))
+
+	status, err = verify.Execute(ctx, repo)
+	require.Error(t, err)
+	require.Equal(t, 1, status)
+
+	// and so must signing it again with another key
+	other, err := keypair.Generate()
+	require.NoError(t, err)
+	forged := cert
+	forged.PublicKey = hex.EncodeToString(other.PublicKey)
+	forged.Signature = ""
+	data, err = forged.signedData()
+	require.NoError(t, err)
+	forged.Signature = hex.EncodeToString(other.Sign(data))
+	data, err = json.Marshal(&forged)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certPath, data, 0644))
+
+	status, err = verify.Execute(ctx, repo)
+	require.ErrorContains(t, err, "certificate was not signed by")
+	require.Equal(t, 1, status)
+}
+
+func TestVerifyIntegrity(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, snap, ctx := generateSnapshot(t, bufOut, bufErr)
+	snap.Close()
+
+	integrityErrors, err := VerifyIntegrity(ctx, repo)
+	require.NoError(t, err)
+	require.Empty(t, integrityErrors)
+
+	packfiles, err := repo.GetPackfiles()
+	require.NoError(t, err)
+	require.NotEmpty(t, packfiles)
+	target := packfiles[0]
+
+	// flip a byte in the middle of the packfile on disk
+	var packfilePath string
+	root := strings.TrimPrefix(repo.Location(), "fs://")
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Name() == hex.EncodeToString(target[:]) {
+			packfilePath = path
+		}
+		return err
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, packfilePath)
+
+	data, err := os.ReadFile(packfilePath)
+	require.NoError(t, err)
+	data[len(data)/2] ^= 0xff
+	require.NoError(t, os.WriteFile(packfilePath, data, 0600))
+
+	integrityErrors, err = VerifyIntegrity(ctx, repo)
+	require.NoError(t, err)
+	require.Len(t, integrityErrors, 1)
+	require.Equal(t, target, integrityErrors[0].PackfileMAC)
+
+	kp, err := keypair.Generate()
+	require.NoError(t, err)
+	ctx.Keypair = kp
+
+	certPath := filepath.Join(t.TempDir(), "cert.json")
+	subcommand := &Check{}
+	err = subcommand.Parse(ctx, []string{"-deep", "-silent", "-certify", certPath, hex.EncodeToString(snap.Header.Identifier[:])})
+	require.NoError(t, err)
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.Error(t, err)
+	require.Equal(t, 1, status)
+
+	// no certificate is issued unless every check passed, -deep included
+	require.NoFileExists(t, certPath)
+}
+
+func TestVerifyData(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, snap, ctx := generateSnapshot(t, bufOut, bufErr)
+	defer snap.Close()
+
+	verifyErrors, err := VerifyData(ctx, repo, snap, "/")
+	require.NoError(t, err)
+	require.Empty(t, verifyErrors)
+
+	fs, err := snap.Filesystem()
+	require.NoError(t, err)
+	entry, err := fs.GetEntry("/subdir/foo.txt")
+	require.NoError(t, err)
+	require.NotNil(t, entry.ResolvedObject)
+	require.NotEmpty(t, entry.ResolvedObject.Chunks)
+	chunkMAC := entry.ResolvedObject.Chunks[0].ContentMAC
+
+	// locate the chunk in its packfile and flip a byte of it on disk
+	packfileMAC, exists, err := repo.GetPackfileForBlob(resources.RT_CHUNK, chunkMAC)
+	require.NoError(t, err)
+	require.True(t, exists)
+	p, err := repo.GetPackfile(packfileMAC)
+	require.NoError(t, err)
+
+	var blob *packfile.Blob
+	for i := range p.Index {
+		if p.Index[i].Type == resources.RT_CHUNK && p.Index[i].MAC == chunkMAC {
+			blob = &p.Index[i]
+		}
+	}
+	require.NotNil(t, blob)
+
+	var packfilePath string
+	root := strings.TrimPrefix(repo.Location(), "fs://")
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Name() == hex.EncodeToString(packfileMAC[:]) {
+			packfilePath = path
+		}
+		return err
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, packfilePath)
+
+	data, err := os.ReadFile(packfilePath)
+	require.NoError(t, err)
+	data[int(storage.STORAGE_HEADER_SIZE)+int(blob.Offset)+int(blob.Length)/2] ^= 0xff
+	require.NoError(t, os.WriteFile(packfilePath, data, 0600))
+
+	verifyErrors, err = VerifyData(ctx, repo, snap, "/subdir")
+	require.NoError(t, err)
+	require.Len(t, verifyErrors, 1)
+	require.Equal(t, "/subdir/foo.txt", verifyErrors[0].Path)
+	require.Equal(t, 0, verifyErrors[0].Chunk)
+	require.Equal(t, chunkMAC, verifyErrors[0].Expected)
+
+	subcommand := &Check{}
+	err = subcommand.Parse(ctx, []string{"-verify-data"})
+	require.NoError(t, err)
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.Error(t, err)
+	require.Equal(t, 1, status)
+
+	// the corruption is reported once, by chunk, and not again by the
+	// snapshot check
+	require.Equal(t, 1, strings.Count(bufErr.String(), "/subdir/foo.txt"))
+	require.Contains(t, bufErr.String(), "/subdir/foo.txt: chunk 0")
+}
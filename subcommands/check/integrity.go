@@ -0,0 +1,147 @@
+package check
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/PlakarKorp/kloset/compression"
+	"github.com/PlakarKorp/kloset/encryption"
+	"github.com/PlakarKorp/kloset/events"
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/resources"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"golang.org/x/sync/errgroup"
+)
+
+// IntegrityError describes a blob, or a whole packfile if BlobMAC is
+// zero, whose stored data could not be verified.
+type IntegrityError struct {
+	PackfileMAC objects.MAC
+	BlobMAC     objects.MAC
+	Type        resources.Type
+	Reason      string
+}
+
+func (e IntegrityError) String() string {
+	if e.BlobMAC == (objects.MAC{}) {
+		return fmt.Sprintf("packfile %x: %s", e.PackfileMAC, e.Reason)
+	}
+	return fmt.Sprintf("packfile %x: %s %x: %s", e.PackfileMAC, e.Type, e.BlobMAC, e.Reason)
+}
+
+// blobs of these types are stored under the identifier of their snapshot
+// rather than the MAC of their content.
+func isContentAddressed(typ resources.Type) bool {
+	switch typ {
+	case resources.RT_SNAPSHOT, resources.RT_SIGNATURE:
+		return false
+	}
+	return true
+}
+
+// decodeBlob decrypts and inflates a blob as stored in a packfile, the
+// way the repository does when reading it.
+func decodeBlob(ctx *appcontext.AppContext, repo *repository.Repository, data []byte) ([]byte, error) {
+	config := repo.Configuration()
+
+	var rd io.Reader = bytes.NewReader(data)
+	if config.Encryption != nil {
+		tmp, err := encryption.DecryptStream(config.Encryption, ctx.GetSecret(), rd)
+		if err != nil {
+			return nil, err
+		}
+		rd = tmp
+	}
+	if config.Compression != nil {
+		tmp, err := compression.InflateStream(config.Compression.Algorithm, rd)
+		if err != nil {
+			return nil, err
+		}
+		rd = tmp
+	}
+	return io.ReadAll(rd)
+}
+
+// VerifyIntegrity reads back every blob of every packfile in the store,
+// decodes it and compares the MAC of its content with the one it is
+// indexed under. A packfile that fails its own authentication is
+// reported as a whole, as its index can't be trusted.
+func VerifyIntegrity(ctx *appcontext.AppContext, repo *repository.Repository) ([]IntegrityError, error) {
+	packfiles, err := repo.GetPackfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var failures []IntegrityError
+	report := func(e IntegrityError) {
+		ctx.Events().Send(events.ErrorEvent(e.PackfileMAC, e.String()))
+		mu.Lock()
+		failures = append(failures, e)
+		mu.Unlock()
+	}
+
+	ctx.Events().Send(events.StartEvent())
+	defer ctx.Events().Send(events.DoneEvent())
+
+	wg := new(errgroup.Group)
+	wg.SetLimit(ctx.MaxConcurrency)
+	for _, packfileMAC := range packfiles {
+		if err := ctx.Err(); err != nil {
+			break
+		}
+
+		wg.Go(func() error {
+			p, err := repo.GetPackfile(packfileMAC)
+			if err != nil {
+				report(IntegrityError{
+					PackfileMAC: packfileMAC,
+					Type:        resources.RT_PACKFILE,
+					Reason:      err.Error(),
+				})
+				return nil
+			}
+
+			for _, blob := range p.Index {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+
+				// padding blobs are stored raw and carry no
+				// content to verify.
+				if blob.Type == resources.RT_RANDOM {
+					continue
+				}
+
+				// the whole packfile is already in memory, decode
+				// the blob from there rather than fetching it again.
+				end := blob.Offset + uint64(blob.Length)
+				if end > uint64(len(p.Blobs)) {
+					report(IntegrityError{packfileMAC, blob.MAC, blob.Type, "blob out of packfile bounds"})
+					continue
+				}
+				data, err := decodeBlob(ctx, repo, p.Blobs[blob.Offset:end])
+				if err != nil {
+					report(IntegrityError{packfileMAC, blob.MAC, blob.Type, err.Error()})
+					continue
+				}
+
+				if isContentAddressed(blob.Type) && repo.ComputeMAC(data) != blob.MAC {
+					report(IntegrityError{packfileMAC, blob.MAC, blob.Type, "MAC mismatch"})
+				}
+			}
+			return nil
+		})
+	}
+	if err := wg.Wait(); err != nil {
+		return failures, err
+	}
+	if err := ctx.Err(); err != nil {
+		return failures, err
+	}
+
+	return failures, nil
+}
@@ -0,0 +1,76 @@
+package check
+
+import (
+	"fmt"
+
+	"github.com/PlakarKorp/kloset/events"
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/resources"
+	"github.com/PlakarKorp/kloset/snapshot"
+	"github.com/PlakarKorp/plakar/appcontext"
+)
+
+// VerifyError describes a chunk of a file whose content does not match
+// the MAC it is referenced under. Computed is zero if the chunk could not
+// be read at all.
+type VerifyError struct {
+	Path     string
+	Chunk    int
+	Expected objects.MAC
+	Computed objects.MAC
+	Reason   string
+}
+
+func (e VerifyError) String() string {
+	if e.Computed == (objects.MAC{}) {
+		return fmt.Sprintf("%s: chunk %d (%x): %s", e.Path, e.Chunk, e.Expected, e.Reason)
+	}
+	return fmt.Sprintf("%s: chunk %d: expected MAC %x, computed %x", e.Path, e.Chunk, e.Expected, e.Computed)
+}
+
+// VerifyData walks the snapshot from pathname, reads back every chunk of
+// every file and compares the MAC of its content with the one the file
+// references it under.
+func VerifyData(ctx *appcontext.AppContext, repo *repository.Repository, snap *snapshot.Snapshot, pathname string) ([]VerifyError, error) {
+	fs, err := snap.Filesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	var failures []VerifyError
+	for entry, err := range fs.Files(pathname) {
+		if err != nil {
+			return failures, err
+		}
+		if err := ctx.Err(); err != nil {
+			return failures, err
+		}
+		if entry.ResolvedObject == nil {
+			continue
+		}
+
+		for i, chunk := range entry.ResolvedObject.Chunks {
+			failure := VerifyError{
+				Path:     entry.Path(),
+				Chunk:    i,
+				Expected: chunk.ContentMAC,
+			}
+
+			data, err := repo.GetBlobBytes(resources.RT_CHUNK, chunk.ContentMAC)
+			if err != nil {
+				failure.Reason = err.Error()
+			} else if computed := repo.ComputeMAC(data); computed != chunk.ContentMAC {
+				failure.Computed = computed
+				failure.Reason = "MAC mismatch"
+			} else {
+				continue
+			}
+
+			ctx.Events().Send(events.ErrorEvent(snap.Header.Identifier, failure.String()))
+			failures = append(failures, failure)
+		}
+	}
+
+	return failures, nil
+}
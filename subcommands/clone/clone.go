@@ -23,6 +23,7 @@ import (
 	"hash"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/PlakarKorp/kloset/hashing"
 	"github.com/PlakarKorp/kloset/repository"
@@ -38,21 +39,37 @@ func init() {
 }
 
 func (cmd *Clone) Parse(ctx *appcontext.AppContext, args []string) error {
+	var opt_tags string
+
 	flags := flag.NewFlagSet("clone", flag.ExitOnError)
 	flags.Usage = func() {
 		fmt.Fprintf(flags.Output(), "Usage: %s to /path/to/repository\n", flags.Name())
 		fmt.Fprintf(flags.Output(), "       %s to s3://bucket/path\n", flags.Name())
+		fmt.Fprintf(flags.Output(), "       %s [OPTIONS] SNAPSHOT as NAME\n", flags.Name())
+		fmt.Fprintf(flags.Output(), "\nOPTIONS:\n")
 		flags.PrintDefaults()
 	}
-
+	flags.StringVar(&opt_tags, "tag", "", "comma-separated list of tags to apply to the cloned snapshot")
 	flags.Parse(args)
 
-	if flags.NArg() != 2 || flags.Arg(0) != "to" {
-		return fmt.Errorf("usage: %s to <repository>. See '%s -h' or 'help %s'", flags.Name(), flags.Name(), flags.Name())
+	switch {
+	case flags.NArg() == 2 && flags.Arg(0) == "to":
+		if opt_tags != "" {
+			return fmt.Errorf("-tag only applies when cloning a snapshot")
+		}
+		cmd.Dest = flags.Arg(1)
+	case flags.NArg() == 3 && flags.Arg(1) == "as":
+		cmd.Snapshot = flags.Arg(0)
+		cmd.Name = flags.Arg(2)
+		if opt_tags != "" {
+			cmd.Tags = strings.Split(opt_tags, ",")
+		}
+	default:
+		return fmt.Errorf("usage: %s to <repository> or %s <snapshot> as <name>. See '%s -h' or 'help %s'",
+			flags.Name(), flags.Name(), flags.Name(), flags.Name())
 	}
 
 	cmd.RepositorySecret = ctx.GetSecret()
-	cmd.Dest = flags.Arg(1)
 
 	return nil
 }
@@ -61,9 +78,17 @@ type Clone struct {
 	subcommands.SubcommandBase
 
 	Dest string
+
+	Snapshot string
+	Name     string
+	Tags     []string
 }
 
 func (cmd *Clone) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
+	if cmd.Snapshot != "" {
+		return cmd.cloneSnapshot(ctx, repo)
+	}
+
 	sourceStore := repo.Store()
 
 	configuration := repo.Configuration()
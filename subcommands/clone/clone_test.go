@@ -2,12 +2,16 @@ package clone
 
 import (
 	"bytes"
+	"encoding/hex"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/snapshot"
 	_ "github.com/PlakarKorp/plakar/connectors/fs/exporter"
 	ptesting "github.com/PlakarKorp/plakar/testing"
+	"github.com/PlakarKorp/plakar/utils"
 	"github.com/stretchr/testify/require"
 )
 
@@ -51,3 +55,78 @@ func TestExecuteCmdClone(t *testing.T) {
 	_, err = os.Stat(outputDir)
 	require.NoError(t, err)
 }
+
+func TestExecuteCmdCloneSnapshot(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/dummy.txt", 0644, "hello dummy"),
+		ptesting.NewMockFile("subdir/foo.txt", 0644, "hello foo"),
+	})
+	defer snap.Close()
+
+	packfiles, err := repo.Store().GetPackfiles()
+	require.NoError(t, err)
+
+	args := []string{"-tag", "pinned,yearly", hex.EncodeToString(snap.Header.Identifier[:]), "as", "pinned"}
+
+	subcommand := &Clone{}
+	err = subcommand.Parse(ctx, args)
+	require.NoError(t, err)
+	require.Equal(t, "pinned", subcommand.Name)
+	require.Equal(t, []string{"pinned", "yearly"}, subcommand.Tags)
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	require.NoError(t, repo.RebuildState())
+	snapshotIDs := utils.LookupSnapshotByPrefix(repo, "")
+	require.Len(t, snapshotIDs, 2)
+
+	var cloneID objects.MAC
+	for _, snapshotID := range snapshotIDs {
+		if snapshotID != snap.Header.Identifier {
+			cloneID = snapshotID
+		}
+	}
+
+	clone, err := snapshot.Load(repo, cloneID)
+	require.NoError(t, err)
+	defer clone.Close()
+
+	require.Equal(t, "pinned", clone.Header.Name)
+	require.Equal(t, []string{"pinned", "yearly"}, clone.Header.Tags)
+	require.Equal(t, snap.Header.GetSource(0).VFS, clone.Header.GetSource(0).VFS)
+
+	listFiles := func(snap *snapshot.Snapshot) []string {
+		fs, err := snap.Filesystem()
+		require.NoError(t, err)
+
+		var files []string
+		for pathname, err := range fs.Pathnames() {
+			require.NoError(t, err)
+			files = append(files, pathname)
+		}
+		return files
+	}
+	require.Equal(t, listFiles(snap), listFiles(clone))
+
+	// only the packfile holding the new header was added
+	clonePackfiles, err := repo.Store().GetPackfiles()
+	require.NoError(t, err)
+	require.LessOrEqual(t, len(clonePackfiles), len(packfiles)+1)
+}
+
+func TestParseCmdCloneUsage(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	_, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+
+	require.Error(t, (&Clone{}).Parse(ctx, []string{"abcd", "to", "name"}))
+	require.Error(t, (&Clone{}).Parse(ctx, []string{"-tag", "foo", "to", "/tmp/repo"}))
+}
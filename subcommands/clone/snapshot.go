@@ -0,0 +1,57 @@
+package clone
+
+import (
+	"fmt"
+
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/snapshot"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/utils"
+)
+
+// CloneSnapshot stores a new snapshot sharing the filesystem, indexes
+// and summaries of an existing one, under a different name and tags.
+// Only the new header is written, no data is copied.
+func CloneSnapshot(repo *repository.Repository, snapshotID objects.MAC, name string, tags []string) (objects.MAC, error) {
+	src, err := snapshot.Load(repo, snapshotID)
+	if err != nil {
+		return objects.MAC{}, err
+	}
+	defer src.Close()
+
+	dst, err := snapshot.Create(repo, repository.DefaultType)
+	if err != nil {
+		return objects.MAC{}, err
+	}
+	defer dst.Close()
+
+	hdr := *src.Header
+	hdr.Identifier = dst.Header.Identifier
+	hdr.Identity = dst.Header.Identity
+	hdr.Name = name
+	if tags != nil {
+		hdr.Tags = tags
+	}
+	dst.Header = &hdr
+
+	if err := dst.Commit(nil, true); err != nil {
+		return objects.MAC{}, err
+	}
+	return hdr.Identifier, nil
+}
+
+func (cmd *Clone) cloneSnapshot(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
+	snapshotID, err := utils.LocateSnapshotByPrefix(repo, cmd.Snapshot)
+	if err != nil {
+		return 1, fmt.Errorf("clone: %w", err)
+	}
+
+	cloneID, err := CloneSnapshot(repo, snapshotID, cmd.Name, cmd.Tags)
+	if err != nil {
+		return 1, fmt.Errorf("clone: failed to clone snapshot %x: %w", snapshotID[:4], err)
+	}
+
+	ctx.GetLogger().Info("clone: created snapshot %x from %x", cloneID[:4], snapshotID[:4])
+	return 0, nil
+}
@@ -29,6 +29,14 @@ func init() {
 		subcommands.BeforeRepositoryOpen, "source")
 	subcommands.Register(func() subcommands.Subcommand { return &ConfigDestinationCmd{} },
 		subcommands.BeforeRepositoryOpen, "destination")
+	subcommands.Register(func() subcommands.Subcommand { return &ConfigEncryptCmd{} },
+		subcommands.BeforeRepositoryOpen, "config", "encrypt")
+	subcommands.Register(func() subcommands.Subcommand { return &ConfigDecryptCmd{} },
+		subcommands.BeforeRepositoryOpen, "config", "decrypt")
+	subcommands.Register(func() subcommands.Subcommand { return &ConfigStoreCmd{prefix: []string{"default"}} },
+		subcommands.BeforeRepositoryOpen, "config", "set-default")
+	subcommands.Register(func() subcommands.Subcommand { return &ConfigUnsetDefaultCmd{} },
+		subcommands.BeforeRepositoryOpen, "config", "unset-default")
 }
 
 func normalizeLocation(location string) string {
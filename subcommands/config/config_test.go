@@ -181,3 +181,107 @@ func TestCmdRepository(t *testing.T) {
 	err = cmd_store_config(ctx, args)
 	require.EqualError(t, err, "backend 'invalid' does not exist")
 }
+
+func TestConfigEncrypt(t *testing.T) {
+	t.Setenv("PLAKAR_CONFIG_PASSPHRASE", "correct horse battery staple")
+
+	tmpDir, err := os.MkdirTemp("", "plakar-config-test")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	cfg, err := utils.LoadConfig(tmpDir)
+	require.NoError(t, err)
+	ctx := appcontext.NewAppContext()
+	ctx.ConfigDir = tmpDir
+	ctx.Config = cfg
+	repo := &repository.Repository{}
+
+	subcommand := &ConfigStoreCmd{}
+	err = subcommand.Parse(ctx, []string{"add", "s3", "s3://foobar", "secret_access_key=hunter2"})
+	require.NoError(t, err)
+	_, err = subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+
+	encrypt := &ConfigEncryptCmd{}
+	require.NoError(t, encrypt.Parse(ctx, []string{}))
+	status, err := encrypt.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "klosets.yml"))
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "hunter2")
+	require.Contains(t, string(data), "secret_access_key: enc:")
+	require.Contains(t, string(data), "location: s3://foobar")
+
+	cfg, err = utils.LoadConfig(tmpDir)
+	require.NoError(t, err)
+	require.Equal(t, "hunter2", cfg.Repositories["s3"]["secret_access_key"])
+
+	decrypt := &ConfigDecryptCmd{}
+	require.NoError(t, decrypt.Parse(ctx, []string{}))
+	status, err = decrypt.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	data, err = os.ReadFile(filepath.Join(tmpDir, "klosets.yml"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "secret_access_key: hunter2")
+}
+
+func TestConfigSetDefault(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "plakar-config-test")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	cfg, err := utils.LoadConfig(tmpDir)
+	require.NoError(t, err)
+	ctx := appcontext.NewAppContext()
+	ctx.ConfigDir = tmpDir
+	ctx.Config = cfg
+	repo := &repository.Repository{}
+
+	for _, name := range []string{"first", "second"} {
+		subcommand := &ConfigStoreCmd{}
+		require.NoError(t, subcommand.Parse(ctx, []string{"add", name, "fs:/tmp/" + name}))
+		_, err = subcommand.Execute(ctx, repo)
+		require.NoError(t, err)
+	}
+
+	// config set-default is an alias of store default
+	setDefault := &ConfigStoreCmd{prefix: []string{"default"}}
+	require.NoError(t, setDefault.Parse(ctx, []string{"unknown"}))
+	status, err := setDefault.Execute(ctx, repo)
+	require.Error(t, err)
+	require.Equal(t, 1, status)
+
+	for _, name := range []string{"first", "second"} {
+		setDefault := &ConfigStoreCmd{prefix: []string{"default"}}
+		require.NoError(t, setDefault.Parse(ctx, []string{name}))
+		status, err = setDefault.Execute(ctx, repo)
+		require.NoError(t, err)
+		require.Equal(t, 0, status)
+
+		cfg, err = utils.LoadConfig(tmpDir)
+		require.NoError(t, err)
+		require.Equal(t, name, cfg.DefaultRepository)
+
+		storeConfig, err := cfg.GetRepository("@" + cfg.DefaultRepository)
+		require.NoError(t, err)
+		require.Equal(t, "fs:/tmp/"+name, storeConfig["location"])
+	}
+
+	unsetDefault := &ConfigUnsetDefaultCmd{}
+	require.NoError(t, unsetDefault.Parse(ctx, []string{}))
+	status, err = unsetDefault.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	cfg, err = utils.LoadConfig(tmpDir)
+	require.NoError(t, err)
+	require.Empty(t, cfg.DefaultRepository)
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/subcommands"
+	"github.com/PlakarKorp/plakar/utils"
+)
+
+type ConfigEncryptCmd struct {
+	subcommands.SubcommandBase
+
+	passphrase []byte
+}
+
+func (cmd *ConfigEncryptCmd) Parse(ctx *appcontext.AppContext, args []string) error {
+	flags := flag.NewFlagSet("config encrypt", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s\n", flags.Name())
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: plakar config encrypt")
+	}
+
+	if passphrase, ok := os.LookupEnv("PLAKAR_CONFIG_PASSPHRASE"); ok {
+		cmd.passphrase = []byte(passphrase)
+	} else {
+		passphrase, err := utils.GetPassphraseConfirm("configuration", 0)
+		if err != nil {
+			return err
+		}
+		cmd.passphrase = passphrase
+	}
+
+	return nil
+}
+
+func (cmd *ConfigEncryptCmd) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
+	if err := utils.EncryptConfig(ctx.ConfigDir, ctx.Config, cmd.passphrase); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+type ConfigDecryptCmd struct {
+	subcommands.SubcommandBase
+}
+
+func (cmd *ConfigDecryptCmd) Parse(ctx *appcontext.AppContext, args []string) error {
+	flags := flag.NewFlagSet("config decrypt", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s\n", flags.Name())
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: plakar config decrypt")
+	}
+	return nil
+}
+
+func (cmd *ConfigDecryptCmd) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
+	if err := utils.DecryptConfig(ctx.ConfigDir, ctx.Config); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/subcommands"
+	"github.com/PlakarKorp/plakar/utils"
+)
+
+type ConfigUnsetDefaultCmd struct {
+	subcommands.SubcommandBase
+}
+
+func (cmd *ConfigUnsetDefaultCmd) Parse(ctx *appcontext.AppContext, args []string) error {
+	flags := flag.NewFlagSet("config unset-default", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s\n", flags.Name())
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: plakar config unset-default")
+	}
+	return nil
+}
+
+func (cmd *ConfigUnsetDefaultCmd) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
+	ctx.Config.DefaultRepository = ""
+	if err := utils.SaveConfig(ctx.ConfigDir, ctx.Config); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"flag"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/PlakarKorp/kloset/repository"
@@ -17,7 +18,10 @@ import (
 type ConfigStoreCmd struct {
 	subcommands.SubcommandBase
 
-	args []string
+	// prefix is prepended to the arguments, so that aliases such as
+	// "config set-default" run the matching "store" subcommand.
+	prefix []string
+	args   []string
 }
 
 func (cmd *ConfigStoreCmd) Parse(ctx *appcontext.AppContext, args []string) error {
@@ -28,7 +32,7 @@ func (cmd *ConfigStoreCmd) Parse(ctx *appcontext.AppContext, args []string) erro
 	}
 
 	flags.Parse(args)
-	cmd.args = flags.Args()
+	cmd.args = append(slices.Clone(cmd.prefix), flags.Args()...)
 
 	return nil
 }
@@ -56,6 +56,7 @@ func (cmd *Create) Parse(ctx *appcontext.AppContext, args []string) error {
 	flags.StringVar(&cmd.Hashing, "hashing", hashing.DEFAULT_HASHING_ALGORITHM, "hashing algorithm to use for digests")
 	flags.BoolVar(&cmd.NoEncryption, "plaintext", false, "disable transparent encryption")
 	flags.BoolVar(&cmd.NoCompression, "no-compression", false, "disable transparent compression")
+	flags.StringVar(&cmd.Chunking, "chunking", "fastcdc", "content-defined chunking algorithm: fastcdc or ultracdc")
 	flags.Parse(args)
 
 	if flags.NArg() != 0 {
@@ -66,6 +67,12 @@ func (cmd *Create) Parse(ctx *appcontext.AppContext, args []string) error {
 		return fmt.Errorf("%s: unknown hashing algorithm", flag.CommandLine.Name())
 	}
 
+	switch strings.ToLower(cmd.Chunking) {
+	case "fastcdc", "ultracdc":
+	default:
+		return fmt.Errorf("%s: unknown chunking algorithm", flag.CommandLine.Name())
+	}
+
 	minEntropBits := 80.
 	if allow_weak {
 		minEntropBits = 0.
@@ -102,6 +109,7 @@ type Create struct {
 	subcommands.SubcommandBase
 
 	Hashing       string
+	Chunking      string
 	NoEncryption  bool
 	NoCompression bool
 }
@@ -119,6 +127,7 @@ func (cmd *Create) Execute(ctx *appcontext.AppContext, repo *repository.Reposito
 		return 1, err
 	}
 	storageConfiguration.Hashing = *hashingConfiguration
+	storageConfiguration.Chunking.Algorithm = strings.ToUpper(cmd.Chunking)
 
 	var hasher hash.Hash
 	if !cmd.NoEncryption {
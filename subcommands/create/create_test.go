@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/storage"
 	"github.com/PlakarKorp/plakar/appcontext"
 	_ "github.com/PlakarKorp/plakar/connectors/fs/storage"
 	"github.com/stretchr/testify/require"
@@ -120,3 +121,34 @@ func TestExecuteCmdCreateDefaultWithKeyfile(t *testing.T) {
 	_, err = os.Stat(fmt.Sprintf("%s/repo/CONFIG", tmpRepoDirRoot))
 	require.NoError(t, err)
 }
+
+func TestExecuteCmdCreateWithChunking(t *testing.T) {
+	tmpRepoDirRoot, err := os.MkdirTemp("", "tmp_repo")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(tmpRepoDirRoot)
+	})
+	ctx := appcontext.NewAppContext()
+	defer ctx.Close()
+
+	repo, err := repository.Inexistent(ctx.GetInner(), map[string]string{"location": tmpRepoDirRoot + "/repo"})
+	require.NoError(t, err)
+
+	subcommand := &Create{}
+	err = subcommand.Parse(ctx, []string{"-plaintext", "-chunking", "unknowncdc"})
+	require.Error(t, err)
+
+	subcommand = &Create{}
+	err = subcommand.Parse(ctx, []string{"-plaintext", "-chunking", "ultracdc"})
+	require.NoError(t, err)
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	data, err := os.ReadFile(fmt.Sprintf("%s/repo/CONFIG", tmpRepoDirRoot))
+	require.NoError(t, err)
+	config, err := storage.NewConfigurationFromWrappedBytes(data)
+	require.NoError(t, err)
+	require.Equal(t, "ULTRACDC", config.Chunking.Algorithm)
+}
@@ -0,0 +1,219 @@
+package dedupcompare
+
+import (
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/PlakarKorp/kloset/encryption"
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/resources"
+	"github.com/PlakarKorp/kloset/snapshot"
+	"github.com/PlakarKorp/kloset/snapshot/vfs"
+	"github.com/PlakarKorp/kloset/storage"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/subcommands"
+	"github.com/PlakarKorp/plakar/utils"
+	"github.com/dustin/go-humanize"
+)
+
+func init() {
+	subcommands.Register(func() subcommands.Subcommand { return &DedupCompare{} }, subcommands.AgentSupport, "dedup-compare")
+}
+
+type DedupCompare struct {
+	subcommands.SubcommandBase
+
+	PeerRepositoryLocation string
+	PeerRepositorySecret   []byte
+
+	LocateOptions *utils.LocateOptions
+}
+
+func (cmd *DedupCompare) Parse(ctx *appcontext.AppContext, args []string) error {
+	cmd.LocateOptions = utils.NewDefaultLocateOptions()
+
+	flags := flag.NewFlagSet("dedup-compare", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [OPTIONS] REPOSITORY\n", flags.Name())
+		fmt.Fprintf(flags.Output(), "\nOPTIONS:\n")
+		flags.PrintDefaults()
+	}
+	cmd.LocateOptions.InstallFlags(flags)
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: dedup-compare [OPTIONS] REPOSITORY")
+	}
+	peerRepositoryPath := flags.Arg(0)
+
+	storeConfig, err := ctx.Config.GetRepository(peerRepositoryPath)
+	if err != nil {
+		return fmt.Errorf("peer repository: %w", err)
+	}
+
+	peerStore, peerStoreSerializedConfig, err := storage.Open(ctx.GetInner(), storeConfig)
+	if err != nil {
+		return err
+	}
+	defer peerStore.Close()
+
+	peerStoreConfig, err := storage.NewConfigurationFromWrappedBytes(peerStoreSerializedConfig)
+	if err != nil {
+		return err
+	}
+
+	var peerSecret []byte
+	if peerStoreConfig.Encryption != nil {
+		if pass, ok := storeConfig["passphrase"]; ok {
+			key, err := encryption.DeriveKey(peerStoreConfig.Encryption.KDFParams, []byte(pass))
+			if err != nil {
+				return err
+			}
+			if !encryption.VerifyCanary(peerStoreConfig.Encryption, key) {
+				return fmt.Errorf("invalid passphrase")
+			}
+			peerSecret = key
+		} else {
+			for {
+				passphrase, err := utils.GetPassphrase("peer repository")
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s\n", err)
+					continue
+				}
+
+				key, err := encryption.DeriveKey(peerStoreConfig.Encryption.KDFParams, passphrase)
+				if err != nil {
+					return err
+				}
+				if !encryption.VerifyCanary(peerStoreConfig.Encryption, key) {
+					return fmt.Errorf("invalid passphrase")
+				}
+				peerSecret = key
+				break
+			}
+		}
+	}
+
+	cmd.RepositorySecret = ctx.GetSecret()
+	cmd.PeerRepositoryLocation = peerRepositoryPath
+	cmd.PeerRepositorySecret = peerSecret
+
+	return nil
+}
+
+func (cmd *DedupCompare) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
+	storeConfig, err := ctx.Config.GetRepository(cmd.PeerRepositoryLocation)
+	if err != nil {
+		return 1, fmt.Errorf("peer repository: %w", err)
+	}
+
+	peerStore, peerStoreSerializedConfig, err := storage.Open(ctx.GetInner(), storeConfig)
+	if err != nil {
+		return 1, fmt.Errorf("could not open peer store %s: %s", cmd.PeerRepositoryLocation, err)
+	}
+
+	peerCtx := appcontext.NewAppContextFrom(ctx)
+	peerCtx.SetSecret(cmd.PeerRepositorySecret)
+	peerRepository, err := repository.New(peerCtx.GetInner(), peerCtx.GetSecret(), peerStore, peerStoreSerializedConfig)
+	if err != nil {
+		return 1, fmt.Errorf("could not open peer repository %s: %s", cmd.PeerRepositoryLocation, err)
+	}
+	defer peerRepository.Close()
+
+	local, err := cmd.contentDigests(ctx, repo)
+	if err != nil {
+		return 1, err
+	}
+	peer, err := cmd.contentDigests(ctx, peerRepository)
+	if err != nil {
+		return 1, err
+	}
+
+	var localSize, peerSize, sharedSize uint64
+	var shared int
+	for digest, length := range local {
+		localSize += uint64(length)
+		if _, ok := peer[digest]; ok {
+			sharedSize += uint64(length)
+			shared++
+		}
+	}
+	for _, length := range peer {
+		peerSize += uint64(length)
+	}
+
+	fmt.Fprintf(ctx.Stdout, "local:  %d chunks, %s\n", len(local), humanize.Bytes(localSize))
+	fmt.Fprintf(ctx.Stdout, "peer:   %d chunks, %s\n", len(peer), humanize.Bytes(peerSize))
+	fmt.Fprintf(ctx.Stdout, "shared: %d chunks, %s (%.2f%% of local, %.2f%% of peer)\n",
+		shared, humanize.Bytes(sharedSize), percent(sharedSize, localSize), percent(sharedSize, peerSize))
+
+	return 0, nil
+}
+
+func percent(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
+
+// contentDigests maps the SHA256 of every distinct chunk referenced by
+// the selected snapshots of repo to its length. Chunk MACs are keyed per
+// repository and can't be compared across repositories, so the chunks
+// have to be read and hashed again with a key-independent function.
+func (cmd *DedupCompare) contentDigests(ctx *appcontext.AppContext, repo *repository.Repository) (map[[32]byte]uint32, error) {
+	snapshotIDs, err := utils.LocateSnapshotIDs(repo, cmd.LocateOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[objects.MAC]struct{})
+	digests := make(map[[32]byte]uint32)
+	for _, snapshotID := range snapshotIDs {
+		snap, err := snapshot.Load(repo, snapshotID)
+		if err != nil {
+			return nil, fmt.Errorf("could not load snapshot %x: %w", snapshotID, err)
+		}
+
+		fs, err := snap.Filesystem()
+		if err != nil {
+			snap.Close()
+			return nil, err
+		}
+
+		err = fs.WalkDir("/", func(path string, entry *vfs.Entry, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if entry.ResolvedObject == nil {
+				return nil
+			}
+
+			for _, chunk := range entry.ResolvedObject.Chunks {
+				if _, ok := seen[chunk.ContentMAC]; ok {
+					continue
+				}
+				seen[chunk.ContentMAC] = struct{}{}
+
+				data, err := repo.GetBlobBytes(resources.RT_CHUNK, chunk.ContentMAC)
+				if err != nil {
+					return fmt.Errorf("%s: could not read chunk %x: %w", path, chunk.ContentMAC, err)
+				}
+				digests[sha256.Sum256(data)] = chunk.Length
+			}
+			return nil
+		})
+		snap.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return digests, nil
+}
@@ -0,0 +1,49 @@
+package dedupcompare
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	ptesting "github.com/PlakarKorp/plakar/testing"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	os.Setenv("TZ", "UTC")
+}
+
+func TestExecuteCmdDedupCompare(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	localRepo, lctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	localSnap := ptesting.GenerateSnapshot(t, localRepo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/dummy.txt", 0644, "hello dummy"),
+		ptesting.NewMockFile("subdir/foo.txt", 0644, "hello foo"),
+	})
+	defer localSnap.Close()
+
+	peerRepo, _ := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	peerSnap := ptesting.GenerateSnapshot(t, peerRepo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/dummy.txt", 0644, "hello dummy"),
+		ptesting.NewMockFile("subdir/bar.txt", 0644, "hello bar"),
+		ptesting.NewMockFile("subdir/baz.txt", 0644, "hello baz"),
+	})
+	defer peerSnap.Close()
+
+	subcommand := &DedupCompare{}
+	err := subcommand.Parse(lctx, []string{peerRepo.Location()})
+	require.NoError(t, err)
+
+	status, err := subcommand.Execute(lctx, localRepo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	output := bufOut.String()
+	require.Contains(t, output, "local:  2 chunks")
+	require.Contains(t, output, "peer:   3 chunks")
+	require.Contains(t, output, "shared: 1 chunks, 11 B (")
+}
@@ -29,7 +29,10 @@ func init() {
 	subcommands.Register(func() subcommands.Subcommand { return &DiagVFS{} }, subcommands.AgentSupport, "diag", "vfs")
 	subcommands.Register(func() subcommands.Subcommand { return &DiagXattr{} }, subcommands.AgentSupport, "diag", "xattr")
 	subcommands.Register(func() subcommands.Subcommand { return &DiagContentType{} }, subcommands.AgentSupport, "diag", "contenttype")
+	subcommands.Register(func() subcommands.Subcommand { return &DiagEntropy{} }, subcommands.AgentSupport, "diag", "entropy")
 	subcommands.Register(func() subcommands.Subcommand { return &DiagLocks{} }, subcommands.AgentSupport, "diag", "locks")
 	subcommands.Register(func() subcommands.Subcommand { return &DiagSearch{} }, subcommands.AgentSupport, "diag", "search")
+	subcommands.Register(func() subcommands.Subcommand { return &DiagGraph{} }, subcommands.AgentSupport, "diag", "graph")
+	subcommands.Register(func() subcommands.Subcommand { return &DiagLocateBlob{} }, subcommands.AgentSupport, "diag", "locate-blob")
 	subcommands.Register(func() subcommands.Subcommand { return &DiagRepository{} }, subcommands.AgentSupport, "diag")
 }
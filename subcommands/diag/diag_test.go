@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	iofs "io/fs"
 	"os"
 	"strings"
 	"testing"
 
+	"github.com/PlakarKorp/kloset/objects"
 	"github.com/PlakarKorp/kloset/repository"
 	"github.com/PlakarKorp/kloset/snapshot"
+	"github.com/PlakarKorp/kloset/snapshot/vfs"
 	"github.com/PlakarKorp/plakar/appcontext"
 	_ "github.com/PlakarKorp/plakar/connectors/fs/exporter"
 	"github.com/PlakarKorp/plakar/subcommands"
@@ -138,6 +141,105 @@ func TestExecuteCmdDiagErrors(t *testing.T) {
 	require.Equal(t, "", strings.Trim(output, "\n"))
 }
 
+func TestExecuteCmdDiagGraph(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, snap, ctx := generateSnapshot(t, bufOut, bufErr)
+	defer snap.Close()
+
+	indexId := snap.Header.GetIndexID()
+	args := []string{"diag", "graph", hex.EncodeToString(indexId[:])}
+
+	subcommand, _, args := subcommands.Lookup(args)
+	err := subcommand.Parse(ctx, args)
+	require.NoError(t, err)
+	require.NotNil(t, subcommand)
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	output := bufOut.String()
+	require.True(t, strings.HasPrefix(output, "digraph snapshot {\n"))
+	require.True(t, strings.HasSuffix(output, "}\n"))
+	require.Contains(t, output, fmt.Sprintf("snapshot:%x", indexId))
+	require.Contains(t, output, "shape=cylinder")
+}
+
+func TestExecuteCmdDiagEntropy(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, snap, ctx := generateSnapshot(t, bufOut, bufErr)
+	defer snap.Close()
+
+	indexId := snap.Header.GetIndexID()
+	args := []string{"diag", "entropy", "-compare", hex.EncodeToString(indexId[:]), hex.EncodeToString(indexId[:])}
+
+	subcommand, _, args := subcommands.Lookup(args)
+	err := subcommand.Parse(ctx, args)
+	require.NoError(t, err)
+	require.NotNil(t, subcommand)
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	output := bufOut.String()
+	require.Contains(t, output, "Chunks: 4\n")
+	require.Contains(t, output, "7.5-8.0: ")
+	require.Contains(t, output, ": 0.000\n")
+	require.NotContains(t, output, "warning:")
+
+	var h1, h2 entropyHistogram
+	h1.add(2)
+	h2.add(8)
+	require.Equal(t, 1.0, h1.distance(&h2))
+	require.Equal(t, 0.0, h1.above(entropyHighThreshold))
+	require.Equal(t, 1.0, h2.above(entropyHighThreshold))
+}
+
+func TestExecuteCmdDiagLocateBlob(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, snap, ctx := generateSnapshot(t, bufOut, bufErr)
+	defer snap.Close()
+
+	fs, err := snap.Filesystem()
+	require.NoError(t, err)
+
+	var chunk objects.MAC
+	err = fs.WalkDir("/", func(path string, entry *vfs.Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.ResolvedObject != nil && len(entry.ResolvedObject.Chunks) != 0 {
+			chunk = entry.ResolvedObject.Chunks[0].ContentMAC
+			return iofs.SkipAll
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	args := []string{"diag", "locate-blob", "-type", "chunk", hex.EncodeToString(chunk[:])}
+
+	subcommand, _, args := subcommands.Lookup(args)
+	err = subcommand.Parse(ctx, args)
+	require.NoError(t, err)
+	require.NotNil(t, subcommand)
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	output := bufOut.String()
+	require.Contains(t, output, "Packfile: ")
+	require.Contains(t, output, "Offset: ")
+	require.Contains(t, output, "Length: ")
+}
+
 func TestExecuteCmdDiagState(t *testing.T) {
 	bufOut := bytes.NewBuffer(nil)
 	bufErr := bytes.NewBuffer(nil)
@@ -0,0 +1,191 @@
+package diag
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/snapshot/vfs"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/subcommands"
+	"github.com/PlakarKorp/plakar/utils"
+)
+
+const (
+	// chunks above this many bits of entropy per byte are most likely
+	// compressed or encrypted.
+	entropyHighThreshold = 7.5
+
+	// share of high-entropy chunks above which a snapshot is flagged.
+	entropyHighRatio = 0.5
+
+	// total variation distance between two histograms above which the
+	// distributions are considered to have drifted.
+	entropyDriftThreshold = 0.3
+)
+
+// entropyHistogram counts chunks in 256 buckets of 1/32 bit per byte,
+// covering the 0-8 range of the entropy computed at backup time.
+type entropyHistogram [256]uint64
+
+func (h *entropyHistogram) add(entropy float64) {
+	bucket := int(entropy * 32)
+	if bucket < 0 {
+		bucket = 0
+	} else if bucket > 255 {
+		bucket = 255
+	}
+	h[bucket]++
+}
+
+func (h *entropyHistogram) total() uint64 {
+	var total uint64
+	for _, n := range h {
+		total += n
+	}
+	return total
+}
+
+// above returns the share of chunks whose entropy is at least threshold.
+func (h *entropyHistogram) above(threshold float64) float64 {
+	total := h.total()
+	if total == 0 {
+		return 0
+	}
+	var n uint64
+	for i := int(threshold * 32); i < len(h); i++ {
+		n += h[i]
+	}
+	return float64(n) / float64(total)
+}
+
+// distance returns the total variation distance between the normalized
+// histograms, from 0 for identical distributions to 1 for disjoint ones.
+func (h *entropyHistogram) distance(other *entropyHistogram) float64 {
+	t1, t2 := h.total(), other.total()
+	if t1 == 0 || t2 == 0 {
+		return 0
+	}
+	var d float64
+	for i := range h {
+		diff := float64(h[i])/float64(t1) - float64(other[i])/float64(t2)
+		if diff < 0 {
+			diff = -diff
+		}
+		d += diff
+	}
+	return d / 2
+}
+
+type DiagEntropy struct {
+	subcommands.SubcommandBase
+
+	SnapshotPath string
+	Compare      string
+}
+
+func (cmd *DiagEntropy) Parse(ctx *appcontext.AppContext, args []string) error {
+	flags := flag.NewFlagSet("diag entropy", flag.ExitOnError)
+	flags.StringVar(&cmd.Compare, "compare", "", "snapshot to compare the distribution with")
+	flags.Parse(args)
+
+	if len(flags.Args()) < 1 {
+		return fmt.Errorf("usage: %s [-compare snapshotID] SNAPSHOT[:PATH]", flags.Name())
+	}
+
+	cmd.RepositorySecret = ctx.GetSecret()
+	cmd.SnapshotPath = flags.Args()[0]
+
+	return nil
+}
+
+func snapshotEntropy(ctx *appcontext.AppContext, repo *repository.Repository, snapshotPath string) (*entropyHistogram, error) {
+	snap, pathname, err := utils.OpenSnapshotByPath(repo, snapshotPath)
+	if err != nil {
+		return nil, err
+	}
+	defer snap.Close()
+
+	fs, err := snap.Filesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	// chunks are counted once per object, as they are stored
+	var hist entropyHistogram
+	seen := make(map[objects.MAC]struct{})
+	err = fs.WalkDir(pathname, func(path string, entry *vfs.Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !entry.Stat().Mode().IsRegular() || entry.ResolvedObject == nil {
+			return nil
+		}
+
+		if _, ok := seen[entry.Object]; ok {
+			return nil
+		}
+		seen[entry.Object] = struct{}{}
+
+		for _, chunk := range entry.ResolvedObject.Chunks {
+			hist.add(chunk.Entropy)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &hist, nil
+}
+
+func (cmd *DiagEntropy) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
+	hist, err := snapshotEntropy(ctx, repo, cmd.SnapshotPath)
+	if err != nil {
+		return 1, err
+	}
+
+	total := hist.total()
+	fmt.Fprintf(ctx.Stdout, "Chunks: %d\n", total)
+	if total == 0 {
+		return 0, nil
+	}
+
+	// one line per half bit, the full resolution is kept for the
+	// comparisons below.
+	for i := 0; i < len(hist); i += 16 {
+		var n uint64
+		for _, count := range hist[i : i+16] {
+			n += count
+		}
+		share := float64(n) / float64(total)
+		fmt.Fprintf(ctx.Stdout, "%.1f-%.1f: %8d %5.1f%% %s\n", float64(i)/32, float64(i+16)/32,
+			n, share*100, strings.Repeat("#", int(share*50+0.5)))
+	}
+
+	if high := hist.above(entropyHighThreshold); high > entropyHighRatio {
+		fmt.Fprintf(ctx.Stdout, "warning: %.1f%% of chunks have an entropy above %.1f bits, "+
+			"the data is likely compressed or encrypted and won't compress further\n",
+			high*100, entropyHighThreshold)
+	}
+
+	if cmd.Compare != "" {
+		other, err := snapshotEntropy(ctx, repo, cmd.Compare)
+		if err != nil {
+			return 1, err
+		}
+
+		distance := hist.distance(other)
+		fmt.Fprintf(ctx.Stdout, "Distance to %s: %.3f\n", cmd.Compare, distance)
+		if distance > entropyDriftThreshold {
+			fmt.Fprintf(ctx.Stdout, "warning: the entropy distribution differs from %s, "+
+				"check for files encrypted since\n", cmd.Compare)
+		}
+	}
+
+	return 0, nil
+}
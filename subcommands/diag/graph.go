@@ -0,0 +1,208 @@
+package diag
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/resources"
+	"github.com/PlakarKorp/kloset/snapshot/vfs"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/subcommands"
+	"github.com/PlakarKorp/plakar/utils"
+)
+
+// maximum number of children drawn for a node, the rest is summarized
+const graphMaxChildren = 10
+
+type DiagGraph struct {
+	subcommands.SubcommandBase
+
+	SnapshotID string
+	Output     string
+}
+
+func (cmd *DiagGraph) Parse(ctx *appcontext.AppContext, args []string) error {
+	flags := flag.NewFlagSet("diag graph", flag.ExitOnError)
+	flags.StringVar(&cmd.Output, "output", "", "write the graph to this file instead of stdout")
+	flags.Parse(args)
+
+	if len(flags.Args()) < 1 {
+		return fmt.Errorf("usage: %s [-output file] SNAPSHOT", flags.Name())
+	}
+
+	cmd.RepositorySecret = ctx.GetSecret()
+	cmd.SnapshotID = flags.Args()[0]
+
+	return nil
+}
+
+type graphWriter struct {
+	w     io.Writer
+	repo  *repository.Repository
+	fs    *vfs.Filesystem
+	drawn map[string]struct{}
+}
+
+func (g *graphWriter) node(id, shape, label string) {
+	if _, ok := g.drawn[id]; ok {
+		return
+	}
+	g.drawn[id] = struct{}{}
+	fmt.Fprintf(g.w, "  %q [shape=%s, label=%q];\n", id, shape, label)
+}
+
+func (g *graphWriter) edge(from, to string) {
+	id := from + " -> " + to
+	if _, ok := g.drawn[id]; ok {
+		return
+	}
+	g.drawn[id] = struct{}{}
+	fmt.Fprintf(g.w, "  %q -> %q;\n", from, to)
+}
+
+// more draws the ellipsis for the children of parent that were pruned
+func (g *graphWriter) more(parent string, total int) {
+	if total <= graphMaxChildren {
+		return
+	}
+	id := parent + "/more"
+	g.node(id, "plaintext", fmt.Sprintf("%d more...", total-graphMaxChildren))
+	g.edge(parent, id)
+}
+
+func (cmd *DiagGraph) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
+	snap, _, err := utils.OpenSnapshotByPath(repo, cmd.SnapshotID)
+	if err != nil {
+		return 1, err
+	}
+	defer snap.Close()
+
+	fs, err := snap.Filesystem()
+	if err != nil {
+		return 1, err
+	}
+
+	var out io.Writer = ctx.Stdout
+	if cmd.Output != "" {
+		fp, err := os.Create(cmd.Output)
+		if err != nil {
+			return 1, err
+		}
+		defer fp.Close()
+		bw := bufio.NewWriter(fp)
+		defer bw.Flush()
+		out = bw
+	}
+
+	g := &graphWriter{
+		w:     out,
+		repo:  repo,
+		fs:    fs,
+		drawn: make(map[string]struct{}),
+	}
+
+	fmt.Fprintf(out, "digraph snapshot {\n")
+
+	snapID := fmt.Sprintf("snapshot:%x", snap.Header.GetIndexID())
+	g.node(snapID, "doubleoctagon", fmt.Sprintf("snapshot %x", snap.Header.GetIndexShortID()))
+
+	// only the btree nodes reachable through drawn pointers are kept,
+	// the depth-first iteration yields the root first.
+	reachable := make(map[objects.MAC]struct{})
+	iter := fs.IterNodes()
+	first := true
+	for iter.Next() {
+		ptr, node := iter.Current()
+		nodeID := fmt.Sprintf("btree:%x", ptr)
+		if first {
+			reachable[ptr] = struct{}{}
+			g.edge(snapID, nodeID)
+			first = false
+		}
+		if _, ok := reachable[ptr]; !ok {
+			continue
+		}
+
+		if len(node.Pointers) != 0 {
+			g.node(nodeID, "box", fmt.Sprintf("btree node %x\n%d keys", ptr[:4], len(node.Keys)))
+		} else {
+			g.node(nodeID, "box3d", fmt.Sprintf("btree leaf %x\n%d keys", ptr[:4], len(node.Keys)))
+		}
+
+		for i, child := range node.Pointers {
+			if i == graphMaxChildren {
+				break
+			}
+			reachable[child] = struct{}{}
+			g.edge(nodeID, fmt.Sprintf("btree:%x", child))
+		}
+		g.more(nodeID, len(node.Pointers))
+
+		for i, key := range node.Keys {
+			if len(node.Pointers) != 0 || i == graphMaxChildren {
+				break
+			}
+			if err := g.entry(nodeID, key); err != nil {
+				return 1, err
+			}
+		}
+		if len(node.Pointers) == 0 {
+			g.more(nodeID, len(node.Keys))
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return 1, err
+	}
+
+	fmt.Fprintf(out, "}\n")
+	return 0, nil
+}
+
+func (g *graphWriter) entry(parent, pathname string) error {
+	entry, err := g.fs.GetEntry(pathname)
+	if err != nil {
+		return err
+	}
+
+	entryID := "entry:" + pathname
+	g.node(entryID, "folder", pathname)
+	g.edge(parent, entryID)
+
+	object := entry.ResolvedObject
+	if object == nil {
+		return nil
+	}
+
+	objectID := fmt.Sprintf("object:%x", object.ContentMAC)
+	g.node(objectID, "ellipse", fmt.Sprintf("object %x", object.ContentMAC[:4]))
+	g.edge(entryID, objectID)
+
+	for i, chunk := range object.Chunks {
+		if i == graphMaxChildren {
+			break
+		}
+
+		chunkID := fmt.Sprintf("chunk:%x", chunk.ContentMAC)
+		g.node(chunkID, "circle", fmt.Sprintf("%x", chunk.ContentMAC[:4]))
+		g.edge(objectID, chunkID)
+
+		packfile, exists, err := g.repo.GetPackfileForBlob(resources.RT_CHUNK, chunk.ContentMAC)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		packfileID := fmt.Sprintf("packfile:%x", packfile)
+		g.node(packfileID, "cylinder", fmt.Sprintf("packfile %x", packfile[:4]))
+		g.edge(chunkID, packfileID)
+	}
+	g.more(objectID, len(object.Chunks))
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package diag
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/resources"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/subcommands"
+)
+
+type DiagLocateBlob struct {
+	subcommands.SubcommandBase
+
+	Type resources.Type
+	MAC  objects.MAC
+}
+
+func (cmd *DiagLocateBlob) Parse(ctx *appcontext.AppContext, args []string) error {
+	var typ string
+
+	flags := flag.NewFlagSet("diag locate-blob", flag.ExitOnError)
+	flags.StringVar(&typ, "type", "chunk", "type of the blob: chunk, object, vfs-entry, ...")
+	flags.Parse(args)
+
+	if len(flags.Args()) < 1 {
+		return fmt.Errorf("usage: %s [-type type] MAC", flags.Name())
+	}
+
+	found := false
+	typ = strings.ReplaceAll(typ, "-", " ")
+	for _, t := range resources.Types() {
+		if t.String() == typ {
+			cmd.Type, found = t, true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("unknown blob type: %s", typ)
+	}
+
+	arg := flags.Args()[0]
+	b, err := hex.DecodeString(arg)
+	if err != nil || len(b) != len(cmd.MAC) {
+		return fmt.Errorf("invalid blob hash: %s", arg)
+	}
+	copy(cmd.MAC[:], b)
+
+	cmd.RepositorySecret = ctx.GetSecret()
+
+	return nil
+}
+
+func (cmd *DiagLocateBlob) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
+	packfileMAC, exists, err := repo.GetPackfileForBlob(cmd.Type, cmd.MAC)
+	if err != nil {
+		return 1, err
+	}
+
+	if exists {
+		fmt.Fprintf(ctx.Stdout, "Packfile: %x\n", packfileMAC)
+
+		p, err := repo.GetPackfile(packfileMAC)
+		if err != nil {
+			return 1, fmt.Errorf("could not fetch packfile %x: %w", packfileMAC, err)
+		}
+		for _, entry := range p.Index {
+			if entry.Type == cmd.Type && entry.MAC == cmd.MAC {
+				fmt.Fprintf(ctx.Stdout, "Offset: %d\n", entry.Offset)
+				fmt.Fprintf(ctx.Stdout, "Length: %d\n", entry.Length)
+				return 0, nil
+			}
+		}
+		return 1, fmt.Errorf("%s %x is not listed in the index of packfile %x", cmd.Type, cmd.MAC, packfileMAC)
+	}
+
+	// it might still be known as an orphan, in which case the
+	// packfile it lived in tells whether maintenance removed it.
+	for entry, err := range repo.ListOrphanBlobs() {
+		if err != nil {
+			return 1, err
+		}
+		if entry.Type != cmd.Type || entry.Blob != cmd.MAC {
+			continue
+		}
+
+		fmt.Fprintf(ctx.Stdout, "Orphaned in packfile %x at offset %d, length %d\n",
+			entry.Location.Packfile, entry.Location.Offset, entry.Location.Length)
+
+		deleted, err := repo.HasDeletedPackfile(entry.Location.Packfile)
+		if err != nil {
+			return 1, err
+		}
+		if deleted {
+			fmt.Fprintf(ctx.Stdout, "Packfile %x was deleted by maintenance\n", entry.Location.Packfile)
+		}
+	}
+
+	return 1, fmt.Errorf("%s %x is not referenced by the state", cmd.Type, cmd.MAC)
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io"
 	"path"
+	"strings"
 
 	"github.com/PlakarKorp/plakar/appcontext"
 	"github.com/PlakarKorp/kloset/repository"
@@ -120,15 +121,7 @@ func diff_filesystems(ctx *appcontext.AppContext, snap1 *snapshot.Snapshot, snap
 		return "", err
 	}
 
-	var f1, f2 *vfs.Entry
-	if f1, err = vfs1.GetEntry("/"); err != nil {
-		return "", err
-	}
-	if f2, err = vfs2.GetEntry("/"); err != nil {
-		return "", err
-	}
-
-	return diff_directories(ctx, f1, f2)
+	return diff_directories(ctx, vfs1, "/", vfs2, "/")
 }
 
 func diff_pathnames(ctx *appcontext.AppContext, snap1 *snapshot.Snapshot, pathname1 string, snap2 *snapshot.Snapshot, pathname2 string) (string, error) {
@@ -151,7 +144,7 @@ func diff_pathnames(ctx *appcontext.AppContext, snap1 *snapshot.Snapshot, pathna
 	}
 
 	if f1.Stat().IsDir() && f2.Stat().IsDir() {
-		return diff_directories(ctx, f1, f2)
+		return diff_directories(ctx, vfs1, pathname1, vfs2, pathname2)
 	}
 
 	if f1.Stat().IsDir() || f2.Stat().IsDir() {
@@ -161,8 +154,38 @@ func diff_pathnames(ctx *appcontext.AppContext, snap1 *snapshot.Snapshot, pathna
 	return diff_files(ctx, snap1, f1, snap2, f2)
 }
 
-func diff_directories(_ *appcontext.AppContext, _ *vfs.Entry, _ *vfs.Entry) (string, error) {
-	return "", fmt.Errorf("not implemented yet")
+func diff_directories(ctx *appcontext.AppContext, vfs1 *vfs.Filesystem, pathname1 string, vfs2 *vfs.Filesystem, pathname2 string) (string, error) {
+	records, err := DiffTrees(ctx, vfs1, pathname1, vfs2, pathname2)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for record, err := range records {
+		if err != nil {
+			return "", err
+		}
+
+		var mark byte
+		var entry *vfs.Entry
+		switch record.ChangeType {
+		case Added:
+			mark, entry = '+', record.NewEntry
+		case Removed:
+			mark, entry = '-', record.OldEntry
+		case Modified:
+			mark, entry = '~', record.NewEntry
+		default:
+			continue
+		}
+
+		pathname := utils.SanitizeText(record.Path)
+		if entry.Stat().IsDir() {
+			pathname += "/"
+		}
+		fmt.Fprintf(&sb, "%c %s\n", mark, pathname)
+	}
+	return sb.String(), nil
 }
 
 func diff_files(ctx *appcontext.AppContext, snap1 *snapshot.Snapshot, fileEntry1 *vfs.Entry, snap2 *snapshot.Snapshot, fileEntry2 *vfs.Entry) (string, error) {
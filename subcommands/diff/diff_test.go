@@ -112,3 +112,95 @@ func TestExecuteCmdDiffFiles(t *testing.T) {
 -hello dummy
 +hello dummy!!`)
 }
+
+func TestDiffTrees(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+
+	snap1 := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockDir("another_subdir"),
+		ptesting.NewMockFile("subdir/dummy.txt", 0644, "hello dummy"),
+		ptesting.NewMockFile("subdir/foo.txt", 0644, "hello foo"),
+		ptesting.NewMockFile("subdir/removed.txt", 0644, "bye"),
+		ptesting.NewMockFile("subdir/moved.txt", 0644, "on the move"),
+	})
+	defer snap1.Close()
+
+	snap2 := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockDir("another_subdir"),
+		ptesting.NewMockFile("subdir/dummy.txt", 0644, "hello dummy!!"),
+		ptesting.NewMockFile("subdir/foo.txt", 0644, "hello foo"),
+		ptesting.NewMockFile("subdir/added.txt", 0644, "hi"),
+		ptesting.NewMockFile("another_subdir/moved.txt", 0644, "on the move"),
+	})
+	defer snap2.Close()
+
+	fs1, err := snap1.Filesystem()
+	require.NoError(t, err)
+	fs2, err := snap2.Filesystem()
+	require.NoError(t, err)
+
+	records, err := DiffTrees(ctx, fs1, "/", fs2, "/")
+	require.NoError(t, err)
+
+	changes := make(map[string]ChangeType)
+	for record, err := range records {
+		require.NoError(t, err)
+		changes[record.Path] = record.ChangeType
+	}
+
+	tests := []struct {
+		path   string
+		change ChangeType
+	}{
+		{"/subdir/added.txt", Added},
+		{"/subdir/removed.txt", Removed},
+		{"/subdir/dummy.txt", Modified},
+		{"/subdir/foo.txt", Unchanged},
+		{"/subdir/moved.txt", Removed},
+		{"/another_subdir/moved.txt", Added},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			change, ok := changes[test.path]
+			require.True(t, ok)
+			require.Equal(t, test.change, change)
+		})
+	}
+
+	// the same comparison below a directory yields relative paths
+	records, err = DiffTrees(ctx, fs1, "/subdir", fs2, "/subdir")
+	require.NoError(t, err)
+	changes = make(map[string]ChangeType)
+	for record, err := range records {
+		require.NoError(t, err)
+		changes[record.Path] = record.ChangeType
+	}
+	require.Equal(t, map[string]ChangeType{
+		"/added.txt":   Added,
+		"/removed.txt": Removed,
+		"/dummy.txt":   Modified,
+		"/foo.txt":     Unchanged,
+		"/moved.txt":   Removed,
+	}, changes)
+
+	subcommand := &Diff{}
+	indexId1 := snap1.Header.GetIndexShortID()
+	indexId2 := snap2.Header.GetIndexShortID()
+	err = subcommand.Parse(ctx, []string{hex.EncodeToString(indexId1[:]), hex.EncodeToString(indexId2[:])})
+	require.NoError(t, err)
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	output := bufOut.String()
+	require.Contains(t, output, "+ /subdir/added.txt\n")
+	require.Contains(t, output, "- /subdir/removed.txt\n")
+	require.Contains(t, output, "~ /subdir/dummy.txt\n")
+	require.NotContains(t, output, "/subdir/foo.txt")
+}
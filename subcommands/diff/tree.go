@@ -0,0 +1,173 @@
+package diff
+
+import (
+	"iter"
+	"strings"
+
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/snapshot/vfs"
+	"github.com/PlakarKorp/plakar/appcontext"
+)
+
+type ChangeType int
+
+const (
+	Unchanged ChangeType = iota
+	Added
+	Removed
+	Modified
+)
+
+func (c ChangeType) String() string {
+	switch c {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	default:
+		return "unchanged"
+	}
+}
+
+// DiffRecord describes how the entry at Path, relative to the compared
+// directories, changed between the two trees. OldEntry is nil for added
+// entries and NewEntry for removed ones; neither is resolved for
+// unchanged entries.
+type DiffRecord struct {
+	Path       string
+	ChangeType ChangeType
+	OldEntry   *vfs.Entry
+	NewEntry   *vfs.Entry
+}
+
+// treeCursor walks the VFS btree of a snapshot, keeping only the entries
+// below root, in the order of the btree.
+type treeCursor struct {
+	root string
+	next func() (string, objects.MAC, bool)
+	err  func() error
+}
+
+func newTreeCursor(fs *vfs.Filesystem, root string) (*treeCursor, error) {
+	tree, _, _ := fs.BTrees()
+	it, err := tree.ScanAll()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.TrimSuffix(root, "/") + "/"
+	return &treeCursor{
+		root: strings.TrimSuffix(root, "/"),
+		next: func() (string, objects.MAC, bool) {
+			for it.Next() {
+				path, mac := it.Current()
+				if strings.HasPrefix(path, prefix) && path != prefix {
+					return path, mac, true
+				}
+			}
+			return "", objects.MAC{}, false
+		},
+		err: it.Err,
+	}, nil
+}
+
+// relative returns the path of an entry below the root of the cursor,
+// so that entries of different roots can be compared. The depth of all
+// the paths of a tree is shifted by the same amount, which keeps them
+// in the order of vfs.PathCmp.
+func (c *treeCursor) relative(path string) string {
+	return path[len(c.root):]
+}
+
+func sameEntry(e1, e2 *vfs.Entry) bool {
+	s1, s2 := e1.Stat(), e2.Stat()
+	if s1.Mode() != s2.Mode() {
+		return false
+	}
+	// directories change with their content, which is compared on its own
+	if s1.IsDir() {
+		return true
+	}
+	return e1.Object == e2.Object &&
+		s1.Size() == s2.Size() &&
+		s1.ModTime().Equal(s2.ModTime())
+}
+
+// DiffTrees compares the entries below root1 in fs1 with those below
+// root2 in fs2, walking both VFS btrees side by side.
+func DiffTrees(ctx *appcontext.AppContext, fs1 *vfs.Filesystem, root1 string, fs2 *vfs.Filesystem, root2 string) (iter.Seq2[DiffRecord, error], error) {
+	c1, err := newTreeCursor(fs1, root1)
+	if err != nil {
+		return nil, err
+	}
+	c2, err := newTreeCursor(fs2, root2)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(yield func(DiffRecord, error) bool) {
+		p1, mac1, ok1 := c1.next()
+		p2, mac2, ok2 := c2.next()
+
+		for ok1 || ok2 {
+			if err := ctx.Err(); err != nil {
+				yield(DiffRecord{}, err)
+				return
+			}
+
+			var cmp int
+			switch {
+			case !ok2:
+				cmp = -1
+			case !ok1:
+				cmp = 1
+			default:
+				cmp = vfs.PathCmp(c1.relative(p1), c2.relative(p2))
+			}
+
+			var record DiffRecord
+			var err error
+			switch {
+			case cmp < 0:
+				record = DiffRecord{Path: c1.relative(p1), ChangeType: Removed}
+				record.OldEntry, err = fs1.ResolveEntry(mac1)
+				p1, mac1, ok1 = c1.next()
+
+			case cmp > 0:
+				record = DiffRecord{Path: c2.relative(p2), ChangeType: Added}
+				record.NewEntry, err = fs2.ResolveEntry(mac2)
+				p2, mac2, ok2 = c2.next()
+
+			case mac1 == mac2:
+				record = DiffRecord{Path: c1.relative(p1), ChangeType: Unchanged}
+				p1, mac1, ok1 = c1.next()
+				p2, mac2, ok2 = c2.next()
+
+			default:
+				record = DiffRecord{Path: c1.relative(p1), ChangeType: Modified}
+				record.OldEntry, err = fs1.ResolveEntry(mac1)
+				if err == nil {
+					record.NewEntry, err = fs2.ResolveEntry(mac2)
+				}
+				if err == nil && sameEntry(record.OldEntry, record.NewEntry) {
+					record.ChangeType = Unchanged
+				}
+				p1, mac1, ok1 = c1.next()
+				p2, mac2, ok2 = c2.next()
+			}
+
+			if !yield(record, err) {
+				return
+			}
+		}
+
+		for _, c := range []*treeCursor{c1, c2} {
+			if err := c.err(); err != nil {
+				yield(DiffRecord{}, err)
+				return
+			}
+		}
+	}, nil
+}
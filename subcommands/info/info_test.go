@@ -166,6 +166,31 @@ func TestExecuteCmdInfoSnapshot(t *testing.T) {
 	require.Contains(t, output, fmt.Sprintf("SnapshotID: %s", hex.EncodeToString(indexId[:])))
 }
 
+func TestExecuteCmdInfoSnapshotMIME(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, snap, ctx := generateSnapshot(t, bufOut, bufErr)
+	defer snap.Close()
+
+	indexId := snap.Header.GetIndexID()
+	info := func(args ...string) string {
+		bufOut.Reset()
+		subcommand, _, args := subcommands.Lookup(append([]string{"info", "snapshot"}, args...))
+		require.NoError(t, subcommand.Parse(ctx, args))
+
+		status, err := subcommand.Execute(ctx, repo)
+		require.NoError(t, err)
+		require.Equal(t, 0, status)
+		return bufOut.String()
+	}
+
+	require.NotContains(t, info(hex.EncodeToString(indexId[:])), "MIMETypes:")
+
+	output := info("-mime", hex.EncodeToString(indexId[:]))
+	require.Contains(t, output, "MIMETypes:\n - text: 4 files, 49 B (49 bytes)\n")
+}
+
 func TestExecuteCmdInfoSnapshotPath(t *testing.T) {
 	bufOut := bytes.NewBuffer(nil)
 	bufErr := bytes.NewBuffer(nil)
@@ -211,3 +236,37 @@ func TestExecuteCmdInfoSnapshotPath(t *testing.T) {
 	require.Contains(t, output, "[FileEntry]")
 	require.Contains(t, output, "Name: dummy.txt")
 }
+
+func TestExecuteCmdInfoSnapshotSize(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/a.txt", 0644, "the same content"),
+		ptesting.NewMockFile("subdir/b.txt", 0644, "the same content"),
+	})
+	defer snap.Close()
+
+	logical, deduplicated, err := snapshotSize(snap, "/")
+	require.NoError(t, err)
+	require.Equal(t, int64(32), logical)
+	require.Less(t, deduplicated, logical)
+
+	indexId := snap.Header.GetIndexID()
+	args := []string{"info", "snapshot", "-size", "/", hex.EncodeToString(indexId[:])}
+
+	subcommand, _, args := subcommands.Lookup(args)
+	err = subcommand.Parse(ctx, args)
+	require.NoError(t, err)
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	output := bufOut.String()
+	require.Contains(t, output, "LogicalSize: 32 B (32 bytes)\n")
+	require.Contains(t, output, "DeduplicatedSize: 16 B (16 bytes)\n")
+	require.Contains(t, output, "DedupRatio: 2.00\n")
+}
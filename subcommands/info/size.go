@@ -0,0 +1,38 @@
+package info
+
+import (
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/snapshot"
+)
+
+// snapshotSize returns the logical size of the files below prefix, i.e.
+// the sum of their sizes, and their deduplicated size, i.e. the sum of
+// the lengths of the distinct chunks they reference, counting once the
+// chunks shared between files.
+func snapshotSize(snap *snapshot.Snapshot, prefix string) (logical, deduplicated int64, err error) {
+	fs, err := snap.Filesystem()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	seen := make(map[objects.MAC]struct{})
+	for entry, err := range fs.Files(prefix) {
+		if err != nil {
+			return 0, 0, err
+		}
+
+		logical += entry.Stat().Size()
+		if entry.ResolvedObject == nil {
+			continue
+		}
+		for _, chunk := range entry.ResolvedObject.Chunks {
+			if _, ok := seen[chunk.ContentMAC]; ok {
+				continue
+			}
+			seen[chunk.ContentMAC] = struct{}{}
+			deduplicated += int64(chunk.Length)
+		}
+	}
+
+	return logical, deduplicated, nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"flag"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -20,10 +21,18 @@ type InfoSnapshot struct {
 	subcommands.SubcommandBase
 
 	SnapshotID string
+	Health     bool
+	RPO        time.Duration
+	SizePath   string
+	MIME       bool
 }
 
 func (cmd *InfoSnapshot) Parse(ctx *appcontext.AppContext, args []string) error {
 	flags := flag.NewFlagSet("info snapshot", flag.ExitOnError)
+	flags.BoolVar(&cmd.Health, "health", false, "only display the health score of the snapshot")
+	flags.DurationVar(&cmd.RPO, "rpo", utils.DefaultRPO, "recovery point objective the snapshot age is scored against")
+	flags.StringVar(&cmd.SizePath, "size", "", "only display the logical and deduplicated sizes of the files below this path")
+	flags.BoolVar(&cmd.MIME, "mime", false, "also display the number and size of the files of each MIME type")
 	flags.Parse(args)
 
 	if len(flags.Args()) < 1 {
@@ -45,6 +54,29 @@ func (cmd *InfoSnapshot) Execute(ctx *appcontext.AppContext, repo *repository.Re
 
 	header := snap.Header
 
+	if cmd.Health {
+		health := utils.SnapshotHealth(header, cmd.RPO, time.Now())
+		fmt.Fprintf(ctx.Stdout, "HealthScore: %d\n", health.Score)
+		fmt.Fprintf(ctx.Stdout, "Age: %s\n", health.Age.Round(time.Second))
+		fmt.Fprintf(ctx.Stdout, "Errors: %d\n", health.Errors)
+		fmt.Fprintf(ctx.Stdout, "Signed: %t\n", health.Signed)
+		return 0, nil
+	}
+
+	if cmd.SizePath != "" {
+		logical, deduplicated, err := snapshotSize(snap, cmd.SizePath)
+		if err != nil {
+			return 1, err
+		}
+		fmt.Fprintf(ctx.Stdout, "Path: %s\n", utils.SanitizeText(cmd.SizePath))
+		fmt.Fprintf(ctx.Stdout, "LogicalSize: %s (%d bytes)\n", humanize.Bytes(uint64(logical)), logical)
+		fmt.Fprintf(ctx.Stdout, "DeduplicatedSize: %s (%d bytes)\n", humanize.Bytes(uint64(deduplicated)), deduplicated)
+		if deduplicated != 0 {
+			fmt.Fprintf(ctx.Stdout, "DedupRatio: %.2f\n", float64(logical)/float64(deduplicated))
+		}
+		return 0, nil
+	}
+
 	indexID := header.GetIndexID()
 	fmt.Fprintf(ctx.Stdout, "Version: %s\n", repo.Configuration().Version)
 	fmt.Fprintf(ctx.Stdout, "SnapshotID: %s\n", hex.EncodeToString(indexID[:]))
@@ -114,5 +146,28 @@ func (cmd *InfoSnapshot) Execute(ctx *appcontext.AppContext, repo *repository.Re
 	fmt.Fprintf(ctx.Stdout, " - MIMEOther: %d\n", header.GetSource(0).Summary.Directory.MIMEOther+header.GetSource(0).Summary.Below.MIMEOther)
 
 	fmt.Fprintf(ctx.Stdout, " - Errors: %d\n", header.GetSource(0).Summary.Directory.Errors+header.GetSource(0).Summary.Below.Errors)
+
+	// this resolves every file of the snapshot, only do it on request.
+	if !cmd.MIME {
+		return 0, nil
+	}
+
+	stats, err := utils.GetSnapshotStatistics(snap)
+	if err != nil {
+		return 1, err
+	}
+	if len(stats.MIME) > 0 {
+		mimes := make([]string, 0, len(stats.MIME))
+		for mime := range stats.MIME {
+			mimes = append(mimes, mime)
+		}
+		sort.Strings(mimes)
+
+		fmt.Fprintln(ctx.Stdout, "MIMETypes:")
+		for _, mime := range mimes {
+			s := stats.MIME[mime]
+			fmt.Fprintf(ctx.Stdout, " - %s: %d files, %s (%d bytes)\n", mime, s.Files, humanize.Bytes(s.Size), s.Size)
+		}
+	}
 	return 0, nil
 }
@@ -17,6 +17,7 @@
 package locate
 
 import (
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"path"
@@ -25,6 +26,7 @@ import (
 	"github.com/PlakarKorp/kloset/objects"
 	"github.com/PlakarKorp/kloset/repository"
 	"github.com/PlakarKorp/kloset/snapshot"
+	"github.com/PlakarKorp/kloset/snapshot/vfs"
 	"github.com/PlakarKorp/plakar/subcommands"
 	"github.com/PlakarKorp/plakar/utils"
 )
@@ -43,10 +45,21 @@ func (cmd *Locate) Parse(ctx *appcontext.AppContext, args []string) error {
 		flags.PrintDefaults()
 	}
 
+	var contentHash string
 	flags.StringVar(&cmd.Snapshot, "snapshot", "", "snapshot to locate in")
+	flags.StringVar(&contentHash, "content-hash", "", "only locate files whose content MAC, as printed by manifest, matches this hex value")
 	cmd.LocateOptions.InstallFlags(flags)
 	flags.Parse(args)
 
+	if contentHash != "" {
+		mac, err := hex.DecodeString(contentHash)
+		if err != nil || len(mac) != len(objects.MAC{}) {
+			return fmt.Errorf("invalid content hash: %s", contentHash)
+		}
+		cmd.ContentHash = &objects.MAC{}
+		copy(cmd.ContentHash[:], mac)
+	}
+
 	if cmd.Snapshot != "" && !cmd.LocateOptions.Empty() {
 		ctx.GetLogger().Warn("snapshot specified, filters will be ignored")
 	}
@@ -64,6 +77,7 @@ type Locate struct {
 
 	LocateOptions *utils.LocateOptions
 	Snapshot      string
+	ContentHash   *objects.MAC
 	Patterns      []string
 }
 
@@ -91,35 +105,85 @@ func (cmd *Locate) Execute(ctx *appcontext.AppContext, repo *repository.Reposito
 			snap.Close()
 			return 1, fmt.Errorf("locate: could not get filesystem: %w", err)
 		}
-		for pathname, err := range fs.Pathnames() {
-			if err != nil {
-				snap.Close()
-				return 1, fmt.Errorf("locate: could not get pathname: %w", err)
-			}
+		if cmd.ContentHash != nil {
+			err = cmd.locateContent(ctx, snap, fs)
+		} else {
+			err = cmd.locatePathnames(ctx, snap, fs)
+		}
+		if err != nil {
+			snap.Close()
+			return 1, err
+		}
+		snap.Close()
+	}
+	return 0, nil
+}
 
-			if err := ctx.Err(); err != nil {
-				return 1, err
-			}
+func (cmd *Locate) match(pathname string) (bool, error) {
+	for _, pattern := range cmd.Patterns {
+		if path.Base(pathname) == pattern {
+			return true, nil
+		}
+		matched, err := path.Match(pattern, path.Base(pathname))
+		if err != nil {
+			return false, fmt.Errorf("locate: could not match pattern: %w", err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (cmd *Locate) locatePathnames(ctx *appcontext.AppContext, snap *snapshot.Snapshot, fs *vfs.Filesystem) error {
+	for pathname, err := range fs.Pathnames() {
+		if err != nil {
+			return fmt.Errorf("locate: could not get pathname: %w", err)
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		matched, err := cmd.match(pathname)
+		if err != nil {
+			return err
+		}
+		if matched {
+			fmt.Fprintf(ctx.Stdout, "%x:%s\n", snap.Header.Identifier[0:4], utils.SanitizeText(pathname))
+		}
+	}
+	return nil
+}
+
+// locateContent walks the files of the snapshot looking for those whose
+// content MAC is the one searched. The VFS is not indexed by content, so
+// every file entry has to be visited.
+func (cmd *Locate) locateContent(ctx *appcontext.AppContext, snap *snapshot.Snapshot, fs *vfs.Filesystem) error {
+	for entry, err := range fs.Files("/") {
+		if err != nil {
+			return fmt.Errorf("locate: could not get file: %w", err)
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if entry.ResolvedObject == nil || entry.ResolvedObject.ContentMAC != *cmd.ContentHash {
+			continue
+		}
 
-			for _, pattern := range cmd.Patterns {
-				matched := false
-				if path.Base(pathname) == pattern {
-					matched = true
-				}
-				if !matched {
-					matched, err := path.Match(pattern, path.Base(pathname))
-					if err != nil {
-						snap.Close()
-						return 1, fmt.Errorf("locate: could not match pattern: %w", err)
-					}
-					if !matched {
-						continue
-					}
-				}
-				fmt.Fprintf(ctx.Stdout, "%x:%s\n", snap.Header.Identifier[0:4], utils.SanitizeText(pathname))
+		pathname := entry.Path()
+		if len(cmd.Patterns) != 0 {
+			matched, err := cmd.match(pathname)
+			if err != nil {
+				return err
+			}
+			if !matched {
+				continue
 			}
 		}
-		snap.Close()
+		fmt.Fprintf(ctx.Stdout, "%x:%s\n", snap.Header.Identifier[0:4], utils.SanitizeText(pathname))
 	}
-	return 0, nil
+	return nil
 }
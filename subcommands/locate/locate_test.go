@@ -2,6 +2,7 @@ package locate
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/hex"
 	"os"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"github.com/PlakarKorp/kloset/snapshot"
 	"github.com/PlakarKorp/plakar/appcontext"
 	_ "github.com/PlakarKorp/plakar/connectors/fs/exporter"
+	"github.com/PlakarKorp/plakar/subcommands/manifest"
 	ptesting "github.com/PlakarKorp/plakar/testing"
 	"github.com/stretchr/testify/require"
 )
@@ -85,3 +87,56 @@ func TestExecuteCmdLocateWithSnapshotId(t *testing.T) {
 	lines := strings.Split(strings.Trim(output, "\n"), "\n")
 	require.Equal(t, 1, len(lines))
 }
+
+func TestExecuteCmdLocateWithContentHash(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, snap, ctx := generateSnapshot(t, bufOut, bufErr)
+	defer snap.Close()
+
+	// the MAC printed by manifest is the one to look for
+	manifestCmd := &manifest.Manifest{}
+	require.NoError(t, manifestCmd.Parse(ctx, []string{hex.EncodeToString(snap.Header.Identifier[:]) + ":/subdir/foo.txt"}))
+	status, err := manifestCmd.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+	mac := strings.Fields(bufOut.String())[0]
+	bufOut.Reset()
+
+	subcommand := &Locate{}
+	err = subcommand.Parse(ctx, []string{"-content-hash", mac})
+	require.NoError(t, err)
+
+	status, err = subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	output := strings.Trim(bufOut.String(), "\n")
+	require.Equal(t, hex.EncodeToString(snap.Header.Identifier[0:4])+":/subdir/foo.txt", output)
+
+	// a plain digest of the contents is not a content MAC
+	bufOut.Reset()
+	digest := sha256.Sum256([]byte("hello foo"))
+
+	subcommand = &Locate{}
+	err = subcommand.Parse(ctx, []string{"-content-hash", hex.EncodeToString(digest[:])})
+	require.NoError(t, err)
+
+	status, err = subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+	require.Empty(t, bufOut.String())
+}
+
+func TestExecuteCmdLocateWithInvalidContentHash(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	_, snap, ctx := generateSnapshot(t, bufOut, bufErr)
+	defer snap.Close()
+
+	subcommand := &Locate{}
+	err := subcommand.Parse(ctx, []string{"-content-hash", "abcd"})
+	require.Error(t, err)
+}
@@ -50,6 +50,7 @@ func (cmd *Ls) Parse(ctx *appcontext.AppContext, args []string) error {
 
 	flags.BoolVar(&cmd.DisplayUUID, "uuid", false, "display uuid instead of short ID")
 	flags.BoolVar(&cmd.Recursive, "recursive", false, "recursive listing")
+	flags.BoolVar(&cmd.UniqueSize, "unique-size", false, "display the size of the chunks only referenced by each snapshot (slow)")
 	cmd.LocateOptions.InstallFlags(flags)
 
 	flags.Parse(args)
@@ -70,6 +71,7 @@ type Ls struct {
 	LocateOptions *utils.LocateOptions
 	Recursive     bool
 	DisplayUUID   bool
+	UniqueSize    bool
 	Path          string
 }
 
@@ -96,26 +98,41 @@ func (cmd *Ls) list_snapshots(ctx *appcontext.AppContext, repo *repository.Repos
 		return fmt.Errorf("ls: could not fetch snapshots list: %w", err)
 	}
 
+	var uniqueSizes map[objects.MAC]uint64
+	if cmd.UniqueSize {
+		uniqueSizes, err = computeUniqueSizes(ctx, repo)
+		if err != nil {
+			return fmt.Errorf("ls: could not compute unique sizes: %w", err)
+		}
+	}
+
 	for _, snapshotID := range snapshotIDs {
 		snap, err := snapshot.Load(repo, snapshotID)
 		if err != nil {
 			return fmt.Errorf("ls: could not fetch snapshot: %w", err)
 		}
 
+		unique := ""
+		if cmd.UniqueSize {
+			unique = fmt.Sprintf(" unique: %8s", humanize.Bytes(uniqueSizes[snapshotID]))
+		}
+
 		if !cmd.DisplayUUID {
-			fmt.Fprintf(ctx.Stdout, "%s %10s%10s%10s %s\n",
+			fmt.Fprintf(ctx.Stdout, "%s %10s%10s%10s%s %s\n",
 				snap.Header.Timestamp.UTC().Format(time.RFC3339),
 				hex.EncodeToString(snap.Header.GetIndexShortID()),
 				humanize.Bytes(snap.Header.GetSource(0).Summary.Directory.Size+snap.Header.GetSource(0).Summary.Below.Size),
 				snap.Header.Duration.Round(time.Second),
+				unique,
 				utils.SanitizeText(snap.Header.GetSource(0).Importer.Directory))
 		} else {
 			indexID := snap.Header.GetIndexID()
-			fmt.Fprintf(ctx.Stdout, "%s %3s%10s%10s %s\n",
+			fmt.Fprintf(ctx.Stdout, "%s %3s%10s%10s%s %s\n",
 				snap.Header.Timestamp.UTC().Format(time.RFC3339),
 				hex.EncodeToString(indexID[:]),
 				humanize.Bytes(snap.Header.GetSource(0).Summary.Directory.Size+snap.Header.GetSource(0).Summary.Below.Size),
 				snap.Header.Duration.Round(time.Second),
+				unique,
 				utils.SanitizeText(snap.Header.GetSource(0).Importer.Directory))
 		}
 
@@ -145,3 +145,41 @@ func TestExecuteCmdLsFilterUuid(t *testing.T) {
 	require.Equal(t, hex.EncodeToString(indexId[:]), fields[1])
 	require.Equal(t, snap.Header.GetSource(0).Importer.Directory, fields[len(fields)-1])
 }
+
+func TestExecuteCmdLsUniqueSize(t *testing.T) {
+	// Create a pipe to capture stdout
+	old := os.Stdout
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	os.Stdout = w
+
+	repo, snap, ctx := generateSnapshot(t)
+	defer snap.Close()
+
+	args := []string{"-unique-size"}
+
+	subcommand := &Ls{}
+	err = subcommand.Parse(ctx, args)
+	require.NoError(t, err)
+	require.NotNil(t, subcommand)
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	// Close the write end of the pipe and restore stdout
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+
+	output := buf.String()
+	lines := strings.Split(strings.Trim(output, "\n"), "\n")
+	require.Equal(t, 1, len(lines))
+	fields := strings.Fields(lines[0])
+	require.Equal(t, 9, len(fields))
+	require.Equal(t, "unique:", fields[5])
+	require.Equal(t, "11", fields[6])
+	require.Equal(t, "B", fields[7])
+}
@@ -0,0 +1,76 @@
+package ls
+
+import (
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/snapshot"
+	"github.com/PlakarKorp/kloset/snapshot/vfs"
+	"github.com/PlakarKorp/plakar/appcontext"
+)
+
+type chunkOwner struct {
+	snapshot int // index of the only referencing snapshot, -1 if shared
+	length   uint32
+}
+
+// computeUniqueSizes returns, for every snapshot of the repository, the
+// size of the chunks that no other snapshot references.  All snapshots
+// are considered, not only the listed ones, as a chunk shared with a
+// filtered out snapshot is not freed by removing the listed one.
+func computeUniqueSizes(ctx *appcontext.AppContext, repo *repository.Repository) (map[objects.MAC]uint64, error) {
+	var snapshotIDs []objects.MAC
+	for snapshotID := range repo.ListSnapshots() {
+		snapshotIDs = append(snapshotIDs, snapshotID)
+	}
+
+	// first pass: find the owner of every chunk
+	owners := make(map[objects.MAC]*chunkOwner)
+	for i, snapshotID := range snapshotIDs {
+		snap, err := snapshot.Load(repo, snapshotID)
+		if err != nil {
+			return nil, err
+		}
+
+		fs, err := snap.Filesystem()
+		if err != nil {
+			snap.Close()
+			return nil, err
+		}
+
+		err = fs.WalkDir("/", func(path string, entry *vfs.Entry, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if entry.ResolvedObject == nil {
+				return nil
+			}
+
+			for _, chunk := range entry.ResolvedObject.Chunks {
+				owner, ok := owners[chunk.ContentMAC]
+				if !ok {
+					owners[chunk.ContentMAC] = &chunkOwner{snapshot: i, length: chunk.Length}
+				} else if owner.snapshot != i {
+					owner.snapshot = -1
+				}
+			}
+			return nil
+		})
+		snap.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// second pass: account the chunks with a single owner
+	sizes := make(map[objects.MAC]uint64, len(snapshotIDs))
+	for _, owner := range owners {
+		if owner.snapshot != -1 {
+			sizes[snapshotIDs[owner.snapshot]] += uint64(owner.length)
+		}
+	}
+
+	return sizes, nil
+}
@@ -0,0 +1,50 @@
+package maintenance
+
+import (
+	"fmt"
+
+	"github.com/PlakarKorp/plakar/appcontext"
+)
+
+// compactState replaces all the states of the repository with a single
+// one holding their aggregate, so that rebuilding the state no longer
+// has to fetch and merge every state ever written.  The aggregate holds
+// every blob location recorded by the merged states, one per blob and
+// packfile, including those pointing to packfiles since removed: only
+// the number of states shrinks.
+func (cmd *Maintenance) compactState(ctx *appcontext.AppContext) (int, error) {
+	done, err := cmd.Lock()
+	if err != nil {
+		return 1, err
+	}
+	defer cmd.Unlock(done)
+
+	// The exclusive lock guarantees no backup is writing a state
+	// anymore, make sure all of those written so far are aggregated.
+	if err := cmd.repository.RebuildState(); err != nil {
+		return 1, fmt.Errorf("failed to rebuild state: %w", err)
+	}
+
+	states, err := cmd.repository.GetStates()
+	if err != nil {
+		return 1, fmt.Errorf("failed to list states: %w", err)
+	}
+
+	if err := cmd.repository.PutCurrentState(); err != nil {
+		return 1, fmt.Errorf("failed to put compacted state: %w", err)
+	}
+
+	// Only delete the previous states once the compacted one is safely
+	// stored, failing here leaves redundant states behind but loses
+	// nothing.
+	for _, stateID := range states {
+		if err := cmd.repository.DeleteState(stateID); err != nil {
+			return 1, fmt.Errorf("failed to delete state %x: %w", stateID, err)
+		}
+	}
+
+	invalidateStats(ctx, cmd.repository)
+
+	fmt.Fprintf(ctx.Stdout, "maintenance: compacted %d states\n", len(states))
+	return 0, nil
+}
@@ -41,8 +41,12 @@ func init() {
 func (cmd *Maintenance) Parse(ctx *appcontext.AppContext, args []string) error {
 	flags := flag.NewFlagSet("maintenance", flag.ExitOnError)
 	flags.Usage = func() {
-		fmt.Fprintf(flags.Output(), "Usage: %s\n", flags.Name())
+		fmt.Fprintf(flags.Output(), "Usage: %s [OPTIONS]\n", flags.Name())
+		fmt.Fprintf(flags.Output(), "\nOPTIONS:\n")
+		flags.PrintDefaults()
 	}
+	flags.BoolVar(&cmd.VerifyImmutability, "verify-immutability", false, "report packfiles not protected by an object lock, without removing anything")
+	flags.BoolVar(&cmd.CompactState, "compact-state", false, "merge all the states of the repository into a single one")
 	flags.Parse(args)
 
 	cmd.RepositorySecret = ctx.GetSecret()
@@ -53,6 +57,9 @@ func (cmd *Maintenance) Parse(ctx *appcontext.AppContext, args []string) error {
 type Maintenance struct {
 	subcommands.SubcommandBase
 
+	VerifyImmutability bool
+	CompactState       bool
+
 	repository    *repository.Repository
 	maintenanceID objects.MAC
 	cutoff        time.Time
@@ -295,6 +302,15 @@ func (cmd *Maintenance) Execute(ctx *appcontext.AppContext, repo *repository.Rep
 
 	cmd.repository = repo
 
+	if cmd.VerifyImmutability {
+		return cmd.verifyImmutability(ctx)
+	}
+
+	if cmd.CompactState {
+		cmd.maintenanceID = objects.RandomMAC()
+		return cmd.compactState(ctx)
+	}
+
 	// This need to be configurable per repo, but we don't have a mechanism yet (comes in a PR soon!)
 	duration, err := time.ParseDuration(os.Getenv("PLAKAR_GRACEPERIOD"))
 	if err != nil {
@@ -333,6 +349,60 @@ func (cmd *Maintenance) Execute(ctx *appcontext.AppContext, repo *repository.Rep
 		return 1, err
 	}
 
+	invalidateStats(ctx, cmd.repository)
+
+	return 0, nil
+}
+
+// invalidateStats lets the repository statistics served by the API be
+// recomputed now that maintenance changed the snapshots, packfiles or
+// states.
+func invalidateStats(ctx *appcontext.AppContext, repo *repository.Repository) {
+	repositoryID := repo.Configuration().RepositoryID
+	if err := ctx.GetCookies().InvalidateRepositoryStats(repositoryID); err != nil {
+		fmt.Fprintf(ctx.Stderr, "maintenance: Failed to invalidate repository stats %s\n", err)
+	}
+}
+
+// immutableStore is implemented by storage backends able to protect
+// packfiles from deletion, such as S3 with object lock.
+type immutableStore interface {
+	IsPackfileImmutable(mac objects.MAC) (bool, error)
+}
+
+func (cmd *Maintenance) verifyImmutability(ctx *appcontext.AppContext) (int, error) {
+	store, ok := cmd.repository.Store().(immutableStore)
+	if !ok {
+		return 1, fmt.Errorf("storage backend does not support immutable packfiles")
+	}
+
+	packfiles, err := cmd.repository.GetPackfiles()
+	if err != nil {
+		return 1, err
+	}
+
+	mutable := 0
+	for _, packfileMAC := range packfiles {
+		if err := ctx.Err(); err != nil {
+			return 1, err
+		}
+
+		immutable, err := store.IsPackfileImmutable(packfileMAC)
+		if err != nil {
+			fmt.Fprintf(ctx.Stderr, "maintenance: Failed to check packfile %x: %s\n", packfileMAC, err)
+			mutable++
+			continue
+		}
+		if !immutable {
+			fmt.Fprintf(ctx.Stdout, "maintenance: packfile %x is not locked\n", packfileMAC)
+			mutable++
+		}
+	}
+
+	fmt.Fprintf(ctx.Stdout, "maintenance: %d/%d packfiles are locked\n", len(packfiles)-mutable, len(packfiles))
+	if mutable != 0 {
+		return 1, fmt.Errorf("%d packfiles are not locked", mutable)
+	}
 	return 0, nil
 }
 
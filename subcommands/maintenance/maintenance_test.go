@@ -6,7 +6,12 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/PlakarKorp/kloset/caching"
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/resources"
 	_ "github.com/PlakarKorp/plakar/connectors/fs/exporter"
 	ptesting "github.com/PlakarKorp/plakar/testing"
 	"github.com/stretchr/testify/require"
@@ -30,6 +35,8 @@ func TestExecuteCmdMaintenanceDefault(t *testing.T) {
 		ptesting.NewMockFile("another_subdir/bar.txt", 0644, "hello bar"),
 	})
 
+	waitForLocks(t, repo)
+
 	indexId := snap.Header.GetIndexID()
 	args := []string{fmt.Sprintf("%s", hex.EncodeToString(indexId[:]))}
 
@@ -49,3 +56,115 @@ func TestExecuteCmdMaintenanceDefault(t *testing.T) {
 	require.Contains(t, output, "maintenance: Coloured 0 packfiles (0 orphaned) for deletion")
 	require.Contains(t, output, "maintenance: 0 blobs and 0 packfiles were removed")
 }
+
+func TestExecuteCmdMaintenanceCompactState(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	snap1 := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/dummy.txt", 0644, "hello dummy"),
+	})
+	snap2 := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/foo.txt", 0644, "hello foo"),
+	})
+
+	waitForLocks(t, repo)
+
+	states, err := repo.GetStates()
+	require.NoError(t, err)
+	require.Greater(t, len(states), 1)
+
+	subcommand := &Maintenance{}
+	err = subcommand.Parse(ctx, []string{"-compact-state"})
+	require.NoError(t, err)
+	require.True(t, subcommand.CompactState)
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	require.Contains(t, bufOut.String(), fmt.Sprintf("maintenance: compacted %d states", len(states)))
+	require.False(t, ctx.GetCookies().RepositoryStatsInvalidatedAt(repo.Configuration().RepositoryID).IsZero())
+
+	compacted, err := repo.GetStates()
+	require.NoError(t, err)
+	require.Len(t, compacted, 1)
+	require.NotContains(t, states, compacted[0])
+
+	// the compacted state still knows about every snapshot and blob
+	require.NoError(t, repo.RebuildState())
+	snapshots := map[objects.MAC]struct{}{}
+	for snapshotID := range repo.ListSnapshots() {
+		snapshots[snapshotID] = struct{}{}
+	}
+	require.Contains(t, snapshots, snap1.Header.Identifier)
+	require.Contains(t, snapshots, snap2.Header.Identifier)
+
+	fs, err := snap2.Filesystem()
+	require.NoError(t, err)
+	files := 0
+	for entry, err := range fs.Files("/") {
+		require.NoError(t, err)
+		if !entry.HasObject() {
+			continue
+		}
+		require.True(t, repo.BlobExists(resources.RT_OBJECT, entry.Object))
+		for _, chunk := range entry.ResolvedObject.Chunks {
+			require.True(t, repo.BlobExists(resources.RT_CHUNK, chunk.ContentMAC))
+		}
+		files++
+	}
+	require.Equal(t, 1, files)
+}
+
+// waitForLocks waits for the shared locks of the backups to be released,
+// which happens asynchronously once they are done.
+func waitForLocks(tb testing.TB, repo *repository.Repository) {
+	require.Eventually(tb, func() bool {
+		locks, err := repo.GetLocks()
+		return err == nil && len(locks) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// BenchmarkRebuildState measures rebuilding the state from an empty cache,
+// as on a new machine, before and after compacting 1000 states.
+func BenchmarkRebuildState(b *testing.B) {
+	repo, ctx := ptesting.GenerateRepository(b, bytes.NewBuffer(nil), bytes.NewBuffer(nil), nil)
+	for i := range 1000 {
+		snap := ptesting.GenerateSnapshot(b, repo, []ptesting.MockFile{
+			ptesting.NewMockDir("subdir"),
+			ptesting.NewMockFile("subdir/dummy.txt", 0644, fmt.Sprintf("hello dummy %d", i)),
+		})
+		snap.Close()
+		waitForLocks(b, repo)
+	}
+
+	rebuild := func(b *testing.B) {
+		for range b.N {
+			b.StopTimer()
+			manager := caching.NewManager(b.TempDir())
+			cache, err := manager.Repository(repo.Configuration().RepositoryID)
+			require.NoError(b, err)
+			b.StartTimer()
+
+			require.NoError(b, repo.RebuildStateWithCache(cache))
+
+			b.StopTimer()
+			manager.Close()
+			b.StartTimer()
+		}
+	}
+
+	b.Run("states=1000", rebuild)
+
+	subcommand := &Maintenance{}
+	require.NoError(b, subcommand.Parse(ctx, []string{"-compact-state"}))
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(b, err)
+	require.Equal(b, 0, status)
+
+	b.Run("compacted", rebuild)
+}
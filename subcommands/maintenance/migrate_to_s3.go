@@ -0,0 +1,273 @@
+package maintenance
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/PlakarKorp/kloset/hashing"
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/resources"
+	"github.com/PlakarKorp/kloset/storage"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/subcommands"
+	"github.com/PlakarKorp/plakar/utils"
+	"golang.org/x/sync/errgroup"
+)
+
+func init() {
+	subcommands.Register(func() subcommands.Subcommand { return &MigrateToS3{} }, subcommands.AgentSupport, "maintenance", "migrate-to-s3")
+}
+
+func (cmd *MigrateToS3) Parse(ctx *appcontext.AppContext, args []string) error {
+	flags := flag.NewFlagSet("maintenance migrate-to-s3", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [OPTIONS] s3://host/bucket | @store\n", flags.Name())
+		fmt.Fprintf(flags.Output(), "\nOPTIONS:\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: %s s3://host/bucket | @store", flags.Name())
+	}
+
+	storeConfig, err := ctx.Config.GetRepository(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(storeConfig["location"], "s3://") {
+		return fmt.Errorf("%s is not an S3 location", storeConfig["location"])
+	}
+
+	cmd.RepositorySecret = ctx.GetSecret()
+	cmd.Dest = flags.Arg(0)
+
+	return nil
+}
+
+type MigrateToS3 struct {
+	subcommands.SubcommandBase
+
+	Dest string
+}
+
+func (cmd *MigrateToS3) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
+	storeConfig, err := ctx.Config.GetRepository(cmd.Dest)
+	if err != nil {
+		return 1, err
+	}
+
+	dest, err := openDestination(ctx, repo, storeConfig)
+	if err != nil {
+		return 1, err
+	}
+	defer dest.Close()
+
+	if err := migrateStore(ctx, repo.Store(), dest); err != nil {
+		return 1, err
+	}
+
+	if err := switchStores(ctx, repo.Location(), storeConfig["location"]); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// switchStores points the configured stores at location from to the
+// location to.  Only the location changes, every other option of the
+// stores, such as their passphrase, is kept.
+func switchStores(ctx *appcontext.AppContext, from, to string) error {
+	if ctx.Config == nil {
+		return nil
+	}
+
+	switched := 0
+	for name, storeConfig := range ctx.Config.Repositories {
+		if storeConfig["location"] != from {
+			continue
+		}
+		storeConfig["location"] = to
+		ctx.GetLogger().Info("migrate-to-s3: store %q now points to %s", name, to)
+		switched++
+	}
+	if switched == 0 {
+		return nil
+	}
+	return utils.SaveConfig(ctx.ConfigDir, ctx.Config)
+}
+
+// migrateStore copies the packfiles then the states of src that dest
+// does not hold yet, and verifies the copies.
+func migrateStore(ctx *appcontext.AppContext, src, dest storage.Store) error {
+	packfiles, err := src.GetPackfiles()
+	if err != nil {
+		return fmt.Errorf("could not list packfiles: %w", err)
+	}
+	existing, err := dest.GetPackfiles()
+	if err != nil {
+		return fmt.Errorf("could not list destination packfiles: %w", err)
+	}
+	copied, err := migrateBlobs(ctx, packfiles, existing, src.GetPackfile, dest.PutPackfile, dest.GetPackfile)
+	if err != nil {
+		return fmt.Errorf("failed to migrate packfiles: %w", err)
+	}
+	ctx.GetLogger().Info("migrate-to-s3: %d packfiles copied, %d already present", copied, len(packfiles)-copied)
+
+	// states go last: they reference the packfiles, so a store is only
+	// usable once all of its packfiles are in place.
+	states, err := src.GetStates()
+	if err != nil {
+		return fmt.Errorf("could not list states: %w", err)
+	}
+	existing, err = dest.GetStates()
+	if err != nil {
+		return fmt.Errorf("could not list destination states: %w", err)
+	}
+	copied, err = migrateBlobs(ctx, states, existing, src.GetState, dest.PutState, dest.GetState)
+	if err != nil {
+		return fmt.Errorf("failed to migrate states: %w", err)
+	}
+	ctx.GetLogger().Info("migrate-to-s3: %d states copied, %d already present", copied, len(states)-copied)
+
+	return nil
+}
+
+// openDestination opens the destination store, creating it with the
+// configuration of repo unless a previous run already did.
+func openDestination(ctx *appcontext.AppContext, repo *repository.Repository, storeConfig map[string]string) (storage.Store, error) {
+	configuration := repo.Configuration()
+
+	if dest, serializedConfig, err := storage.Open(ctx.GetInner(), storeConfig); err == nil {
+		destConfig, err := storage.NewConfigurationFromWrappedBytes(serializedConfig)
+		if err != nil {
+			dest.Close()
+			return nil, err
+		}
+		if destConfig.RepositoryID != configuration.RepositoryID {
+			dest.Close()
+			return nil, fmt.Errorf("%s already holds another store", storeConfig["location"])
+		}
+		return dest, nil
+	}
+
+	serializedConfig, err := configuration.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var hasher hash.Hash
+	if configuration.Encryption != nil {
+		hasher = hashing.GetMACHasher(storage.DEFAULT_HASHING_ALGORITHM, ctx.GetSecret())
+	} else {
+		hasher = hashing.GetHasher(storage.DEFAULT_HASHING_ALGORITHM)
+	}
+
+	wrappedRd, err := storage.Serialize(hasher, resources.RT_CONFIG, configuration.Version, bytes.NewReader(serializedConfig))
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := io.ReadAll(wrappedRd)
+	if err != nil {
+		return nil, err
+	}
+
+	dest, err := storage.Create(ctx.GetInner(), storeConfig, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("could not create store: %w", err)
+	}
+	return dest, nil
+}
+
+type blobGetter func(objects.MAC) (io.Reader, error)
+type blobPutter func(objects.MAC, io.Reader) (int64, error)
+
+// missingBlobs returns the blobs of macs that are not in existing, which
+// is what is left to copy when resuming a migration.
+func missingBlobs(macs, existing []objects.MAC) map[objects.MAC]struct{} {
+	present := make(map[objects.MAC]struct{}, len(existing))
+	for _, mac := range existing {
+		present[mac] = struct{}{}
+	}
+
+	missing := make(map[objects.MAC]struct{})
+	for _, mac := range macs {
+		if _, ok := present[mac]; !ok {
+			missing[mac] = struct{}{}
+		}
+	}
+	return missing
+}
+
+// copyBlob copies a blob and returns the checksum of what was read.
+func copyBlob(mac objects.MAC, get blobGetter, put blobPutter) ([]byte, error) {
+	rd, err := get(mac)
+	if err != nil {
+		return nil, err
+	}
+	defer closeReader(rd)
+
+	hasher := sha256.New()
+	if _, err := put(mac, io.TeeReader(rd, hasher)); err != nil {
+		return nil, err
+	}
+	return hasher.Sum(nil), nil
+}
+
+// verifyBlob reads a blob back and compares it with the checksum of the
+// original.
+func verifyBlob(mac objects.MAC, get blobGetter, sum []byte) error {
+	rd, err := get(mac)
+	if err != nil {
+		return err
+	}
+	defer closeReader(rd)
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, rd); err != nil {
+		return err
+	}
+	if !bytes.Equal(sum, hasher.Sum(nil)) {
+		return fmt.Errorf("copy does not match the original")
+	}
+	return nil
+}
+
+// migrateBlobs copies and verifies the blobs that are not yet in
+// existing.  Those a previous run copied were verified back then and
+// are left alone.  It returns the number of blobs copied.
+func migrateBlobs(ctx *appcontext.AppContext, macs, existing []objects.MAC, get blobGetter, put blobPutter, verify blobGetter) (int, error) {
+	missing := missingBlobs(macs, existing)
+
+	wg := new(errgroup.Group)
+	wg.SetLimit(ctx.MaxConcurrency)
+	for mac := range missing {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		wg.Go(func() error {
+			sum, err := copyBlob(mac, get, put)
+			if err != nil {
+				return fmt.Errorf("%x: %w", mac, err)
+			}
+			if err := verifyBlob(mac, verify, sum); err != nil {
+				return fmt.Errorf("%x: %w", mac, err)
+			}
+			return nil
+		})
+	}
+
+	return len(missing), wg.Wait()
+}
+
+func closeReader(rd io.Reader) {
+	if closer, ok := rd.(io.Closer); ok {
+		closer.Close()
+	}
+}
@@ -0,0 +1,145 @@
+package maintenance
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/PlakarKorp/kloset/config"
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/storage"
+	ptesting "github.com/PlakarKorp/plakar/testing"
+	"github.com/PlakarKorp/plakar/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateBlobs(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/dummy.txt", 0644, "hello dummy"),
+		ptesting.NewMockFile("subdir/foo.txt", 0644, "hello foo"),
+	})
+	snap.Close()
+	waitForLocks(t, repo)
+
+	// another fs store stands for the bucket
+	destConfig := map[string]string{"location": filepath.Join(t.TempDir(), "repo")}
+	dest, err := openDestination(ctx, repo, destConfig)
+	require.NoError(t, err)
+	defer dest.Close()
+
+	src := repo.Store()
+	packfiles, err := src.GetPackfiles()
+	require.NoError(t, err)
+	require.NotEmpty(t, packfiles)
+
+	// an interrupted run left a single packfile behind
+	_, err = copyBlob(packfiles[0], src.GetPackfile, dest.PutPackfile)
+	require.NoError(t, err)
+
+	existing, err := dest.GetPackfiles()
+	require.NoError(t, err)
+	require.Len(t, missingBlobs(packfiles, existing), len(packfiles)-1)
+
+	copied, err := migrateBlobs(ctx, packfiles, existing, src.GetPackfile, dest.PutPackfile, dest.GetPackfile)
+	require.NoError(t, err)
+	require.Equal(t, len(packfiles)-1, copied)
+
+	// a rerun has nothing left to copy and reads nothing
+	existing, err = dest.GetPackfiles()
+	require.NoError(t, err)
+	require.ElementsMatch(t, packfiles, existing)
+
+	read := 0
+	counting := func(get blobGetter) blobGetter {
+		return func(mac objects.MAC) (io.Reader, error) {
+			read++
+			return get(mac)
+		}
+	}
+	copied, err = migrateBlobs(ctx, packfiles, existing, counting(src.GetPackfile), dest.PutPackfile, counting(dest.GetPackfile))
+	require.NoError(t, err)
+	require.Equal(t, 0, copied)
+	require.Equal(t, 0, read)
+
+	// a copy that does not read back as the original is reported
+	corrupt := func(mac objects.MAC, rd io.Reader) (int64, error) {
+		return dest.PutPackfile(mac, bytes.NewReader([]byte("corrupted")))
+	}
+	_, err = migrateBlobs(ctx, packfiles, nil, src.GetPackfile, corrupt, dest.GetPackfile)
+	require.ErrorContains(t, err, "copy does not match the original")
+}
+
+func TestSwitchStores(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	_, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+
+	cfg, err := utils.LoadConfig(t.TempDir())
+	require.NoError(t, err)
+	ctx.Config = cfg
+	ctx.ConfigDir = t.TempDir()
+	ctx.Config.Repositories = map[string]config.RepositoryConfig{
+		"backups": {"location": "/var/backups", "passphrase_cmd": "pass plakar"},
+		"other":   {"location": "/var/other"},
+		"bucket":  {"location": "s3://s3.example.org/backups", "access_key": "key", "secret_access_key": "secret"},
+	}
+
+	require.NoError(t, switchStores(ctx, "/var/backups", "s3://s3.example.org/backups"))
+
+	// only the location of the matching store changed, and was saved
+	saved, err := utils.LoadConfig(ctx.ConfigDir)
+	require.NoError(t, err)
+	for _, cfg := range []*config.Config{ctx.Config, saved} {
+		require.Equal(t, config.RepositoryConfig{"location": "s3://s3.example.org/backups", "passphrase_cmd": "pass plakar"}, cfg.Repositories["backups"])
+		require.Equal(t, config.RepositoryConfig{"location": "/var/other"}, cfg.Repositories["other"])
+	}
+}
+
+func TestMigrateStore(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/dummy.txt", 0644, "hello dummy"),
+	})
+	snapshotID := snap.Header.Identifier
+	snap.Close()
+	waitForLocks(t, repo)
+
+	destConfig := map[string]string{"location": filepath.Join(t.TempDir(), "repo")}
+	dest, err := openDestination(ctx, repo, destConfig)
+	require.NoError(t, err)
+	require.NoError(t, migrateStore(ctx, repo.Store(), dest))
+	dest.Close()
+	require.Contains(t, bufOut.String(), "packfiles copied, 0 already present")
+
+	// a second run reopens the destination and copies nothing
+	bufOut.Reset()
+	dest, err = openDestination(ctx, repo, destConfig)
+	require.NoError(t, err)
+	defer dest.Close()
+	require.NoError(t, migrateStore(ctx, repo.Store(), dest))
+	require.Contains(t, bufOut.String(), "migrate-to-s3: 0 packfiles copied")
+	require.Contains(t, bufOut.String(), "migrate-to-s3: 0 states copied")
+
+	// the copy is a usable store
+	destStore, serializedConfig, err := storage.Open(ctx.GetInner(), destConfig)
+	require.NoError(t, err)
+	destRepo, err := repository.New(ctx.GetInner(), ctx.GetSecret(), destStore, serializedConfig)
+	require.NoError(t, err)
+	defer destRepo.Close()
+
+	snapshots, err := destRepo.GetSnapshots()
+	require.NoError(t, err)
+	require.Equal(t, []objects.MAC{snapshotID}, snapshots)
+}
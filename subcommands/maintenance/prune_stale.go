@@ -0,0 +1,174 @@
+package maintenance
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/snapshot"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/subcommands"
+	"github.com/PlakarKorp/plakar/utils"
+)
+
+func init() {
+	subcommands.Register(func() subcommands.Subcommand { return &PruneStale{} }, subcommands.AgentSupport, "maintenance", "prune-stale")
+}
+
+func (cmd *PruneStale) Parse(ctx *appcontext.AppContext, args []string) error {
+	cmd.LocateOptions = utils.NewDefaultLocateOptions()
+
+	flags := flag.NewFlagSet("maintenance prune-stale", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [OPTIONS]\n", flags.Name())
+		fmt.Fprintf(flags.Output(), "\nOPTIONS:\n")
+		flags.PrintDefaults()
+	}
+
+	cmd.LocateOptions.InstallFlags(flags)
+	flags.DurationVar(&cmd.GracePeriod, "grace-period", 30*24*time.Hour, "how long a source must have been absent before its snapshots are removed")
+	flags.BoolVar(&cmd.Confirm, "confirm", false, "actually remove the snapshots instead of only reporting them")
+	flags.Func("exclude-tag", "never remove snapshots with this tag, can be repeated", func(tag string) error {
+		cmd.ExcludeTags = append(cmd.ExcludeTags, tag)
+		return nil
+	})
+	flags.Parse(args)
+
+	if flags.NArg() != 0 {
+		return fmt.Errorf("too many arguments")
+	}
+
+	if cmd.LocateOptions.ImporterType == "" {
+		cmd.LocateOptions.ImporterType = "fs"
+	} else if !strings.EqualFold(cmd.LocateOptions.ImporterType, "fs") {
+		return fmt.Errorf("only fs sources can be checked for absence")
+	}
+
+	// a path is only meaningful on the host it was backed up from
+	if cmd.LocateOptions.Origin == "" {
+		cmd.LocateOptions.Origin = ctx.Hostname
+	} else if !strings.EqualFold(cmd.LocateOptions.Origin, ctx.Hostname) {
+		return fmt.Errorf("sources of %s can only be checked from that host", cmd.LocateOptions.Origin)
+	}
+
+	cmd.LocateOptions.MaxConcurrency = ctx.MaxConcurrency
+	cmd.RepositorySecret = ctx.GetSecret()
+
+	return nil
+}
+
+type PruneStale struct {
+	subcommands.SubcommandBase
+
+	LocateOptions *utils.LocateOptions
+	GracePeriod   time.Duration
+	Confirm       bool
+	ExcludeTags   []string
+}
+
+type staleSource struct {
+	latest    time.Time
+	snapshots []objects.MAC
+}
+
+func (cmd *PruneStale) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
+	snapshotIDs, err := utils.LocateSnapshotIDs(repo, cmd.LocateOptions)
+	if err != nil {
+		return 1, err
+	}
+
+	sources := make(map[string]*staleSource)
+	for _, snapshotID := range snapshotIDs {
+		snap, err := snapshot.Load(repo, snapshotID)
+		if err != nil {
+			return 1, err
+		}
+
+		root := snap.Header.GetSource(0).Importer.Directory
+		if tag, ok := excludedTag(snap, cmd.ExcludeTags); ok {
+			ctx.GetLogger().Info("prune-stale: keeping snapshot %x of %s: tagged %s", snapshotID[:4], root, tag)
+			snap.Close()
+			continue
+		}
+
+		source, ok := sources[root]
+		if !ok {
+			source = &staleSource{}
+			sources[root] = source
+		}
+		source.snapshots = append(source.snapshots, snapshotID)
+		if snap.Header.Timestamp.After(source.latest) {
+			source.latest = snap.Header.Timestamp
+		}
+		snap.Close()
+	}
+
+	roots := make([]string, 0, len(sources))
+	for root := range sources {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+
+	cutoff := time.Now().Add(-cmd.GracePeriod)
+	removed := 0
+	errors := 0
+	for _, root := range roots {
+		source := sources[root]
+
+		_, err := os.Stat(root)
+		if err == nil {
+			ctx.GetLogger().Info("prune-stale: keeping %d snapshots of %s: source exists", len(source.snapshots), root)
+			continue
+		} else if !os.IsNotExist(err) {
+			ctx.GetLogger().Warn("prune-stale: keeping %d snapshots of %s: %s", len(source.snapshots), root, err)
+			continue
+		}
+
+		if source.latest.After(cutoff) {
+			ctx.GetLogger().Info("prune-stale: keeping %d snapshots of %s: source absent, but last backed up on %s",
+				len(source.snapshots), root, source.latest.Format(time.RFC3339))
+			continue
+		}
+
+		if !cmd.Confirm {
+			ctx.GetLogger().Info("prune-stale: would remove %d snapshots of %s: source absent since at least %s",
+				len(source.snapshots), root, source.latest.Format(time.RFC3339))
+			continue
+		}
+
+		for _, snapshotID := range source.snapshots {
+			if err := repo.DeleteSnapshot(snapshotID); err != nil {
+				ctx.GetLogger().Error("prune-stale: failed to remove snapshot %x: %s", snapshotID[:4], err)
+				errors++
+				continue
+			}
+			removed++
+			ctx.GetLogger().Info("prune-stale: removed snapshot %x of %s: source absent since at least %s",
+				snapshotID[:4], root, source.latest.Format(time.RFC3339))
+		}
+	}
+
+	if removed != 0 {
+		invalidateStats(ctx, repo)
+	}
+
+	if errors != 0 {
+		return 1, fmt.Errorf("failed to remove %d snapshots", errors)
+	}
+
+	return 0, nil
+}
+
+func excludedTag(snap *snapshot.Snapshot, tags []string) (string, bool) {
+	for _, tag := range tags {
+		if snap.Header.HasTag(tag) {
+			return tag, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,83 @@
+package maintenance
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/snapshot"
+	"github.com/PlakarKorp/plakar/appcontext"
+	_ "github.com/PlakarKorp/plakar/connectors/fs/importer"
+	"github.com/PlakarKorp/plakar/subcommands/backup"
+	ptesting "github.com/PlakarKorp/plakar/testing"
+	"github.com/stretchr/testify/require"
+)
+
+func backupDir(t *testing.T, ctx *appcontext.AppContext, repo *repository.Repository, dir string, args ...string) {
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dummy.txt"), []byte("hello "+dir), 0644))
+
+	subcommand := &backup.Backup{}
+	require.NoError(t, subcommand.Parse(ctx, append(args, "-silent", dir)))
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+	waitForLocks(t, repo)
+}
+
+func TestExecuteCmdMaintenancePruneStale(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+
+	root := t.TempDir()
+	live := filepath.Join(root, "live")
+	stale := filepath.Join(root, "stale")
+	pinned := filepath.Join(root, "pinned")
+
+	backupDir(t, ctx, repo, live)
+	backupDir(t, ctx, repo, stale)
+	backupDir(t, ctx, repo, stale)
+	backupDir(t, ctx, repo, pinned, "-tag", "keep")
+	require.NoError(t, os.RemoveAll(stale))
+	require.NoError(t, os.RemoveAll(pinned))
+
+	sources := func() map[string]int {
+		require.NoError(t, repo.RebuildState())
+		ret := make(map[string]int)
+		for snapshotID := range repo.ListSnapshots() {
+			snap, err := snapshot.Load(repo, snapshotID)
+			require.NoError(t, err)
+			ret[filepath.Base(snap.Header.GetSource(0).Importer.Directory)]++
+			snap.Close()
+		}
+		return ret
+	}
+	require.Equal(t, map[string]int{"live": 1, "stale": 2, "pinned": 1}, sources())
+
+	prune := func(args ...string) {
+		subcommand := &PruneStale{}
+		require.NoError(t, subcommand.Parse(ctx, args))
+		status, err := subcommand.Execute(ctx, repo)
+		require.NoError(t, err)
+		require.Equal(t, 0, status)
+	}
+
+	// absent sources are kept for the grace period
+	prune("-confirm", "-exclude-tag", "keep")
+	require.Equal(t, map[string]int{"live": 1, "stale": 2, "pinned": 1}, sources())
+
+	// and only reported without -confirm
+	prune("-grace-period", "0", "-exclude-tag", "keep")
+	require.Equal(t, map[string]int{"live": 1, "stale": 2, "pinned": 1}, sources())
+	require.True(t, ctx.GetCookies().RepositoryStatsInvalidatedAt(repo.Configuration().RepositoryID).IsZero())
+
+	prune("-grace-period", "0", "-confirm", "-exclude-tag", "keep")
+	require.Equal(t, map[string]int{"live": 1, "pinned": 1}, sources())
+	require.False(t, ctx.GetCookies().RepositoryStatsInvalidatedAt(repo.Configuration().RepositoryID).IsZero())
+
+	prune("-grace-period", "0", "-confirm")
+	require.Equal(t, map[string]int{"live": 1}, sources())
+}
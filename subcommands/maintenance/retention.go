@@ -0,0 +1,209 @@
+package maintenance
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/snapshot"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/subcommands"
+)
+
+func init() {
+	subcommands.Register(func() subcommands.Subcommand { return &Retention{} }, subcommands.AgentSupport, "maintenance", "retention")
+}
+
+// RetentionPolicy describes which snapshots are kept: those younger than
+// MaxAge, at most MaxCount of them, and any snapshot carrying one of Tags.
+// A zero MaxAge or MaxCount does not limit anything.
+type RetentionPolicy struct {
+	MaxAge   time.Duration
+	MaxCount int
+	Tags     []string
+}
+
+// retentionPolicyFromConfig reads the policy from the retention_max_age,
+// retention_max_count and retention_keep_tags keys of the store
+// configuration whose location matches the repository.
+func retentionPolicyFromConfig(ctx *appcontext.AppContext, repo *repository.Repository) (RetentionPolicy, error) {
+	var policy RetentionPolicy
+	if ctx.Config == nil {
+		return policy, nil
+	}
+
+	for name, storeConfig := range ctx.Config.Repositories {
+		if storeConfig["location"] != repo.Location() {
+			continue
+		}
+
+		if value, ok := storeConfig["retention_max_age"]; ok {
+			maxAge, err := time.ParseDuration(value)
+			if err != nil || maxAge < 0 {
+				return policy, fmt.Errorf("store %s: invalid retention_max_age value %q", name, value)
+			}
+			policy.MaxAge = maxAge
+		}
+		if value, ok := storeConfig["retention_max_count"]; ok {
+			maxCount, err := strconv.Atoi(value)
+			if err != nil || maxCount < 0 {
+				return policy, fmt.Errorf("store %s: invalid retention_max_count value %q", name, value)
+			}
+			policy.MaxCount = maxCount
+		}
+		if value, ok := storeConfig["retention_keep_tags"]; ok {
+			for _, tag := range strings.Split(value, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					policy.Tags = append(policy.Tags, tag)
+				}
+			}
+		}
+		break
+	}
+
+	return policy, nil
+}
+
+type retentionCandidate struct {
+	id        objects.MAC
+	timestamp time.Time
+	tags      []string
+}
+
+// expired returns the candidates falling outside of the policy, the most
+// recent ones being kept first when MaxCount applies.
+func (policy RetentionPolicy) expired(candidates []retentionCandidate, now time.Time) []retentionCandidate {
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].timestamp.After(candidates[j].timestamp)
+	})
+
+	var ret []retentionCandidate
+	kept := 0
+	for _, candidate := range candidates {
+		if policy.exempt(candidate.tags) {
+			continue
+		}
+		if policy.MaxAge != 0 && now.Sub(candidate.timestamp) > policy.MaxAge {
+			ret = append(ret, candidate)
+			continue
+		}
+		if policy.MaxCount != 0 && kept >= policy.MaxCount {
+			ret = append(ret, candidate)
+			continue
+		}
+		kept++
+	}
+	return ret
+}
+
+func (policy RetentionPolicy) exempt(tags []string) bool {
+	for _, tag := range tags {
+		for _, keep := range policy.Tags {
+			if tag == keep {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (cmd *Retention) Parse(ctx *appcontext.AppContext, args []string) error {
+	flags := flag.NewFlagSet("maintenance retention", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [OPTIONS]\n", flags.Name())
+		fmt.Fprintf(flags.Output(), "\nOPTIONS:\n")
+		flags.PrintDefaults()
+	}
+
+	flags.DurationVar(&cmd.Policy.MaxAge, "max-age", 0, "remove snapshots older than this duration")
+	flags.IntVar(&cmd.Policy.MaxCount, "max-count", 0, "keep at most this many snapshots")
+	flags.Func("keep-tag", "never remove snapshots with this tag, can be repeated", func(tag string) error {
+		cmd.Policy.Tags = append(cmd.Policy.Tags, tag)
+		return nil
+	})
+	flags.BoolVar(&cmd.DryRun, "dry-run", false, "only report the snapshots that would be removed")
+	flags.Parse(args)
+
+	if flags.NArg() != 0 {
+		return fmt.Errorf("too many arguments")
+	}
+	if cmd.Policy.MaxAge < 0 {
+		return fmt.Errorf("invalid -max-age value")
+	}
+	if cmd.Policy.MaxCount < 0 {
+		return fmt.Errorf("invalid -max-count value")
+	}
+
+	cmd.RepositorySecret = ctx.GetSecret()
+
+	return nil
+}
+
+type Retention struct {
+	subcommands.SubcommandBase
+
+	Policy RetentionPolicy
+	DryRun bool
+}
+
+func (cmd *Retention) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
+	policy, err := retentionPolicyFromConfig(ctx, repo)
+	if err != nil {
+		return 1, err
+	}
+
+	// options given on the command line take precedence
+	if cmd.Policy.MaxAge != 0 {
+		policy.MaxAge = cmd.Policy.MaxAge
+	}
+	if cmd.Policy.MaxCount != 0 {
+		policy.MaxCount = cmd.Policy.MaxCount
+	}
+	policy.Tags = append(policy.Tags, cmd.Policy.Tags...)
+
+	if policy.MaxAge == 0 && policy.MaxCount == 0 {
+		return 1, fmt.Errorf("no retention policy configured, set -max-age or -max-count")
+	}
+
+	var candidates []retentionCandidate
+	for snapshotID := range repo.ListSnapshots() {
+		snap, err := snapshot.Load(repo, snapshotID)
+		if err != nil {
+			return 1, err
+		}
+		candidates = append(candidates, retentionCandidate{
+			id:        snapshotID,
+			timestamp: snap.Header.Timestamp,
+			tags:      snap.Header.Tags,
+		})
+		snap.Close()
+	}
+
+	errors := 0
+	for _, candidate := range policy.expired(candidates, time.Now()) {
+		if cmd.DryRun {
+			ctx.GetLogger().Info("retention: would remove snapshot %x from %s",
+				candidate.id[:4], candidate.timestamp.Format(time.RFC3339))
+			continue
+		}
+
+		if err := repo.DeleteSnapshot(candidate.id); err != nil {
+			ctx.GetLogger().Error("retention: failed to remove snapshot %x: %s", candidate.id[:4], err)
+			errors++
+			continue
+		}
+		ctx.GetLogger().Info("retention: removed snapshot %x from %s",
+			candidate.id[:4], candidate.timestamp.Format(time.RFC3339))
+	}
+
+	if errors != 0 {
+		return 1, fmt.Errorf("failed to remove %d snapshots", errors)
+	}
+
+	return 0, nil
+}
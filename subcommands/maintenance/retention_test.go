@@ -0,0 +1,82 @@
+package maintenance
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/PlakarKorp/kloset/objects"
+	ptesting "github.com/PlakarKorp/plakar/testing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetentionPolicyExpired(t *testing.T) {
+	now := time.Now()
+	candidates := []retentionCandidate{
+		{id: objects.MAC{1}, timestamp: now.Add(-1 * time.Hour)},
+		{id: objects.MAC{2}, timestamp: now.Add(-48 * time.Hour), tags: []string{"pinned"}},
+		{id: objects.MAC{3}, timestamp: now.Add(-2 * time.Hour)},
+		{id: objects.MAC{4}, timestamp: now.Add(-72 * time.Hour)},
+		{id: objects.MAC{5}, timestamp: now.Add(-3 * time.Hour)},
+	}
+
+	policy := RetentionPolicy{MaxAge: 24 * time.Hour, MaxCount: 2, Tags: []string{"pinned"}}
+	expired := policy.expired(candidates, now)
+
+	ids := []objects.MAC{}
+	for _, candidate := range expired {
+		ids = append(ids, candidate.id)
+	}
+	require.Equal(t, []objects.MAC{{5}, {4}}, ids)
+}
+
+func TestExecuteCmdMaintenanceRetention(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	for range 10 {
+		snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+			ptesting.NewMockDir("subdir"),
+			ptesting.NewMockFile("subdir/dummy.txt", 0644, "hello dummy"),
+		})
+		snap.Close()
+	}
+
+	count := func() int {
+		require.NoError(t, repo.RebuildState())
+		n := 0
+		for range repo.ListSnapshots() {
+			n++
+		}
+		return n
+	}
+	require.Equal(t, 10, count())
+
+	subcommand := &Retention{}
+	require.NoError(t, subcommand.Parse(ctx, []string{"-max-count", "3", "-dry-run"}))
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+	require.Equal(t, 10, count())
+	require.Equal(t, 7, bytes.Count(bufOut.Bytes(), []byte("retention: would remove snapshot")))
+
+	subcommand = &Retention{}
+	require.NoError(t, subcommand.Parse(ctx, []string{"-max-count", "3"}))
+	status, err = subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+	require.Equal(t, 3, count())
+}
+
+func TestExecuteCmdMaintenanceRetentionNoPolicy(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+
+	subcommand := &Retention{}
+	require.NoError(t, subcommand.Parse(ctx, []string{}))
+	_, err := subcommand.Execute(ctx, repo)
+	require.Error(t, err)
+}
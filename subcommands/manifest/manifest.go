@@ -0,0 +1,110 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/snapshot/vfs"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/subcommands"
+	"github.com/PlakarKorp/plakar/utils"
+)
+
+func init() {
+	subcommands.Register(func() subcommands.Subcommand { return &Manifest{} }, subcommands.AgentSupport, "manifest")
+}
+
+type Manifest struct {
+	subcommands.SubcommandBase
+
+	Export   bool
+	Snapshot string
+}
+
+func (cmd *Manifest) Parse(ctx *appcontext.AppContext, args []string) error {
+	flags := flag.NewFlagSet("manifest", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [OPTIONS] SNAPSHOT[:PATH]\n", flags.Name())
+		fmt.Fprintf(flags.Output(), "\nOPTIONS:\n")
+		flags.PrintDefaults()
+	}
+
+	flags.BoolVar(&cmd.Export, "export", false, "produce a self-contained manifest with a header and a digest")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("a snapshot must be specified")
+	}
+
+	cmd.RepositorySecret = ctx.GetSecret()
+	cmd.Snapshot = flags.Arg(0)
+
+	return nil
+}
+
+func (cmd *Manifest) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
+	snap, pathname, err := utils.OpenSnapshotByPath(repo, cmd.Snapshot)
+	if err != nil {
+		return 1, err
+	}
+	defer snap.Close()
+
+	fs, err := snap.Filesystem()
+	if err != nil {
+		return 1, err
+	}
+
+	if !cmd.Export {
+		if err := writeEntries(ctx, ctx.Stdout, fs, pathname); err != nil {
+			return 1, err
+		}
+		return 0, nil
+	}
+
+	// the digest covers everything up to the trailer, so that the
+	// manifest can be checked with nothing but a SHA256 tool.
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# snapshot: %x\n", snap.Header.Identifier)
+	fmt.Fprintf(&buf, "# created: %s\n", snap.Header.Timestamp.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&buf, "# path: %s\n", utils.SanitizeText(pathname))
+	fmt.Fprintf(&buf, "# fields: content-mac size mtime path\n")
+	if err := writeEntries(ctx, &buf, fs, pathname); err != nil {
+		return 1, err
+	}
+
+	digest := sha256.Sum256(buf.Bytes())
+	fmt.Fprintf(&buf, "# sha256: %x\n", digest)
+	if kp := ctx.Keypair; kp != nil {
+		fmt.Fprintf(&buf, "# public-key: %x\n", []byte(kp.PublicKey))
+		fmt.Fprintf(&buf, "# signature: %x\n", kp.Sign(digest[:]))
+	}
+
+	if _, err := ctx.Stdout.Write(buf.Bytes()); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+func writeEntries(ctx *appcontext.AppContext, w io.Writer, fs *vfs.Filesystem, pathname string) error {
+	return fs.WalkDir(pathname, func(path string, entry *vfs.Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !entry.Stat().Mode().IsRegular() || entry.ResolvedObject == nil {
+			return nil
+		}
+
+		_, err = fmt.Fprintf(w, "%x %d %s %s\n", entry.ResolvedObject.ContentMAC,
+			entry.Stat().Size(), entry.Stat().ModTime().UTC().Format(time.RFC3339),
+			utils.SanitizeText(path))
+		return err
+	})
+}
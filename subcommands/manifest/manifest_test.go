@@ -0,0 +1,87 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/snapshot"
+	"github.com/PlakarKorp/plakar/appcontext"
+	ptesting "github.com/PlakarKorp/plakar/testing"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	os.Setenv("TZ", "UTC")
+}
+
+func generateSnapshot(t *testing.T, bufOut *bytes.Buffer, bufErr *bytes.Buffer) (*repository.Repository, *snapshot.Snapshot, *appcontext.AppContext) {
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/dummy.txt", 0644, "hello dummy"),
+		ptesting.NewMockFile("subdir/foo.txt", 0644, "hello foo"),
+	})
+	return repo, snap, ctx
+}
+
+func TestExecuteCmdManifest(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, snap, ctx := generateSnapshot(t, bufOut, bufErr)
+	defer snap.Close()
+
+	indexId := snap.Header.GetIndexID()
+	args := []string{hex.EncodeToString(indexId[:])}
+
+	subcommand := &Manifest{}
+	err := subcommand.Parse(ctx, args)
+	require.NoError(t, err)
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	lines := strings.Split(strings.Trim(bufOut.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		require.Len(t, fields, 4)
+		require.Len(t, fields[0], 64)
+	}
+	require.True(t, strings.HasSuffix(lines[0], "/subdir/dummy.txt"))
+	require.Equal(t, "11", strings.Fields(lines[0])[1])
+}
+
+func TestExecuteCmdManifestExport(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, snap, ctx := generateSnapshot(t, bufOut, bufErr)
+	defer snap.Close()
+
+	indexId := snap.Header.GetIndexID()
+	args := []string{"-export", hex.EncodeToString(indexId[:])}
+
+	subcommand := &Manifest{}
+	err := subcommand.Parse(ctx, args)
+	require.NoError(t, err)
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	output := bufOut.String()
+	require.Contains(t, output, fmt.Sprintf("# snapshot: %x\n", snap.Header.Identifier))
+
+	idx := strings.Index(output, "# sha256: ")
+	require.NotEqual(t, -1, idx)
+	digest := sha256.Sum256([]byte(output[:idx]))
+	require.Equal(t, fmt.Sprintf("# sha256: %x\n", digest), output[idx:])
+}
@@ -0,0 +1,91 @@
+package merge
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/storage"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/subcommands"
+	"github.com/PlakarKorp/plakar/subcommands/clone"
+	"github.com/PlakarKorp/plakar/subcommands/sync"
+)
+
+func init() {
+	subcommands.Register(func() subcommands.Subcommand { return &Merge{} }, subcommands.AgentSupport, "merge")
+}
+
+// Merge is a clone of the current repository followed by a sync of the
+// peer into the clone: the clone copies packfiles and states verbatim,
+// and sync re-encodes the peer snapshots with the key of the new
+// repository, skipping the chunks it already holds.
+type Merge struct {
+	subcommands.SubcommandBase
+
+	Dest string
+
+	clone *clone.Clone
+	sync  *sync.Sync
+}
+
+func (cmd *Merge) Parse(ctx *appcontext.AppContext, args []string) error {
+	var verify bool
+
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [OPTIONS] REPOSITORY into NEW_REPOSITORY\n", flags.Name())
+		fmt.Fprintf(flags.Output(), "\nOPTIONS:\n")
+		flags.PrintDefaults()
+	}
+	flags.BoolVar(&verify, "verify", false, "check merged snapshots in the new repository")
+	flags.Parse(args)
+
+	if flags.NArg() != 3 || flags.Arg(1) != "into" {
+		return fmt.Errorf("usage: %s REPOSITORY into NEW_REPOSITORY", flags.Name())
+	}
+	peer, dest := flags.Arg(0), flags.Arg(2)
+
+	cmd.clone = &clone.Clone{}
+	if err := cmd.clone.Parse(ctx, []string{"to", dest}); err != nil {
+		return err
+	}
+
+	syncArgs := []string{"from", peer}
+	if verify {
+		syncArgs = append([]string{"-verify"}, syncArgs...)
+	}
+	cmd.sync = &sync.Sync{}
+	if err := cmd.sync.Parse(ctx, syncArgs); err != nil {
+		return err
+	}
+
+	cmd.RepositorySecret = ctx.GetSecret()
+	cmd.Dest = dest
+
+	return nil
+}
+
+func (cmd *Merge) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
+	if status, err := cmd.clone.Execute(ctx, repo); err != nil {
+		return status, fmt.Errorf("could not create %s: %w", cmd.Dest, err)
+	}
+
+	storeConfig, err := ctx.Config.GetRepository(cmd.Dest)
+	if err != nil {
+		return 1, err
+	}
+
+	store, serializedConfig, err := storage.Open(ctx.GetInner(), storeConfig)
+	if err != nil {
+		return 1, fmt.Errorf("could not open %s: %w", cmd.Dest, err)
+	}
+
+	dest, err := repository.New(ctx.GetInner(), ctx.GetSecret(), store, serializedConfig)
+	if err != nil {
+		return 1, fmt.Errorf("could not open %s: %w", cmd.Dest, err)
+	}
+	defer dest.Close()
+
+	return cmd.sync.Execute(ctx, dest)
+}
@@ -0,0 +1,58 @@
+package merge
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ptesting "github.com/PlakarKorp/plakar/testing"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	os.Setenv("TZ", "UTC")
+}
+
+func TestExecuteCmdMerge(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	localRepo, lctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	localSnap := ptesting.GenerateSnapshot(t, localRepo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/dummy.txt", 0644, "hello dummy"),
+	})
+	defer localSnap.Close()
+
+	peerRepo, _ := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	peerSnap := ptesting.GenerateSnapshot(t, peerRepo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/dummy.txt", 0644, "hello dummy"),
+		ptesting.NewMockFile("subdir/bar.txt", 0644, "hello bar"),
+	})
+	defer peerSnap.Close()
+
+	tmpDestinationDir, err := os.MkdirTemp("", "merge_destination")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(tmpDestinationDir)
+	})
+	outputDir := filepath.Join(tmpDestinationDir, "merge_test")
+
+	subcommand := &Merge{}
+	err = subcommand.Parse(lctx, []string{peerRepo.Location(), "into", outputDir})
+	require.NoError(t, err)
+
+	status, err := subcommand.Execute(lctx, localRepo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	_, err = os.Stat(outputDir)
+	require.NoError(t, err)
+
+	require.Contains(t, bufOut.String(), "completed: 1 snapshots synchronized")
+
+	err = subcommand.Parse(lctx, []string{peerRepo.Location(), "to", outputDir})
+	require.Error(t, err)
+}
@@ -17,25 +17,18 @@
 package ptar
 
 import (
-	"bytes"
 	"flag"
 	"fmt"
-	"hash"
-	"io"
-	"math"
 	"os"
 	"strings"
 
 	"github.com/PlakarKorp/kloset/compression"
 	"github.com/PlakarKorp/kloset/encryption"
 	"github.com/PlakarKorp/kloset/hashing"
-	"github.com/PlakarKorp/kloset/objects"
 	"github.com/PlakarKorp/kloset/repository"
-	"github.com/PlakarKorp/kloset/resources"
 	"github.com/PlakarKorp/kloset/snapshot"
 	"github.com/PlakarKorp/kloset/snapshot/importer"
 	"github.com/PlakarKorp/kloset/storage"
-	"github.com/PlakarKorp/kloset/versioning"
 	"github.com/PlakarKorp/plakar/appcontext"
 	"github.com/PlakarKorp/plakar/subcommands"
 	"github.com/PlakarKorp/plakar/utils"
@@ -221,7 +214,6 @@ func (cmd *Ptar) Execute(ctx *appcontext.AppContext, repo *repository.Repository
 	}
 	storageConfiguration.Hashing = *hashingConfiguration
 
-	var hasher hash.Hash
 	var key []byte
 	if !cmd.NoEncryption {
 		storageConfiguration.Encryption = encryption.NewDefaultConfiguration()
@@ -237,27 +229,8 @@ func (cmd *Ptar) Execute(ctx *appcontext.AppContext, repo *repository.Repository
 			return 1, err
 		}
 		storageConfiguration.Encryption.Canary = canary
-		hasher = hashing.GetMACHasher(storage.DEFAULT_HASHING_ALGORITHM, key)
-		//ctx.SetSecret(key)
 	} else {
 		storageConfiguration.Encryption = nil
-		hasher = hashing.GetHasher(storage.DEFAULT_HASHING_ALGORITHM)
-	}
-
-	storageConfiguration.Packfile.MaxSize = math.MaxUint64
-
-	serializedConfig, err := storageConfiguration.ToBytes()
-	if err != nil {
-		return 1, err
-	}
-
-	rd, err := storage.Serialize(hasher, resources.RT_CONFIG, versioning.GetCurrentVersion(resources.RT_CONFIG), bytes.NewReader(serializedConfig))
-	if err != nil {
-		return 1, err
-	}
-	wrappedConfig, err := io.ReadAll(rd)
-	if err != nil {
-		return 1, err
 	}
 
 	location := cmd.KlosetPath
@@ -276,25 +249,11 @@ func (cmd *Ptar) Execute(ctx *appcontext.AppContext, repo *repository.Repository
 		}
 	}
 
-	st, err := storage.Create(ctx.GetInner(), map[string]string{"location": location}, wrappedConfig)
+	repoWriter, err := utils.NewPtarWriter(ctx, location, storageConfiguration, key)
 	if err != nil {
 		return 1, err
 	}
 
-	repo, err = repository.New(ctx.GetInner(), key, st, wrappedConfig)
-	if err != nil {
-		return 1, err
-	}
-
-	identifier := objects.RandomMAC()
-	scanCache, err := repo.AppContext().GetCache().Scan(identifier)
-	if err != nil {
-		return 1, err
-	}
-
-	defer scanCache.Close()
-
-	repoWriter := repo.NewRepositoryWriter(scanCache, identifier, repository.PtarType)
 	for i, syncTarget := range cmd.SyncTargets {
 		storeConfig, err := ctx.Config.GetRepository(syncTarget)
 		if err != nil {
@@ -312,22 +271,16 @@ func (cmd *Ptar) Execute(ctx *appcontext.AppContext, repo *repository.Repository
 			return 1, fmt.Errorf("could not open source repository %s: %s", syncTarget, err)
 		}
 
-		if err := cmd.synchronize(ctx, srcRepository, repoWriter); err != nil {
+		if err := cmd.synchronize(ctx, srcRepository, repoWriter.RepositoryWriter); err != nil {
 			return 1, err
 		}
 	}
-	if err := cmd.backup(ctx, repoWriter); err != nil {
+	if err := cmd.backup(ctx, repoWriter.RepositoryWriter); err != nil {
 		return 1, err
 	}
 
 	// We are done with everything we can now stop the backup routines.
-	repoWriter.PackerManager.Wait()
-	err = repoWriter.CommitTransaction(identifier)
-	if err != nil {
-		return 1, err
-	}
-
-	if err := st.Close(); err != nil {
+	if err := repoWriter.Commit(); err != nil {
 		return 1, err
 	}
 
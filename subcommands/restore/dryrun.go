@@ -0,0 +1,126 @@
+package restore
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/resources"
+	"github.com/PlakarKorp/kloset/snapshot"
+	"github.com/PlakarKorp/kloset/snapshot/vfs"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/utils"
+	"github.com/dustin/go-humanize"
+)
+
+// dryRun lists what would be restored below pathname and checks that
+// every chunk needed to restore it is known to the repository state and
+// stored in a packfile that exists, without fetching any data.
+func (cmd *Restore) dryRun(ctx *appcontext.AppContext, repo *repository.Repository, snap *snapshot.Snapshot, pathname string) (int, error) {
+	packfiles, err := repo.GetPackfiles()
+	if err != nil {
+		return 1, err
+	}
+	stored := make(map[objects.MAC]struct{}, len(packfiles))
+	for _, packfile := range packfiles {
+		stored[packfile] = struct{}{}
+	}
+
+	fs, err := snap.Filesystem()
+	if err != nil {
+		return 1, err
+	}
+
+	restorable, damaged := 0, 0
+	missingPackfiles := make(map[objects.MAC]struct{})
+	err = fs.WalkDir(pathname, func(entrypath string, entry *vfs.Entry, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		printEntry(ctx, entrypath, entry)
+
+		if !entry.Stat().Mode().IsRegular() || entry.ResolvedObject == nil {
+			return nil
+		}
+
+		missing := 0
+		for _, chunk := range entry.ResolvedObject.Chunks {
+			packfile, exists, err := repo.GetPackfileForBlob(resources.RT_CHUNK, chunk.ContentMAC)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				missing++
+				continue
+			}
+			if _, ok := stored[packfile]; !ok {
+				missingPackfiles[packfile] = struct{}{}
+				missing++
+			}
+		}
+
+		if missing != 0 {
+			fmt.Fprintf(ctx.Stdout, "%s: %d of %d chunks missing\n", entrypath, missing, len(entry.ResolvedObject.Chunks))
+			damaged++
+		} else {
+			restorable++
+		}
+		return nil
+	})
+	if err != nil {
+		return 1, err
+	}
+
+	sorted := make([]objects.MAC, 0, len(missingPackfiles))
+	for packfile := range missingPackfiles {
+		sorted = append(sorted, packfile)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return string(sorted[i][:]) < string(sorted[j][:])
+	})
+	for _, packfile := range sorted {
+		fmt.Fprintf(ctx.Stdout, "missing packfile %x\n", packfile)
+	}
+
+	ctx.GetLogger().Info("restore: dry-run of %x:%s: %d files restorable, %d files with missing chunks, %d packfiles missing",
+		snap.Header.GetIndexShortID(), pathname, restorable, damaged, len(sorted))
+
+	if damaged != 0 {
+		return 1, fmt.Errorf("%d files can not be fully restored", damaged)
+	}
+	return 0, nil
+}
+
+// printEntry outputs an entry in the same format as plakar ls -l.
+func printEntry(ctx *appcontext.AppContext, entrypath string, entry *vfs.Entry) {
+	sb := entry.Stat()
+
+	username := sb.Username()
+	if username == "" {
+		username = fmt.Sprintf("%d", sb.Uid())
+	}
+	groupname := sb.Groupname()
+	if groupname == "" {
+		groupname = fmt.Sprintf("%d", sb.Gid())
+	}
+
+	var linkTarget string
+	if sb.Mode()&os.ModeSymlink != 0 {
+		linkTarget = fmt.Sprintf(" -> %s", utils.SanitizeText(entry.SymlinkTarget))
+	}
+
+	fmt.Fprintf(ctx.Stdout, "%s %s % 8s % 8s % 8s %s%s\n",
+		sb.ModTime().UTC().Format(time.RFC3339),
+		sb.Mode(),
+		username,
+		groupname,
+		humanize.Bytes(uint64(sb.Size())),
+		utils.SanitizeText(entrypath),
+		linkTarget)
+}
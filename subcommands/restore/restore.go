@@ -19,6 +19,8 @@ package restore
 import (
 	"flag"
 	"fmt"
+	"maps"
+	"os"
 	"strings"
 	"time"
 
@@ -26,6 +28,8 @@ import (
 	"github.com/PlakarKorp/kloset/snapshot"
 	"github.com/PlakarKorp/kloset/snapshot/exporter"
 	"github.com/PlakarKorp/plakar/appcontext"
+	fsexporter "github.com/PlakarKorp/plakar/connectors/fs/exporter"
+	"github.com/PlakarKorp/plakar/progress"
 	"github.com/PlakarKorp/plakar/subcommands"
 	"github.com/PlakarKorp/plakar/utils"
 )
@@ -53,8 +57,13 @@ func (cmd *Restore) Parse(ctx *appcontext.AppContext, args []string) error {
 	flags.StringVar(&cmd.OptTag, "tag", "", "filter by tag")
 
 	flags.StringVar(&pullPath, "to", "", "base directory where pull will restore")
+	flags.StringVar(&cmd.Ownership, "ownership", fsexporter.OwnershipByUID,
+		fmt.Sprintf("how to restore file ownership: %s, %s or %s",
+			fsexporter.OwnershipByUID, fsexporter.OwnershipByName, fsexporter.OwnershipCurrentUser))
 	flags.BoolVar(&cmd.Quiet, "quiet", false, "do not print progress")
+	flags.BoolVar(&cmd.Progress, "progress", false, "display a progress bar on the terminal")
 	flags.BoolVar(&cmd.Silent, "silent", false, "do not print ANY progress")
+	flags.BoolVar(&cmd.DryRun, "dry-run", false, "only check that all the data needed for the restore is present")
 	flags.Parse(args)
 
 	if flags.NArg() != 0 {
@@ -65,8 +74,19 @@ func (cmd *Restore) Parse(ctx *appcontext.AppContext, args []string) error {
 		return fmt.Errorf("multiple restore paths specified, please specify only one")
 	}
 
+	if err := fsexporter.ValidateOwnership(cmd.Ownership); err != nil {
+		return err
+	}
+
 	if pullPath == "" {
 		pullPath = fmt.Sprintf("%s/plakar-%s", ctx.CWD, time.Now().Format(time.RFC3339))
+	} else if pullPath == "-" {
+		pullPath = "stdio://"
+	}
+
+	// the tar stream owns the standard output
+	if pullPath == "stdio://" {
+		cmd.Silent = true
 	}
 
 	cmd.RepositorySecret = ctx.GetSecret()
@@ -89,14 +109,22 @@ type Restore struct {
 	Target      string
 	Strip       string
 	Concurrency uint64
+	Ownership   string
 	Quiet       bool
+	Progress    bool
 	Silent      bool
+	DryRun      bool
 	Snapshots   []string
 }
 
 func (cmd *Restore) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
+	var tracker *progress.Tracker
 	if !cmd.Silent {
-		go eventsProcessorStdio(ctx, cmd.Quiet)
+		if cmd.Progress {
+			tracker = progress.NewTracker(os.Stderr)
+			tracker.Start(ctx)
+		}
+		go eventsProcessorStdio(ctx, cmd.Quiet || cmd.Progress)
 	}
 	var snapshots []string
 	if len(cmd.Snapshots) == 0 {
@@ -152,6 +180,15 @@ func (cmd *Restore) Execute(ctx *appcontext.AppContext, repo *repository.Reposit
 		return 1, fmt.Errorf("multiple snapshots found, please specify one")
 	}
 
+	if cmd.DryRun {
+		snap, pathname, err := utils.OpenSnapshotByPath(repo, snapshots[0])
+		if err != nil {
+			return 1, err
+		}
+		defer snap.Close()
+		return cmd.dryRun(ctx, repo, snap, pathname)
+	}
+
 	exporterConfig := map[string]string{
 		"location": cmd.Target,
 	}
@@ -163,9 +200,12 @@ func (cmd *Restore) Execute(ctx *appcontext.AppContext, repo *repository.Reposit
 		if _, ok := remote["location"]; !ok {
 			return 1, fmt.Errorf("could not resolve exporter location: %s", cmd.Target)
 		} else {
-			exporterConfig = remote
+			exporterConfig = maps.Clone(remote)
 		}
 	}
+	if cmd.Ownership != "" && cmd.Ownership != fsexporter.OwnershipByUID {
+		exporterConfig["ownership"] = cmd.Ownership
+	}
 
 	var exporterInstance exporter.Exporter
 	var err error
@@ -173,7 +213,18 @@ func (cmd *Restore) Execute(ctx *appcontext.AppContext, repo *repository.Reposit
 	if err != nil {
 		return 1, err
 	}
-	defer exporterInstance.Close()
+	// closing may flush what the exporter buffered, such as the trailer
+	// of a tar archive, so its error matters once the restore succeeded.
+	closed := false
+	defer func() {
+		if !closed {
+			exporterInstance.Close()
+		}
+	}()
+
+	if _, ok := exporterInstance.(*fsexporter.FSExporter); !ok && cmd.Ownership != fsexporter.OwnershipByUID {
+		return 1, fmt.Errorf("-ownership is only supported when restoring to the filesystem")
+	}
 
 	opts := &snapshot.RestoreOptions{
 		MaxConcurrency: cmd.Concurrency,
@@ -186,16 +237,50 @@ func (cmd *Restore) Execute(ctx *appcontext.AppContext, repo *repository.Reposit
 		}
 		opts.Strip = snap.Header.GetSource(0).Importer.Directory
 
+		if tracker != nil {
+			tracker.SetTotal(restoreSize(snap, pathname))
+		}
+
 		err = snap.Restore(exporterInstance, exporterInstance.Root(), pathname, opts)
+		if tracker != nil {
+			tracker.Close()
+		}
 
 		if err != nil {
 			return 1, err
 		}
-		ctx.GetLogger().Info("restore: restoration of %x:%s at %s completed successfully",
-			snap.Header.GetIndexShortID(),
-			pathname,
-			cmd.Target)
+		if cmd.Target != "stdio://" {
+			ctx.GetLogger().Info("restore: restoration of %x:%s at %s completed successfully",
+				snap.Header.GetIndexShortID(),
+				pathname,
+				cmd.Target)
+		}
 		snap.Close()
 	}
+
+	closed = true
+	if err := exporterInstance.Close(); err != nil {
+		return 1, err
+	}
 	return 0, nil
 }
+
+// restoreSize returns the number of bytes below pathname in the snapshot,
+// or zero if it can't be determined.
+func restoreSize(snap *snapshot.Snapshot, pathname string) uint64 {
+	fs, err := snap.Filesystem()
+	if err != nil {
+		return 0
+	}
+	entry, err := fs.GetEntry(pathname)
+	if err != nil {
+		return 0
+	}
+	if !entry.IsDir() {
+		return uint64(entry.Size())
+	}
+	if entry.Summary == nil {
+		return 0
+	}
+	return entry.Summary.Directory.Size + entry.Summary.Below.Size
+}
@@ -1,16 +1,21 @@
 package restore
 
 import (
+	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/PlakarKorp/kloset/config"
 	"github.com/PlakarKorp/kloset/repository"
 	"github.com/PlakarKorp/kloset/snapshot"
 	"github.com/PlakarKorp/plakar/appcontext"
 	_ "github.com/PlakarKorp/plakar/connectors/fs/exporter"
+	_ "github.com/PlakarKorp/plakar/connectors/stdio/exporter"
 	ptesting "github.com/PlakarKorp/plakar/testing"
 	"github.com/stretchr/testify/require"
 )
@@ -107,3 +112,108 @@ func TestExecuteCmdRestoreSpecificSnapshot(t *testing.T) {
 
 	checkRestored(t, tmpToRestoreDir)
 }
+
+func TestExecuteCmdRestoreDryRun(t *testing.T) {
+	repo, snap, ctx := generateSnapshot(t)
+	defer snap.Close()
+
+	tmpToRestoreDir, err := os.MkdirTemp("", "tmp_to_restore")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(tmpToRestoreDir)
+	})
+
+	indexId := snap.Header.GetIndexID()
+	args := []string{"-dry-run", "-to", tmpToRestoreDir, hex.EncodeToString(indexId[:])}
+	subcommand := &Restore{}
+	err = subcommand.Parse(ctx, args)
+	require.NoError(t, err)
+
+	bufOut := bytes.NewBuffer(nil)
+	ctx.Stdout = bufOut
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	rest, err := os.ReadDir(tmpToRestoreDir)
+	require.NoError(t, err)
+	require.Empty(t, rest)
+
+	// every entry is listed, e.g.
+	// 0001-01-01T00:00:00Z -rw-r--r--     flan   hacker     11 B /subdir/dummy.txt
+	var listed []string
+	for _, line := range strings.Split(strings.TrimSpace(bufOut.String()), "\n") {
+		fields := strings.Fields(line)
+		listed = append(listed, fields[len(fields)-1])
+	}
+	for _, pathname := range []string{"/subdir", "/subdir/dummy.txt", "/subdir/foo.txt", "/another_subdir/bar.txt"} {
+		require.Contains(t, listed, pathname)
+	}
+	require.Contains(t, bufOut.String(), "-rw-r--r--")
+}
+
+func TestExecuteCmdRestoreOwnership(t *testing.T) {
+	repo, snap, ctx := generateSnapshot(t)
+	defer snap.Close()
+
+	subcommand := &Restore{}
+	err := subcommand.Parse(ctx, []string{"-ownership", "bogus"})
+	require.ErrorContains(t, err, "invalid ownership mode")
+
+	// the options of a configured destination are not altered
+	tmpToRestoreDir := t.TempDir()
+	ctx.Config = config.NewConfig()
+	ctx.Config.Destinations["dest"] = config.DestinationConfig{"location": tmpToRestoreDir}
+
+	subcommand = &Restore{}
+	err = subcommand.Parse(ctx, []string{"-ownership", "current-user", "-to", "@dest"})
+	require.NoError(t, err)
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	checkRestored(t, tmpToRestoreDir)
+	require.Equal(t, config.DestinationConfig{"location": tmpToRestoreDir}, ctx.Config.Destinations["dest"])
+
+	// only the filesystem exporter knows about ownership
+	subcommand = &Restore{}
+	err = subcommand.Parse(ctx, []string{"-ownership", "by-name", "-to", "-"})
+	require.NoError(t, err)
+
+	status, err = subcommand.Execute(ctx, repo)
+	require.ErrorContains(t, err, "-ownership is only supported")
+	require.Equal(t, 1, status)
+}
+
+// trailerFailingWriter fails to write the end of a tar archive, two
+// blocks of zeros, which no entry of the test snapshot contains.
+type trailerFailingWriter struct {
+	bytes.Buffer
+}
+
+func (w *trailerFailingWriter) Write(p []byte) (int, error) {
+	if len(p) == 512 && bytes.Count(p, []byte{0}) == len(p) {
+		return 0, errors.New("disk full")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestExecuteCmdRestoreStdioCloseError(t *testing.T) {
+	repo, snap, ctx := generateSnapshot(t)
+	defer snap.Close()
+
+	// the tar trailer is written when the exporter is closed
+	archive := &trailerFailingWriter{}
+	ctx.Stdout = archive
+
+	subcommand := &Restore{}
+	err := subcommand.Parse(ctx, []string{"-to", "-", hex.EncodeToString(snap.Header.Identifier[:])})
+	require.NoError(t, err)
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.ErrorContains(t, err, "disk full")
+	require.Equal(t, 1, status)
+	require.Contains(t, archive.String(), "hello dummy")
+}
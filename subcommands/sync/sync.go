@@ -42,9 +42,14 @@ func (cmd *Sync) Parse(ctx *appcontext.AppContext, args []string) error {
 	flags.Usage = func() {
 		fmt.Fprintf(flags.Output(), "Usage: %s [SNAPSHOT] to REPOSITORY\n", flags.Name())
 		fmt.Fprintf(flags.Output(), "       %s [SNAPSHOT] from REPOSITORY\n", flags.Name())
+		fmt.Fprintf(flags.Output(), "       %s -from REPOSITORY [SNAPSHOT]\n", flags.Name())
 		flags.PrintDefaults()
 	}
+	var opt_from string
 	cmd.SrcLocateOptions.InstallFlags(flags)
+	flags.StringVar(&opt_from, "from", "", "synchronize snapshots from this repository, same as the from direction")
+	flags.BoolVar(&cmd.Delete, "delete", false, "delete snapshots present only in the destination repository")
+	flags.BoolVar(&cmd.Verify, "verify", false, "check synchronized snapshots in the destination repository")
 
 	flags.Parse(args)
 
@@ -52,6 +57,20 @@ func (cmd *Sync) Parse(ctx *appcontext.AppContext, args []string) error {
 	peerRepositoryPath := ""
 
 	args = flags.Args()
+	if opt_from != "" {
+		switch len(args) {
+		case 0:
+		case 1:
+			if !cmd.SrcLocateOptions.Empty() {
+				ctx.GetLogger().Warn("snapshot specified, filters will be ignored")
+			}
+			cmd.SrcLocateOptions.Prefix = args[0]
+		default:
+			return fmt.Errorf("usage: sync -from REPOSITORY [SNAPSHOT]")
+		}
+		args = []string{"from", opt_from}
+	}
+
 	switch len(args) {
 	case 2:
 		direction = args[0]
@@ -72,6 +91,10 @@ func (cmd *Sync) Parse(ctx *appcontext.AppContext, args []string) error {
 		return fmt.Errorf("invalid direction, must be to, from or with")
 	}
 
+	if cmd.Delete && direction == "with" {
+		return fmt.Errorf("-delete can not be used with a bi-directional synchronization")
+	}
+
 	storeConfig, err := ctx.Config.GetRepository(peerRepositoryPath)
 	if err != nil {
 		return fmt.Errorf("peer repository: %w", err)
@@ -141,6 +164,8 @@ type Sync struct {
 	PeerRepositorySecret   []byte
 
 	Direction string
+	Delete    bool
+	Verify    bool
 
 	SrcLocateOptions *utils.LocateOptions
 }
@@ -201,6 +226,7 @@ func (cmd *Sync) Execute(ctx *appcontext.AppContext, repo *repository.Repository
 	}
 
 	srcSyncList := make([]objects.MAC, 0)
+	failures := 0
 
 	srcSnapshotIDs, err := utils.LocateSnapshotIDs(srcRepository, cmd.SrcLocateOptions)
 	if err != nil {
@@ -222,6 +248,38 @@ func (cmd *Sync) Execute(ctx *appcontext.AppContext, repo *repository.Repository
 		if err != nil {
 			ctx.GetLogger().Error("failed to synchronize snapshot %x from source repository %s: %s",
 				snapshotID[:4], srcRepository.Location(), err)
+			continue
+		}
+
+		if cmd.Verify {
+			if err := verify(ctx, dstRepository, snapshotID); err != nil {
+				ctx.GetLogger().Error("failed to verify snapshot %x in repository %s: %s",
+					snapshotID[:4], dstRepository.Location(), err)
+				failures++
+			}
+		}
+	}
+
+	deleted := 0
+	if cmd.Delete {
+		dstSnapshotIDs, err := utils.LocateSnapshotIDs(dstRepository, cmd.SrcLocateOptions)
+		if err != nil {
+			return 1, fmt.Errorf("could not locate snapshots in destination repository %s: %s", dstRepository.Location(), err)
+		}
+
+		for _, snapshotID := range dstSnapshotIDs {
+			if err := ctx.Err(); err != nil {
+				return 1, err
+			}
+			if _, exists := srcSnapshotsMap[snapshotID]; exists {
+				continue
+			}
+			if err := dstRepository.DeleteSnapshot(snapshotID); err != nil {
+				ctx.GetLogger().Error("failed to delete snapshot %x from destination repository %s: %s",
+					snapshotID[:4], dstRepository.Location(), err)
+				continue
+			}
+			deleted++
 		}
 	}
 
@@ -246,6 +304,15 @@ func (cmd *Sync) Execute(ctx *appcontext.AppContext, repo *repository.Repository
 			if err != nil {
 				ctx.GetLogger().Error("failed to synchronize snapshot %x from peer repository %s: %s",
 					snapshotID[:4], dstRepository.Location(), err)
+				continue
+			}
+
+			if cmd.Verify {
+				if err := verify(ctx, srcRepository, snapshotID); err != nil {
+					ctx.GetLogger().Error("failed to verify snapshot %x in repository %s: %s",
+						snapshotID[:4], srcRepository.Location(), err)
+					failures++
+				}
 			}
 		}
 		ctx.GetLogger().Info("sync: synchronization between %s and %s completed: %d snapshots synchronized",
@@ -264,6 +331,14 @@ func (cmd *Sync) Execute(ctx *appcontext.AppContext, repo *repository.Repository
 			len(srcSyncList))
 	}
 
+	if cmd.Delete {
+		ctx.GetLogger().Info("sync: %d snapshots deleted from %s", deleted, dstRepository.Location())
+	}
+
+	if failures != 0 {
+		return 1, fmt.Errorf("failed to verify %d snapshots", failures)
+	}
+
 	return 0, nil
 }
 
@@ -293,3 +368,25 @@ func synchronize(ctx *appcontext.AppContext, srcRepository, dstRepository *repos
 	ctx.GetLogger().Info("Synchronization of %x finished", snapshotID)
 	return err
 }
+
+func verify(ctx *appcontext.AppContext, repo *repository.Repository, snapshotID objects.MAC) error {
+	checkCache, err := ctx.GetCache().Check()
+	if err != nil {
+		return err
+	}
+	defer checkCache.Close()
+
+	snap, err := snapshot.Load(repo, snapshotID)
+	if err != nil {
+		return err
+	}
+	defer snap.Close()
+
+	snap.SetCheckCache(checkCache)
+	if err := snap.Check("/", &snapshot.CheckOptions{MaxConcurrency: uint64(ctx.MaxConcurrency)}); err != nil {
+		return err
+	}
+
+	ctx.GetLogger().Info("sync: verification of %x in %s completed successfully", snapshotID[:4], repo.Location())
+	return nil
+}
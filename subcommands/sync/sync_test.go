@@ -9,6 +9,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/PlakarKorp/kloset/objects"
 	"github.com/PlakarKorp/kloset/repository"
 	"github.com/PlakarKorp/kloset/snapshot"
 	"github.com/PlakarKorp/plakar/appcontext"
@@ -128,3 +129,115 @@ func TestExecuteCmdSyncWithEncryption(t *testing.T) {
 	output := bufOut.String()
 	require.Contains(t, strings.Trim(output, "\n"), fmt.Sprintf("info: sync: synchronization between %s and %s completed: 1 snapshots synchronized", localRepo.Location(), peerRepo.Location()))
 }
+
+func TestExecuteCmdSyncToDelete(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	localRepo, snap, lctx := generateSnapshot(t, bufOut, bufErr)
+	defer snap.Close()
+
+	peerRepo, _ := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	peerSnap := ptesting.GenerateSnapshot(t, peerRepo, []ptesting.MockFile{
+		ptesting.NewMockDir("peer"),
+		ptesting.NewMockFile("peer/only.txt", 0644, "only on peer"),
+	})
+	peerSnap.Close()
+
+	subcommand := &Sync{}
+	err := subcommand.Parse(lctx, []string{"-delete", "with", peerRepo.Location()})
+	require.Error(t, err)
+
+	subcommand = &Sync{}
+	err = subcommand.Parse(lctx, []string{"-delete", "to", peerRepo.Location()})
+	require.NoError(t, err)
+
+	status, err := subcommand.Execute(lctx, localRepo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	output := bufOut.String()
+	require.Contains(t, output, fmt.Sprintf("info: sync: synchronization from %s to %s completed: 1 snapshots synchronized", localRepo.Location(), peerRepo.Location()))
+	require.Contains(t, output, fmt.Sprintf("info: sync: 1 snapshots deleted from %s", peerRepo.Location()))
+
+	// the peer now holds the source snapshot and nothing else
+	require.NoError(t, peerRepo.RebuildState())
+	peerSnapshots, err := peerRepo.GetSnapshots()
+	require.NoError(t, err)
+	require.Equal(t, []objects.MAC{snap.Header.Identifier}, peerSnapshots)
+
+	// the source is left untouched
+	localSnapshots, err := localRepo.GetSnapshots()
+	require.NoError(t, err)
+	require.Equal(t, []objects.MAC{snap.Header.Identifier}, localSnapshots)
+}
+
+func TestExecuteCmdSyncToVerify(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	localRepo, snap, lctx := generateSnapshot(t, bufOut, bufErr)
+	defer snap.Close()
+
+	peerRepo, _ := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+
+	indexId := snap.Header.GetIndexID()
+	args := []string{"-verify", hex.EncodeToString(indexId[:]), "to", peerRepo.Location()}
+
+	subcommand := &Sync{}
+	err := subcommand.Parse(lctx, args)
+	require.NoError(t, err)
+
+	status, err := subcommand.Execute(lctx, localRepo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	output := bufOut.String()
+	require.Contains(t, output, fmt.Sprintf("info: sync: verification of %x in %s completed successfully", indexId[:4], peerRepo.Location()))
+}
+
+func TestExecuteCmdSyncFromFlag(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	srcRepo, snap, _ := generateSnapshot(t, bufOut, bufErr)
+	defer snap.Close()
+	srcSnap := ptesting.GenerateSnapshot(t, srcRepo, []ptesting.MockFile{
+		ptesting.NewMockDir("other"),
+		ptesting.NewMockFile("other/file.txt", 0644, "another snapshot"),
+	})
+	srcSnap.Close()
+
+	dstRepo, dctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+
+	subcommand := &Sync{}
+	err := subcommand.Parse(dctx, []string{"-from", srcRepo.Location()})
+	require.NoError(t, err)
+	require.Equal(t, "from", subcommand.Direction)
+
+	status, err := subcommand.Execute(dctx, dstRepo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	srcSnapshots, err := srcRepo.GetSnapshots()
+	require.NoError(t, err)
+	dstSnapshots, err := dstRepo.GetSnapshots()
+	require.NoError(t, err)
+	require.Len(t, srcSnapshots, 2)
+	require.ElementsMatch(t, srcSnapshots, dstSnapshots)
+
+	// a second run has nothing left to transfer
+	bufOut.Reset()
+	subcommand = &Sync{}
+	err = subcommand.Parse(dctx, []string{"-from", srcRepo.Location()})
+	require.NoError(t, err)
+
+	status, err = subcommand.Execute(dctx, dstRepo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+	require.Contains(t, bufOut.String(), "completed: 0 snapshots synchronized")
+
+	subcommand = &Sync{}
+	err = subcommand.Parse(dctx, []string{"-from", srcRepo.Location(), "abcd", "efgh"})
+	require.Error(t, err)
+}
@@ -0,0 +1,140 @@
+package tag
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/snapshot"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/subcommands"
+	"github.com/PlakarKorp/plakar/subcommands/clone"
+	"github.com/PlakarKorp/plakar/utils"
+	"github.com/google/uuid"
+)
+
+func init() {
+	subcommands.Register(func() subcommands.Subcommand { return &Tag{} }, subcommands.AgentSupport, "tag")
+}
+
+func (cmd *Tag) Parse(ctx *appcontext.AppContext, args []string) error {
+	flags := flag.NewFlagSet("tag", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [OPTIONS] SNAPSHOT TAG...\n", flags.Name())
+		fmt.Fprintf(flags.Output(), "\nOPTIONS:\n")
+		flags.PrintDefaults()
+	}
+	flags.BoolVar(&cmd.Remove, "remove", false, "remove the tags from the snapshot instead of adding them")
+	flags.BoolVar(&cmd.Force, "force", false, "retag a snapshot signed by another identity, dropping its signature")
+	flags.Parse(args)
+
+	if flags.NArg() < 2 {
+		return fmt.Errorf("usage: %s [OPTIONS] SNAPSHOT TAG...", flags.Name())
+	}
+	for _, tag := range flags.Args()[1:] {
+		if tag == "" {
+			return fmt.Errorf("empty tag")
+		}
+	}
+
+	cmd.RepositorySecret = ctx.GetSecret()
+	cmd.Snapshot = flags.Arg(0)
+	cmd.Tags = flags.Args()[1:]
+
+	return nil
+}
+
+type Tag struct {
+	subcommands.SubcommandBase
+
+	Remove   bool
+	Force    bool
+	Snapshot string
+	Tags     []string
+}
+
+func (cmd *Tag) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
+	snapshotID, err := utils.LocateSnapshotByPrefix(repo, cmd.Snapshot)
+	if err != nil {
+		return 1, fmt.Errorf("tag: %w", err)
+	}
+
+	var newID objects.MAC
+	if cmd.Remove {
+		newID, err = RemoveTags(repo, snapshotID, cmd.Force, cmd.Tags...)
+	} else {
+		newID, err = AddTags(repo, snapshotID, cmd.Force, cmd.Tags...)
+	}
+	if err != nil {
+		return 1, fmt.Errorf("tag: failed to update snapshot %x: %w", snapshotID[:4], err)
+	}
+
+	if newID != snapshotID {
+		ctx.GetLogger().Info("tag: snapshot %x is now %x", snapshotID[:4], newID[:4])
+	}
+	return 0, nil
+}
+
+// AddTags adds tags to a snapshot.  The identifier of a snapshot
+// is the MAC of its header, so a copy of the snapshot carrying the new
+// tags is stored and the original removed: the returned identifier is
+// the one of the copy, or the original one if it already had all the
+// tags.
+//
+// The copy is signed by the current identity, if any: unless force is
+// set, a snapshot signed by another identity is refused rather than
+// losing its signature.
+func AddTags(repo *repository.Repository, snapshotID objects.MAC, force bool, tags ...string) (objects.MAC, error) {
+	return retag(repo, snapshotID, force, func(current []string) []string {
+		for _, tag := range tags {
+			if !slices.Contains(current, tag) {
+				current = append(current, tag)
+			}
+		}
+		return current
+	})
+}
+
+// RemoveTags removes tags from a snapshot, the same way AddTags adds
+// them.
+func RemoveTags(repo *repository.Repository, snapshotID objects.MAC, force bool, tags ...string) (objects.MAC, error) {
+	return retag(repo, snapshotID, force, func(current []string) []string {
+		return slices.DeleteFunc(current, func(tag string) bool {
+			return slices.Contains(tags, tag)
+		})
+	})
+}
+
+func retag(repo *repository.Repository, snapshotID objects.MAC, force bool, update func([]string) []string) (objects.MAC, error) {
+	hdr, _, err := snapshot.GetSnapshot(repo, snapshotID)
+	if err != nil {
+		return objects.MAC{}, err
+	}
+
+	tags := update(slices.Clone(hdr.Tags))
+	if slices.Equal(tags, hdr.Tags) {
+		return snapshotID, nil
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+
+	signer := hdr.Identity.Identifier
+	if signer != uuid.Nil && signer != repo.AppContext().Identity && !force {
+		return objects.MAC{}, fmt.Errorf("snapshot is signed by identity %s, retagging it would drop the signature", signer)
+	}
+
+	// store the copy first, so that a failure leaves the snapshot
+	// around rather than none.
+	newID, err := clone.CloneSnapshot(repo, snapshotID, hdr.Name, tags)
+	if err != nil {
+		return objects.MAC{}, err
+	}
+
+	if err := repo.DeleteSnapshot(snapshotID); err != nil {
+		return objects.MAC{}, err
+	}
+	return newID, nil
+}
@@ -0,0 +1,149 @@
+package tag
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/PlakarKorp/kloset/encryption/keypair"
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/snapshot"
+	ptesting "github.com/PlakarKorp/plakar/testing"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	os.Setenv("TZ", "UTC")
+}
+
+func listSnapshots(t *testing.T, repo *repository.Repository) []objects.MAC {
+	require.NoError(t, repo.RebuildState())
+	return slices.Collect(repo.ListSnapshots())
+}
+
+func TestExecuteCmdTag(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/dummy.txt", 0644, "hello dummy"),
+	})
+	snap.Close()
+
+	subcommand := &Tag{}
+	err := subcommand.Parse(ctx, []string{hex.EncodeToString(snap.Header.Identifier[:]), "foo", "bar"})
+	require.NoError(t, err)
+
+	status, err := subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	snapshots := listSnapshots(t, repo)
+	require.Len(t, snapshots, 1)
+	require.NotEqual(t, snap.Header.Identifier, snapshots[0])
+
+	tagged, err := snapshot.Load(repo, snapshots[0])
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo", "bar"}, tagged.Header.Tags)
+	require.Equal(t, snap.Header.Name, tagged.Header.Name)
+	require.Equal(t, snap.Header.GetSource(0).VFS, tagged.Header.GetSource(0).VFS)
+	tagged.Close()
+
+	subcommand = &Tag{}
+	err = subcommand.Parse(ctx, []string{"-remove", hex.EncodeToString(snapshots[0][:]), "foo"})
+	require.NoError(t, err)
+
+	status, err = subcommand.Execute(ctx, repo)
+	require.NoError(t, err)
+	require.Equal(t, 0, status)
+
+	snapshots = listSnapshots(t, repo)
+	require.Len(t, snapshots, 1)
+
+	untagged, err := snapshot.Load(repo, snapshots[0])
+	require.NoError(t, err)
+	require.Equal(t, []string{"bar"}, untagged.Header.Tags)
+	untagged.Close()
+}
+
+func TestAddTagsUnchanged(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, _ := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockFile("dummy.txt", 0644, "hello dummy"),
+	})
+	snap.Close()
+
+	// removing a tag the snapshot does not have leaves it untouched
+	snapshotID, err := RemoveTags(repo, snap.Header.Identifier, false, "foo")
+	require.NoError(t, err)
+	require.Equal(t, snap.Header.Identifier, snapshotID)
+	require.Equal(t, []objects.MAC{snap.Header.Identifier}, listSnapshots(t, repo))
+}
+
+func TestAddTagsSigned(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+
+	kp, err := keypair.Generate()
+	require.NoError(t, err)
+	ctx.Identity = uuid.New()
+	ctx.Keypair = kp
+
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockFile("dummy.txt", 0644, "hello dummy"),
+	})
+	snap.Close()
+	signer := snap.Header.Identity.Identifier
+	require.Equal(t, ctx.Identity, signer)
+
+	// the signing identity may retag its own snapshots
+	snapshotID, err := AddTags(repo, snap.Header.Identifier, false, "foo")
+	require.NoError(t, err)
+	require.NotEqual(t, snap.Header.Identifier, snapshotID)
+
+	tagged, err := snapshot.Load(repo, snapshotID)
+	require.NoError(t, err)
+	require.Equal(t, signer, tagged.Header.Identity.Identifier)
+	tagged.Close()
+
+	// another one may not, unless forced
+	other, err := keypair.Generate()
+	require.NoError(t, err)
+	ctx.Identity = uuid.New()
+	ctx.Keypair = other
+
+	_, err = AddTags(repo, snapshotID, false, "bar")
+	require.ErrorContains(t, err, "signed by identity "+signer.String())
+	require.Equal(t, []objects.MAC{snapshotID}, listSnapshots(t, repo))
+
+	forcedID, err := AddTags(repo, snapshotID, true, "bar")
+	require.NoError(t, err)
+	require.Equal(t, []objects.MAC{forcedID}, listSnapshots(t, repo))
+
+	forced, err := snapshot.Load(repo, forcedID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo", "bar"}, forced.Header.Tags)
+	require.Equal(t, ctx.Identity, forced.Header.Identity.Identifier)
+	forced.Close()
+}
+
+func TestParseCmdTagUsage(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	_, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+
+	require.Error(t, (&Tag{}).Parse(ctx, []string{"abcd"}))
+	require.Error(t, (&Tag{}).Parse(ctx, []string{"abcd", ""}))
+}
@@ -0,0 +1,235 @@
+package watchrestore
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/snapshot"
+	"github.com/PlakarKorp/kloset/snapshot/exporter"
+	"github.com/PlakarKorp/kloset/snapshot/vfs"
+	"github.com/PlakarKorp/plakar/appcontext"
+	"github.com/PlakarKorp/plakar/subcommands"
+	"github.com/PlakarKorp/plakar/utils"
+	"github.com/fsnotify/fsnotify"
+)
+
+func init() {
+	subcommands.Register(func() subcommands.Subcommand { return &WatchRestore{} }, 0, "watch-restore")
+}
+
+func (cmd *WatchRestore) Parse(ctx *appcontext.AppContext, args []string) error {
+	flags := flag.NewFlagSet("watch-restore", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [OPTIONS] SNAPSHOT:PATH\n", flags.Name())
+		fmt.Fprintf(flags.Output(), "\nOPTIONS:\n")
+		flags.PrintDefaults()
+	}
+
+	flags.StringVar(&cmd.Target, "to", "", "directory the path is restored into, defaults to its original location")
+	flags.DurationVar(&cmd.Interval, "interval", time.Minute, "interval between checksum comparisons, 0 to only react to deletions")
+	flags.DurationVar(&cmd.Cooldown, "cooldown", 5*time.Minute, "minimum delay between two restores")
+	flags.Uint64Var(&cmd.Concurrency, "concurrency", uint64(ctx.MaxConcurrency), "maximum number of parallel tasks")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("need exactly one snapshot path to watch")
+	}
+
+	if prefix, pathname := utils.ParseSnapshotPath(flags.Arg(0)); prefix == "" || pathname == "" {
+		return fmt.Errorf("snapshot path must be of the form SNAPSHOT:PATH")
+	}
+
+	if cmd.Target != "" && !filepath.IsAbs(cmd.Target) {
+		cmd.Target = filepath.Join(ctx.CWD, cmd.Target)
+	}
+
+	if cmd.Interval < 0 || cmd.Cooldown < 0 {
+		return fmt.Errorf("durations can not be negative")
+	}
+
+	cmd.RepositorySecret = ctx.GetSecret()
+	cmd.SnapshotPath = flags.Arg(0)
+
+	return nil
+}
+
+type WatchRestore struct {
+	subcommands.SubcommandBase
+
+	SnapshotPath string
+	Target       string
+	Interval     time.Duration
+	Cooldown     time.Duration
+	Concurrency  uint64
+}
+
+func (cmd *WatchRestore) Execute(ctx *appcontext.AppContext, repo *repository.Repository) (int, error) {
+	snap, pathname, err := utils.OpenSnapshotByPath(repo, cmd.SnapshotPath)
+	if err != nil {
+		return 1, err
+	}
+	defer snap.Close()
+
+	fs, err := snap.Filesystem()
+	if err != nil {
+		return 1, err
+	}
+
+	if _, err := fs.GetEntry(pathname); err != nil {
+		return 1, fmt.Errorf("%s: %w", pathname, err)
+	}
+
+	target := cmd.Target
+	if target == "" {
+		target = filepath.FromSlash(path.Dir(pathname))
+	}
+	watched := filepath.Join(target, path.Base(pathname))
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return 1, err
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(target); err != nil {
+		return 1, err
+	}
+	if info, err := os.Stat(watched); err == nil && info.IsDir() {
+		if err := watcher.Add(watched); err != nil {
+			return 1, err
+		}
+	}
+
+	var ticker <-chan time.Time
+	if cmd.Interval != 0 {
+		t := time.NewTicker(cmd.Interval)
+		defer t.Stop()
+		ticker = t.C
+	}
+
+	ctx.GetLogger().Info("watch-restore: watching %s against %x:%s", watched, snap.Header.GetIndexShortID(), pathname)
+
+	var lastRestore time.Time
+	for {
+		reason := ""
+
+		select {
+		case <-ctx.Done():
+			return 0, nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return 1, fmt.Errorf("watcher closed")
+			}
+			if event.Name != watched && !strings.HasPrefix(event.Name, watched+string(filepath.Separator)) {
+				continue
+			}
+			if !event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+			reason = fmt.Sprintf("%s was removed", event.Name)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return 1, fmt.Errorf("watcher closed")
+			}
+			ctx.GetLogger().Warn("watch-restore: %s", err)
+			continue
+
+		case <-ticker:
+			changed, err := cmd.compare(fs, repo, pathname, target)
+			if err != nil {
+				ctx.GetLogger().Warn("watch-restore: %s", err)
+				continue
+			}
+			if changed == "" {
+				continue
+			}
+			reason = fmt.Sprintf("%s does not match the snapshot", changed)
+		}
+
+		if !lastRestore.IsZero() && time.Since(lastRestore) < cmd.Cooldown {
+			ctx.GetLogger().Warn("watch-restore: %s, restore skipped as the previous one happened %s ago",
+				reason, time.Since(lastRestore).Round(time.Second))
+			continue
+		}
+
+		ctx.GetLogger().Info("watch-restore: %s, restoring from %x", reason, snap.Header.GetIndexShortID())
+		lastRestore = time.Now()
+		if err := cmd.restore(ctx, snap, pathname, target); err != nil {
+			ctx.GetLogger().Error("watch-restore: restore of %s failed: %s", watched, err)
+			continue
+		}
+		ctx.GetLogger().Info("watch-restore: restore of %s completed successfully", watched)
+
+		// a restored directory is a new inode, it has to be watched again
+		if info, err := os.Stat(watched); err == nil && info.IsDir() {
+			watcher.Add(watched)
+		}
+	}
+}
+
+// compare returns the first local pathname whose content differs from
+// the snapshot, or an empty string if everything matches.
+func (cmd *WatchRestore) compare(fs *vfs.Filesystem, repo *repository.Repository, pathname, target string) (string, error) {
+	changed := ""
+	err := fs.WalkDir(pathname, func(entrypath string, entry *vfs.Entry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Stat().Mode().IsRegular() || entry.ResolvedObject == nil {
+			return nil
+		}
+
+		local := filepath.Join(target, filepath.FromSlash(strings.TrimPrefix(entrypath, path.Dir(pathname))))
+		same, err := sameContent(repo, local, entry.ResolvedObject.ContentMAC[:])
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if !same {
+			changed = local
+			return iofs.SkipAll
+		}
+		return nil
+	})
+	return changed, err
+}
+
+func (cmd *WatchRestore) restore(ctx *appcontext.AppContext, snap *snapshot.Snapshot, pathname, target string) error {
+	exporterInstance, err := exporter.NewExporter(ctx.GetInner(), map[string]string{
+		"location": target,
+	})
+	if err != nil {
+		return err
+	}
+	defer exporterInstance.Close()
+
+	opts := &snapshot.RestoreOptions{
+		MaxConcurrency: cmd.Concurrency,
+		Strip:          path.Dir(pathname),
+	}
+	return snap.Restore(exporterInstance, exporterInstance.Root(), pathname, opts)
+}
+
+func sameContent(repo *repository.Repository, pathname string, mac []byte) (bool, error) {
+	fp, err := os.Open(pathname)
+	if err != nil {
+		return false, err
+	}
+	defer fp.Close()
+
+	hasher := repo.GetMACHasher()
+	if _, err := io.Copy(hasher, fp); err != nil {
+		return false, err
+	}
+	return bytes.Equal(hasher.Sum(nil), mac), nil
+}
@@ -0,0 +1,102 @@
+package watchrestore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PlakarKorp/kloset/logging"
+	_ "github.com/PlakarKorp/plakar/connectors/fs/exporter"
+	ptesting "github.com/PlakarKorp/plakar/testing"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer lets the test read the logs while the watcher writes them.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestExecuteCmdWatchRestore(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, ctx := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+
+	logs := &syncBuffer{}
+	logger := logging.NewLogger(logs, logs)
+	logger.EnableInfo()
+	ctx.SetLogger(logger)
+
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/dummy.txt", 0644, "hello dummy"),
+		ptesting.NewMockFile("subdir/foo.txt", 0644, "hello foo"),
+	})
+	defer snap.Close()
+
+	target := t.TempDir()
+	watched := filepath.Join(target, "subdir")
+	require.NoError(t, os.Mkdir(watched, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(watched, "dummy.txt"), []byte("hello dummy"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(watched, "foo.txt"), []byte("hello foo"), 0644))
+
+	indexId := snap.Header.GetIndexID()
+	args := []string{"-to", target, "-interval", "0", "-cooldown", "0",
+		hex.EncodeToString(indexId[:]) + ":/subdir"}
+
+	subcommand := &WatchRestore{}
+	require.NoError(t, subcommand.Parse(ctx, args))
+
+	type result struct {
+		status int
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		status, err := subcommand.Execute(ctx, repo)
+		done <- result{status, err}
+	}()
+
+	require.Eventually(t, func() bool {
+		return strings.Contains(logs.String(), "watch-restore: watching")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	deleted := filepath.Join(watched, "foo.txt")
+	require.NoError(t, os.Remove(deleted))
+
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(deleted)
+		return err == nil && string(data) == "hello foo"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		return strings.Contains(logs.String(), "restore of "+watched+" completed successfully")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	ctx.Cancel()
+	select {
+	case res := <-done:
+		require.NoError(t, res.err)
+		require.Equal(t, 0, res.status)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch-restore did not stop once cancelled")
+	}
+}
@@ -15,7 +15,7 @@ import (
 	"github.com/PlakarKorp/plakar/subcommands/sync"
 )
 
-func RunCommand(ctx *appcontext.AppContext, cmd subcommands.Subcommand, repo *repository.Repository, taskName string) (int, error) {
+func RunCommand(ctx *appcontext.AppContext, cmd subcommands.Subcommand, repo *repository.Repository, taskName string, notifier *reporting.Dispatcher) (int, error) {
 
 	var taskKind string
 	switch cmd.(type) {
@@ -46,6 +46,9 @@ func RunCommand(ctx *appcontext.AppContext, cmd subcommands.Subcommand, repo *re
 	}
 
 	reporter := reporting.NewReporter(ctx, doReport, repo, ctx.GetLogger())
+	if taskKind != "" {
+		reporter.WithDispatcher(notifier)
+	}
 	reporter.TaskStart(taskKind, taskName)
 	if repo != nil {
 		reporter.WithRepositoryName(repo.Location())
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 
 	_ "github.com/PlakarKorp/plakar/connectors/fs/importer"
 
@@ -19,10 +20,12 @@ import (
 )
 
 type MockFile struct {
-	Path    string
-	IsDir   bool
-	Mode    os.FileMode
-	Content []byte
+	Path          string
+	IsDir         bool
+	Mode          os.FileMode
+	Content       []byte
+	ModTime       time.Time
+	SymlinkTarget string
 }
 
 func NewMockDir(path string) MockFile {
@@ -41,6 +44,20 @@ func NewMockFile(path string, mode os.FileMode, content string) MockFile {
 	}
 }
 
+func NewMockFileModTime(path string, mode os.FileMode, content string, mtime time.Time) MockFile {
+	file := NewMockFile(path, mode, content)
+	file.ModTime = mtime
+	return file
+}
+
+func NewMockSymlink(path string, target string) MockFile {
+	return MockFile{
+		Path:          path,
+		Mode:          os.ModeSymlink | 0777,
+		SymlinkTarget: target,
+	}
+}
+
 func (m *MockFile) ScanResult() *importer.ScanResult {
 	switch {
 	case m.IsDir:
@@ -61,11 +78,12 @@ func (m *MockFile) ScanResult() *importer.ScanResult {
 			Lname:      path.Base(m.Path),
 			Lsize:      int64(len(m.Content)),
 			Lmode:      m.Mode,
+			LmodTime:   m.ModTime,
 			Lnlink:     1,
 			Lusername:  "flan",
 			Lgroupname: "hacker",
 		}
-		return importer.NewScanRecord(m.Path, "", info, nil, func() (io.ReadCloser, error) {
+		return importer.NewScanRecord(m.Path, m.SymlinkTarget, info, nil, func() (io.ReadCloser, error) {
 			if m.Mode&0400 == 0 {
 				return nil, os.ErrPermission
 			}
@@ -93,7 +111,7 @@ func WithName(name string) TestingOptions {
 	}
 }
 
-func GenerateFiles(t *testing.T, files []MockFile) string {
+func GenerateFiles(t testing.TB, files []MockFile) string {
 	tmpBackupDir, err := os.MkdirTemp("", "tmp_to_backup")
 	require.NoError(t, err)
 	t.Cleanup(func() {
@@ -118,7 +136,7 @@ func WithGenerator(gen func(chan<- *importer.ScanResult)) TestingOptions {
 	}
 }
 
-func GenerateSnapshot(t *testing.T, repo *repository.Repository, files []MockFile, opts ...TestingOptions) *snapshot.Snapshot {
+func GenerateSnapshot(t testing.TB, repo *repository.Repository, files []MockFile, opts ...TestingOptions) *snapshot.Snapshot {
 	o := newTestingOptions()
 	for _, f := range opts {
 		f(o)
@@ -0,0 +1,207 @@
+// Package storagetest provides a test suite shared by the storage
+// backends, so that they can all be held to the same behavior.
+package storagetest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// LargeObjectSize is the size of the packfile written by the large
+// object test, big enough to cross the buffering of most backends.
+const LargeObjectSize = 32 << 20
+
+// Factory returns a freshly created and opened store for each test. It
+// may return nil to skip the suite, for instance when the service backing
+// the store is not reachable.
+type Factory func(t *testing.T) storage.Store
+
+func mac(name string) objects.MAC {
+	return objects.MAC(sha256.Sum256([]byte(name)))
+}
+
+func readAll(t *testing.T, rd io.Reader, err error) []byte {
+	require.NoError(t, err)
+	data, err := io.ReadAll(rd)
+	require.NoError(t, err)
+	if closer, ok := rd.(io.Closer); ok {
+		closer.Close()
+	}
+	return data
+}
+
+// BackendTestSuite runs the whole suite against the stores returned by
+// factory.
+func BackendTestSuite(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		fn   func(*testing.T, storage.Store)
+	}{
+		{"States", testStates},
+		{"Packfiles", testPackfiles},
+		{"Locks", testLocks},
+		{"Concurrent", testConcurrent},
+		{"LargeObject", testLargeObject},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := factory(t)
+			if store == nil {
+				t.Skip("store is not available")
+			}
+			test.fn(t, store)
+		})
+	}
+}
+
+func testStates(t *testing.T, store storage.Store) {
+	mac1, mac2 := mac("state1"), mac("state2")
+
+	_, err := store.PutState(mac1, bytes.NewReader([]byte("state1")))
+	require.NoError(t, err)
+	_, err = store.PutState(mac2, bytes.NewReader([]byte("state2")))
+	require.NoError(t, err)
+
+	states, err := store.GetStates()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []objects.MAC{mac1, mac2}, states)
+
+	rd, err := store.GetState(mac2)
+	require.Equal(t, []byte("state2"), readAll(t, rd, err))
+
+	require.NoError(t, store.DeleteState(mac1))
+
+	states, err = store.GetStates()
+	require.NoError(t, err)
+	require.Equal(t, []objects.MAC{mac2}, states)
+}
+
+func testPackfiles(t *testing.T, store storage.Store) {
+	mac1, mac2 := mac("packfile1"), mac("packfile2")
+
+	_, err := store.PutPackfile(mac1, bytes.NewReader([]byte("packfile1")))
+	require.NoError(t, err)
+	_, err = store.PutPackfile(mac2, bytes.NewReader([]byte("packfile2")))
+	require.NoError(t, err)
+
+	packfiles, err := store.GetPackfiles()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []objects.MAC{mac1, mac2}, packfiles)
+
+	rd, err := store.GetPackfile(mac1)
+	require.Equal(t, []byte("packfile1"), readAll(t, rd, err))
+
+	rd, err = store.GetPackfileBlob(mac2, 4, 4)
+	require.Equal(t, []byte("file"), readAll(t, rd, err))
+
+	require.NoError(t, store.DeletePackfile(mac1))
+
+	packfiles, err = store.GetPackfiles()
+	require.NoError(t, err)
+	require.Equal(t, []objects.MAC{mac2}, packfiles)
+}
+
+func testLocks(t *testing.T, store storage.Store) {
+	lock1, lock2 := mac("lock1"), mac("lock2")
+
+	_, err := store.PutLock(lock1, bytes.NewReader([]byte("lock1")))
+	require.NoError(t, err)
+	_, err = store.PutLock(lock2, bytes.NewReader([]byte("lock2")))
+	require.NoError(t, err)
+
+	locks, err := store.GetLocks()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []objects.MAC{lock1, lock2}, locks)
+
+	rd, err := store.GetLock(lock1)
+	require.Equal(t, []byte("lock1"), readAll(t, rd, err))
+
+	require.NoError(t, store.DeleteLock(lock1))
+
+	locks, err = store.GetLocks()
+	require.NoError(t, err)
+	require.Equal(t, []objects.MAC{lock2}, locks)
+}
+
+func testConcurrent(t *testing.T, store storage.Store) {
+	const workers = 16
+
+	macs := make([]objects.MAC, workers)
+	for i := range macs {
+		macs[i] = mac(fmt.Sprintf("concurrent%d", i))
+	}
+
+	// errors are collected rather than reported from the goroutines,
+	// as require must only be called from the test goroutine.
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for i := range macs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = store.PutPackfile(macs[i], bytes.NewReader([]byte(fmt.Sprintf("data%d", i))))
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	packfiles, err := store.GetPackfiles()
+	require.NoError(t, err)
+	for _, m := range macs {
+		require.True(t, slices.Contains(packfiles, m), "packfile %x is missing", m)
+	}
+
+	results := make([][]byte, workers)
+	for i := range macs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rd, err := store.GetPackfile(macs[i])
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			results[i], errs[i] = io.ReadAll(rd)
+		}()
+	}
+	wg.Wait()
+	for i := range macs {
+		require.NoError(t, errs[i])
+		require.Equal(t, []byte(fmt.Sprintf("data%d", i)), results[i])
+	}
+}
+
+func testLargeObject(t *testing.T, store storage.Store) {
+	if testing.Short() {
+		t.Skip("skipping large object test in short mode")
+	}
+
+	data := make([]byte, LargeObjectSize)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+
+	m := mac("large")
+	n, err := store.PutPackfile(m, bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), n)
+
+	rd, err := store.GetPackfile(m)
+	require.True(t, bytes.Equal(data, readAll(t, rd, err)), "large packfile was not read back intact")
+
+	offset := uint64(LargeObjectSize - 1<<20)
+	rd, err = store.GetPackfileBlob(m, offset, 4096)
+	require.True(t, bytes.Equal(data[offset:offset+4096], readAll(t, rd, err)), "blob at the end of a large packfile was not read back intact")
+}
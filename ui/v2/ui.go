@@ -43,7 +43,9 @@ var content embed.FS
 
 func Ui(repo *repository.Repository, ctx *appcontext.AppContext, addr string, opts *UiOptions) error {
 	server := http.NewServeMux()
-	api.SetupRoutes(server, repo, ctx, opts.Token)
+	if err := api.SetupRoutes(server, repo, ctx, opts.Token); err != nil {
+		return err
+	}
 
 	// Serve files from the ./frontend directory
 	server.HandleFunc("/{path...}", func(w http.ResponseWriter, r *http.Request) {
@@ -11,12 +11,17 @@ import (
 
 type configHandler struct {
 	Path string
+	key  *configKey
 }
 
 func newConfigHandler(path string) *configHandler {
-	return &configHandler{
+	cl := &configHandler{
 		Path: path,
 	}
+	if ck, ok := configKeys.Load(path); ok {
+		cl.key = ck.(*configKey)
+	}
+	return cl
 }
 
 func (cl *configHandler) Load() (*config.Config, error) {
@@ -53,6 +58,10 @@ func (cl *configHandler) Load() (*config.Config, error) {
 		}
 	}
 
+	if err := cl.decrypt(cfg); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 
 fallback:
@@ -72,21 +81,67 @@ fallback:
 	return cfg, nil
 }
 
+// decrypt replaces the encrypted values of cfg with their clear text
+// and remembers the key so that Save encrypts them again.
+func (cl *configHandler) decrypt(cfg *config.Config) error {
+	if !hasEncryptedValues(cfg.Sources) && !hasEncryptedValues(cfg.Destinations) &&
+		!hasEncryptedValues(cfg.Repositories) {
+		return nil
+	}
+
+	passphrase, err := GetConfigPassphrase()
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*configKey)
+	if err := openConfigSection(passphrase, keys, cfg.Sources); err != nil {
+		return fmt.Errorf("sources: %w", err)
+	}
+	if err := openConfigSection(passphrase, keys, cfg.Destinations); err != nil {
+		return fmt.Errorf("destinations: %w", err)
+	}
+	if err := openConfigSection(passphrase, keys, cfg.Repositories); err != nil {
+		return fmt.Errorf("stores: %w", err)
+	}
+
+	for _, ck := range keys {
+		cl.key = ck
+		configKeys.Store(cl.Path, ck)
+		break
+	}
+	return nil
+}
+
 func (cl *configHandler) Save(cfg *config.Config) error {
-	err := cl.save("sources.yml", cfg.Sources)
+	sources, err := sealConfigSection(cl.key, cfg.Sources)
+	if err != nil {
+		return err
+	}
+	err = cl.save("sources.yml", sources)
 	if err != nil {
 		return err
 	}
-	err = cl.save("destinations.yml", cfg.Destinations)
+	destinations, err := sealConfigSection(cl.key, cfg.Destinations)
+	if err != nil {
+		return err
+	}
+	err = cl.save("destinations.yml", destinations)
 	if err != nil {
 		return err
 	}
 	for k, v := range cfg.Repositories {
 		if k == cfg.DefaultRepository {
 			v[".isDefault"] = "yes"
+		} else {
+			delete(v, ".isDefault")
 		}
 	}
-	err = cl.save("klosets.yml", cfg.Repositories)
+	repositories, err := sealConfigSection(cl.key, cfg.Repositories)
+	if err != nil {
+		return err
+	}
+	err = cl.save("klosets.yml", repositories)
 	if err != nil {
 		return err
 	}
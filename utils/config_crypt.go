@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/PlakarKorp/kloset/config"
+	"golang.org/x/crypto/scrypt"
+)
+
+// encrypted configuration values are stored as this prefix followed by
+// the base64 encoding of the salt, the nonce and the sealed value.
+const configEncryptedPrefix = "enc:"
+
+const (
+	configSaltSize = 16
+	configKeySize  = 32
+)
+
+// configKeys remembers, per configuration directory, the key used to
+// decrypt the configuration, so that saving it encrypts the sensitive
+// values again instead of writing them in clear.
+var configKeys sync.Map
+
+type configKey struct {
+	salt []byte
+	aead cipher.AEAD
+}
+
+func newConfigKey(passphrase, salt []byte) (*configKey, error) {
+	key, err := scrypt.Key(passphrase, salt, 1<<15, 8, 1, configKeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &configKey{salt: salt, aead: aead}, nil
+}
+
+// isSensitiveConfigKey tells whether the value of a connector option is
+// a credential that deserves to be encrypted.
+func isSensitiveConfigKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range []string{"passphrase", "password", "secret", "token", "access_key"} {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ck *configKey) seal(value string) (string, error) {
+	nonce := make([]byte, ck.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	buf := append([]byte{}, ck.salt...)
+	buf = append(buf, nonce...)
+	buf = ck.aead.Seal(buf, nonce, []byte(value), nil)
+	return configEncryptedPrefix + base64.StdEncoding.EncodeToString(buf), nil
+}
+
+func (ck *configKey) open(value string) (string, error) {
+	buf, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, configEncryptedPrefix))
+	if err != nil || len(buf) < configSaltSize+ck.aead.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	buf = buf[configSaltSize:]
+
+	nonce, sealed := buf[:ck.aead.NonceSize()], buf[ck.aead.NonceSize():]
+	plain, err := ck.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid configuration passphrase")
+	}
+	return string(plain), nil
+}
+
+// sealConfigSection returns a copy of section with its sensitive values
+// encrypted, leaving the ones already encrypted untouched.
+func sealConfigSection[T ~map[string]string](ck *configKey, section map[string]T) (map[string]T, error) {
+	if ck == nil {
+		return section, nil
+	}
+
+	ret := make(map[string]T, len(section))
+	for name, kv := range section {
+		sealed := maps.Clone(kv)
+		for k, v := range kv {
+			if !isSensitiveConfigKey(k) || strings.HasPrefix(v, configEncryptedPrefix) {
+				continue
+			}
+			s, err := ck.seal(v)
+			if err != nil {
+				return nil, err
+			}
+			sealed[k] = s
+		}
+		ret[name] = sealed
+	}
+	return ret, nil
+}
+
+// openConfigSection decrypts in place the encrypted values of section.
+// The keys derived along the way are cached in keys by salt, as a
+// single passphrase usually protects the whole configuration.
+func openConfigSection[T ~map[string]string](passphrase []byte, keys map[string]*configKey, section map[string]T) error {
+	for name, kv := range section {
+		for k, v := range kv {
+			if !strings.HasPrefix(v, configEncryptedPrefix) {
+				continue
+			}
+
+			buf, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(v, configEncryptedPrefix))
+			if err != nil || len(buf) < configSaltSize {
+				return fmt.Errorf("%s: %s: malformed encrypted value", name, k)
+			}
+			salt := string(buf[:configSaltSize])
+
+			ck, ok := keys[salt]
+			if !ok {
+				ck, err = newConfigKey(passphrase, []byte(salt))
+				if err != nil {
+					return err
+				}
+				keys[salt] = ck
+			}
+
+			plain, err := ck.open(v)
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", name, k, err)
+			}
+			kv[k] = plain
+		}
+	}
+	return nil
+}
+
+func hasEncryptedValues[T ~map[string]string](section map[string]T) bool {
+	for _, kv := range section {
+		for _, v := range kv {
+			if strings.HasPrefix(v, configEncryptedPrefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetConfigPassphrase returns the passphrase protecting the configuration,
+// taken from PLAKAR_CONFIG_PASSPHRASE or asked interactively.
+func GetConfigPassphrase() ([]byte, error) {
+	if passphrase, ok := os.LookupEnv("PLAKAR_CONFIG_PASSPHRASE"); ok {
+		return []byte(passphrase), nil
+	}
+	return GetPassphrase("configuration")
+}
+
+// EncryptConfig saves cfg to configDir with its credentials encrypted
+// by a key derived from passphrase. Later saves of the configuration
+// keep them encrypted.
+func EncryptConfig(configDir string, cfg *config.Config, passphrase []byte) error {
+	salt := make([]byte, configSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	ck, err := newConfigKey(passphrase, salt)
+	if err != nil {
+		return err
+	}
+
+	configKeys.Store(configDir, ck)
+	return SaveConfig(configDir, cfg)
+}
+
+// DecryptConfig saves cfg to configDir with its credentials in clear.
+func DecryptConfig(configDir string, cfg *config.Config) error {
+	configKeys.Delete(configDir)
+	return SaveConfig(configDir, cfg)
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/PlakarKorp/kloset/snapshot/header"
+	"github.com/google/uuid"
+)
+
+// DefaultRPO is the recovery point objective used to judge the age of a
+// snapshot when none is configured.
+const DefaultRPO = 24 * time.Hour
+
+// HealthScore summarizes how much a snapshot can be relied upon, from 0
+// to 100.
+type HealthScore struct {
+	Score  int           `json:"score"`
+	Age    time.Duration `json:"age"`
+	Errors uint64        `json:"errors"`
+	Signed bool          `json:"signed"`
+}
+
+// SnapshotHealth scores a snapshot from its header alone: up to 40
+// points for being no older than rpo, up to 40 for the share of entries
+// backed up without error and 20 for being signed.
+func SnapshotHealth(hdr *header.Header, rpo time.Duration, now time.Time) HealthScore {
+	h := HealthScore{
+		Age:    now.Sub(hdr.Timestamp),
+		Signed: hdr.Identity.Identifier != uuid.Nil,
+	}
+
+	// the age score decreases linearly from one to two RPOs
+	age := 40.0
+	if rpo > 0 && h.Age > rpo {
+		age = 40 * (1 - float64(h.Age-rpo)/float64(rpo))
+		if age < 0 {
+			age = 0
+		}
+	}
+
+	var entries uint64
+	for i := range hdr.Sources {
+		summary := &hdr.Sources[i].Summary
+		h.Errors += summary.Directory.Errors + summary.Below.Errors
+		entries += 1 + summary.Directory.Children + summary.Below.Children
+	}
+	errors := 40.0
+	if h.Errors != 0 {
+		errors = 40 * (1 - float64(h.Errors)/float64(entries))
+		if errors < 0 {
+			errors = 0
+		}
+	}
+
+	signed := 0.0
+	if h.Signed {
+		signed = 20
+	}
+
+	h.Score = int(age + errors + signed + 0.5)
+	return h
+}
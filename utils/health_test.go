@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/snapshot/header"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotHealth(t *testing.T) {
+	now := time.Now()
+
+	hdr := header.NewHeader("test", objects.MAC{})
+	hdr.Timestamp = now.Add(-time.Hour)
+	hdr.Sources[0].Summary.Directory.Children = 9
+
+	// recent, error free but unsigned
+	health := SnapshotHealth(hdr, DefaultRPO, now)
+	require.Equal(t, 80, health.Score)
+	require.False(t, health.Signed)
+
+	hdr.Identity.Identifier = uuid.New()
+	require.Equal(t, 100, SnapshotHealth(hdr, DefaultRPO, now).Score)
+
+	// one entry out of ten failed
+	hdr.Sources[0].Summary.Directory.Errors = 1
+	health = SnapshotHealth(hdr, DefaultRPO, now)
+	require.Equal(t, 96, health.Score)
+	require.Equal(t, uint64(1), health.Errors)
+
+	// half way between one and two RPOs
+	hdr.Sources[0].Summary.Directory.Errors = 0
+	hdr.Timestamp = now.Add(-DefaultRPO * 3 / 2)
+	require.Equal(t, 80, SnapshotHealth(hdr, DefaultRPO, now).Score)
+
+	hdr.Timestamp = now.Add(-DefaultRPO * 3)
+	require.Equal(t, 60, SnapshotHealth(hdr, DefaultRPO, now).Score)
+}
@@ -54,6 +54,9 @@ type LocateOptions struct {
 	Job         string
 	Tag         string
 
+	Origin       string
+	ImporterType string
+
 	Prefix string
 }
 
@@ -73,6 +76,9 @@ func NewDefaultLocateOptions() *LocateOptions {
 		Job:         "",
 		Tag:         "",
 
+		Origin:       "",
+		ImporterType: "",
+
 		Prefix: "",
 	}
 }
@@ -88,6 +94,8 @@ func (lo *LocateOptions) InstallFlags(flags *flag.FlagSet) {
 	flags.StringVar(&lo.Perimeter, "perimeter", "", "filter by perimeter")
 	flags.StringVar(&lo.Job, "job", "", "filter by job")
 	flags.StringVar(&lo.Tag, "tag", "", "filter by tag")
+	flags.StringVar(&lo.Origin, "origin", "", "filter by origin")
+	flags.StringVar(&lo.ImporterType, "importer-type", "", "filter by importer type")
 
 	flags.BoolVar(&lo.Latest, "latest", false, "use latest snapshot")
 
@@ -167,6 +175,18 @@ func LocateSnapshotIDs(repo *repository.Repository, opts *LocateOptions) ([]obje
 				}
 			}
 
+			if opts.Origin != "" {
+				if !strings.EqualFold(snap.Header.GetSource(0).Importer.Origin, opts.Origin) {
+					return
+				}
+			}
+
+			if opts.ImporterType != "" {
+				if !strings.EqualFold(snap.Header.GetSource(0).Importer.Type, opts.ImporterType) {
+					return
+				}
+			}
+
 			if !opts.Before.IsZero() {
 				if snap.Header.Timestamp.After(opts.Before) {
 					return
@@ -142,6 +142,24 @@ func TestLocateSnapshotIDs(t *testing.T) {
 	require.Len(t, results, 1)
 	require.Contains(t, results, snap2.Header.Identifier)
 
+	// Test case: Locate snapshots by origin and importer type
+	opts = &LocateOptions{
+		MaxConcurrency: 1,
+		Origin:         "mock",
+		ImporterType:   "MOCK",
+	}
+	results, err = LocateSnapshotIDs(repo, opts)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	opts = &LocateOptions{
+		MaxConcurrency: 1,
+		Origin:         "db-server-01",
+	}
+	results, err = LocateSnapshotIDs(repo, opts)
+	require.NoError(t, err)
+	require.Len(t, results, 0)
+
 	// Test case: Locate latest snapshot
 	opts = &LocateOptions{
 		MaxConcurrency: 1,
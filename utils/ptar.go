@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"bytes"
+	"hash"
+	"io"
+	"math"
+
+	"github.com/PlakarKorp/kloset/caching"
+	"github.com/PlakarKorp/kloset/hashing"
+	"github.com/PlakarKorp/kloset/objects"
+	"github.com/PlakarKorp/kloset/repository"
+	"github.com/PlakarKorp/kloset/resources"
+	"github.com/PlakarKorp/kloset/storage"
+	"github.com/PlakarKorp/kloset/versioning"
+	"github.com/PlakarKorp/plakar/appcontext"
+)
+
+// PtarWriter writes snapshots to a new ptar archive within a single
+// transaction, committed once all of them are written.
+type PtarWriter struct {
+	*repository.RepositoryWriter
+
+	store      storage.Store
+	scanCache  *caching.ScanCache
+	identifier objects.MAC
+}
+
+// NewPtarWriter creates the ptar archive at location using config,
+// key being the encryption key when config.Encryption is set.
+func NewPtarWriter(ctx *appcontext.AppContext, location string, config *storage.Configuration, key []byte) (*PtarWriter, error) {
+	// a ptar archive is made of a single packfile
+	config.Packfile.MaxSize = math.MaxUint64
+
+	var hasher hash.Hash
+	if config.Encryption != nil {
+		hasher = hashing.GetMACHasher(storage.DEFAULT_HASHING_ALGORITHM, key)
+	} else {
+		hasher = hashing.GetHasher(storage.DEFAULT_HASHING_ALGORITHM)
+	}
+
+	serializedConfig, err := config.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	rd, err := storage.Serialize(hasher, resources.RT_CONFIG, versioning.GetCurrentVersion(resources.RT_CONFIG), bytes.NewReader(serializedConfig))
+	if err != nil {
+		return nil, err
+	}
+	wrappedConfig, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := storage.Create(ctx.GetInner(), map[string]string{"location": location}, wrappedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := repository.New(ctx.GetInner(), key, st, wrappedConfig)
+	if err != nil {
+		st.Close()
+		return nil, err
+	}
+
+	identifier := objects.RandomMAC()
+	scanCache, err := repo.AppContext().GetCache().Scan(identifier)
+	if err != nil {
+		st.Close()
+		return nil, err
+	}
+
+	return &PtarWriter{
+		RepositoryWriter: repo.NewRepositoryWriter(scanCache, identifier, repository.PtarType),
+		store:            st,
+		scanCache:        scanCache,
+		identifier:       identifier,
+	}, nil
+}
+
+// Commit waits for the snapshots to be written, commits them and closes
+// the archive.
+func (pw *PtarWriter) Commit() error {
+	pw.PackerManager.Wait()
+	if err := pw.CommitTransaction(pw.identifier); err != nil {
+		pw.Close()
+		return err
+	}
+	return pw.Close()
+}
+
+// Close closes the archive without committing anything to it.
+func (pw *PtarWriter) Close() error {
+	pw.scanCache.Close()
+	return pw.store.Close()
+}
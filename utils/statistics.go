@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/PlakarKorp/kloset/snapshot"
+)
+
+// MIMEStats accounts for the files of a snapshot sharing a MIME type.
+type MIMEStats struct {
+	Files uint64 `json:"files"`
+	Size  uint64 `json:"size"`
+}
+
+// SnapshotStatistics breaks down the files of a snapshot by top-level
+// MIME type, e.g. "text" or "image".
+type SnapshotStatistics struct {
+	MIME map[string]MIMEStats `json:"mime"`
+}
+
+// GetSnapshotStatistics builds the statistics of a snapshot from its
+// content-type index, whose keys are of the form /<mime>/<path>. The
+// returned statistics are empty if the snapshot has no such index.
+func GetSnapshotStatistics(snap *snapshot.Snapshot) (*SnapshotStatistics, error) {
+	stats := &SnapshotStatistics{
+		MIME: make(map[string]MIMEStats),
+	}
+
+	tree, err := snap.ContentTypeIdx()
+	if err != nil {
+		return nil, err
+	}
+	if tree == nil {
+		return stats, nil
+	}
+
+	fs, err := snap.Filesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	it, err := tree.ScanAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for it.Next() {
+		key, mac := it.Current()
+
+		mime, _, found := strings.Cut(strings.TrimPrefix(key, "/"), "/")
+		if !found {
+			continue
+		}
+
+		entry, err := fs.ResolveEntry(mac)
+		if err != nil {
+			return nil, err
+		}
+
+		s := stats.MIME[mime]
+		s.Files++
+		s.Size += uint64(entry.Stat().Size())
+		stats.MIME[mime] = s
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+
+	ptesting "github.com/PlakarKorp/plakar/testing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSnapshotStatistics(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	repo, _ := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/a.txt", 0644, "hello"),
+		ptesting.NewMockFile("subdir/b.txt", 0644, "hello world"),
+		ptesting.NewMockFile("subdir/c.txt", 0644, "bye"),
+	})
+	defer snap.Close()
+
+	stats, err := GetSnapshotStatistics(snap)
+	require.NoError(t, err)
+	require.Equal(t, map[string]MIMEStats{
+		"text": {Files: 3, Size: 19},
+	}, stats.MIME)
+}
+
+func TestGetSnapshotStatisticsMultipleTypes(t *testing.T) {
+	bufOut := bytes.NewBuffer(nil)
+	bufErr := bytes.NewBuffer(nil)
+
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"
+
+	repo, _ := ptesting.GenerateRepository(t, bufOut, bufErr, nil)
+	snap := ptesting.GenerateSnapshot(t, repo, []ptesting.MockFile{
+		ptesting.NewMockDir("subdir"),
+		ptesting.NewMockFile("subdir/a.txt", 0644, "hello"),
+		ptesting.NewMockFile("subdir/b.png", 0644, png),
+		ptesting.NewMockFile("subdir/c.png", 0644, png),
+		ptesting.NewMockFile("subdir/d.json", 0644, `{"hello": "world"}`),
+	})
+	defer snap.Close()
+
+	stats, err := GetSnapshotStatistics(snap)
+	require.NoError(t, err)
+	require.Equal(t, map[string]MIMEStats{
+		"text":        {Files: 1, Size: 5},
+		"image":       {Files: 2, Size: 2 * uint64(len(png))},
+		"application": {Files: 1, Size: 18},
+	}, stats.MIME)
+}